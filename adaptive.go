@@ -0,0 +1,40 @@
+package dnscache
+
+import "time"
+
+// WithAdaptiveRefresh adapts the background refresh interval of each host to
+// how often its IP set actually changes, within min and max. The interval of a
+// host starts at its refresh interval, the frequency given to New or the one set
+// by WithHostRefreshInterval. It doubles every time a lookup finds the IP set of
+// the host unchanged and halves every time it finds it changed, so that stable
+// hosts are refreshed less often while volatile ones keep failing over fast.
+func WithAdaptiveRefresh(min, max time.Duration) Option {
+	return Option{apply: func(r *Resolver) {
+		if min <= 0 || max < min {
+			r.invalid("WithAdaptiveRefresh", "invalid bounds")
+			return
+		}
+		r.adaptive = &adaptiveRefresh{min: min, max: max}
+	}}
+}
+
+// adaptiveRefresh holds the bounds of adaptive refresh intervals.
+type adaptiveRefresh struct {
+	min time.Duration
+	max time.Duration
+}
+
+// next returns the refresh interval of the entry e of host which replaces old, if
+// any. base is the configured refresh interval of the host.
+func (a *adaptiveRefresh) next(old *entry, e *entry, base time.Duration) time.Duration {
+	interval := base
+	if old != nil && old.interval > 0 {
+		interval = old.interval
+		if sameIPs(old.ips, e.ips) {
+			interval *= 2
+		} else {
+			interval /= 2
+		}
+	}
+	return max(a.min, min(interval, a.max))
+}
@@ -0,0 +1,68 @@
+package dnscache
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveRefresh(t *testing.T) {
+	clock := newFakeClock()
+	ip := "127.0.0.1"
+	lookups := 0
+	r := &Resolver{
+		cache:                map[string]*entry{},
+		freq:                 4 * time.Second,
+		lookupTimeout:        time.Second,
+		defaultLookupTimeout: time.Second,
+		clock:                clock,
+		lookupIPFn: func(ctx context.Context, network, host string) ([]net.IP, error) {
+			lookups++
+			return []net.IP{net.ParseIP(ip)}, nil
+		},
+	}
+	WithAdaptiveRefresh(time.Second, 16*time.Second).apply(r)
+	if got := r.tickInterval(); got != time.Second {
+		t.Fatalf("got %v; want the tick to be the minimum interval", got)
+	}
+
+	if _, err := r.Fetch(context.Background(), "deeeet.com"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	interval := func() time.Duration {
+		e, _ := r.cached("deeeet.com")
+		return e.interval
+	}
+	if got := interval(); got != 4*time.Second {
+		t.Fatalf("got %v; want to start at the refresh interval", got)
+	}
+
+	// Stable hosts are refreshed less often up to the maximum.
+	for _, want := range []time.Duration{8 * time.Second, 16 * time.Second, 16 * time.Second} {
+		before := lookups
+		clock.Advance(interval() - time.Second)
+		r.refresh(context.Background(), true)
+		if lookups != before {
+			t.Fatalf("expect not to be refreshed before the interval")
+		}
+		clock.Advance(time.Second)
+		r.refresh(context.Background(), true)
+		if lookups != before+1 {
+			t.Fatalf("expect to be refreshed after the interval")
+		}
+		if got := interval(); got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	}
+
+	// Volatile hosts are refreshed more often down to the minimum.
+	for i, want := range []time.Duration{8 * time.Second, 4 * time.Second, 2 * time.Second, time.Second, time.Second} {
+		ip = "127.0.0." + string(rune('2'+i))
+		clock.Advance(interval())
+		r.refresh(context.Background(), true)
+		if got := interval(); got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package dnscache
+
+import (
+	"net"
+	"net/netip"
+	"sort"
+)
+
+// sourceAddr returns the source address the system would use to reach dst.
+// Connecting a UDP socket does not send any packet.
+// This is used to replace source address selection when test.
+var sourceAddr = func(dst net.IP) (net.IP, bool) {
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: dst, Port: 9})
+	if err != nil {
+		return nil, false
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP, true
+}
+
+// sortByRFC6724 sorts ips in place by the destination address selection rules
+// of RFC 6724, section 6. Rules 3, 4 and 7 are not applied because it can not
+// be known whether an address is deprecated, a home address or native.
+func sortByRFC6724(ips []net.IP) {
+	if len(ips) < 2 {
+		return
+	}
+
+	attrs := make([]addrAttr, len(ips))
+	for i, ip := range ips {
+		attrs[i] = newAddrAttr(ip)
+	}
+	sort.Stable(&byRFC6724{ips: ips, attrs: attrs})
+}
+
+// addrAttr holds the attributes of a destination and its source address used
+// for sorting.
+type addrAttr struct {
+	dst, src  netip.Addr
+	usable    bool
+	dstPolicy policyEntry
+	srcPolicy policyEntry
+}
+
+func newAddrAttr(ip net.IP) addrAttr {
+	dst, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return addrAttr{}
+	}
+
+	a := addrAttr{dst: dst.Unmap(), dstPolicy: classifyPolicy(dst)}
+	if srcIP, ok := sourceAddr(ip); ok {
+		if src, ok := netip.AddrFromSlice(srcIP); ok {
+			a.src = src.Unmap()
+			a.srcPolicy = classifyPolicy(src)
+			a.usable = true
+		}
+	}
+	return a
+}
+
+type byRFC6724 struct {
+	ips   []net.IP
+	attrs []addrAttr
+}
+
+func (s *byRFC6724) Len() int { return len(s.ips) }
+
+func (s *byRFC6724) Swap(i, j int) {
+	s.ips[i], s.ips[j] = s.ips[j], s.ips[i]
+	s.attrs[i], s.attrs[j] = s.attrs[j], s.attrs[i]
+}
+
+// Less reports whether ips[i] is preferred over ips[j].
+func (s *byRFC6724) Less(i, j int) bool {
+	a, b := s.attrs[i], s.attrs[j]
+
+	// Rule 1: Avoid unusable destinations.
+	if a.usable != b.usable {
+		return a.usable
+	}
+	if !a.usable {
+		return false
+	}
+
+	// Rule 2: Prefer matching scope.
+	aScope, bScope := addrScope(a.dst), addrScope(b.dst)
+	aMatch := aScope == addrScope(a.src)
+	bMatch := bScope == addrScope(b.src)
+	if aMatch != bMatch {
+		return aMatch
+	}
+
+	// Rule 5: Prefer matching label.
+	aMatch = a.dstPolicy.label == a.srcPolicy.label
+	bMatch = b.dstPolicy.label == b.srcPolicy.label
+	if aMatch != bMatch {
+		return aMatch
+	}
+
+	// Rule 6: Prefer higher precedence.
+	if a.dstPolicy.precedence != b.dstPolicy.precedence {
+		return a.dstPolicy.precedence > b.dstPolicy.precedence
+	}
+
+	// Rule 8: Prefer smaller scope.
+	if aScope != bScope {
+		return aScope < bScope
+	}
+
+	// Rule 9: Use longest matching prefix. Like other implementations, this is
+	// only applied between addresses of the same family.
+	if a.dst.Is4() == b.dst.Is4() {
+		aPrefix, bPrefix := commonPrefixLen(a.dst, a.src), commonPrefixLen(b.dst, b.src)
+		if aPrefix != bPrefix {
+			return aPrefix > bPrefix
+		}
+	}
+
+	// Rule 10: Otherwise, leave the order unchanged.
+	return false
+}
+
+// policyEntry is an entry of the policy table of RFC 6724, section 2.1.
+type policyEntry struct {
+	prefix     netip.Prefix
+	precedence uint8
+	label      uint8
+}
+
+// policyTable is the default policy table ordered from the longest prefix.
+var policyTable = []policyEntry{
+	{netip.MustParsePrefix("::1/128"), 50, 0},
+	{netip.MustParsePrefix("::ffff:0:0/96"), 35, 4},
+	{netip.MustParsePrefix("::/96"), 1, 3},
+	{netip.MustParsePrefix("2001::/32"), 5, 5},
+	{netip.MustParsePrefix("2002::/16"), 30, 2},
+	{netip.MustParsePrefix("3ffe::/16"), 1, 12},
+	{netip.MustParsePrefix("fec0::/10"), 1, 11},
+	{netip.MustParsePrefix("fc00::/7"), 3, 13},
+	{netip.MustParsePrefix("::/0"), 40, 1},
+}
+
+// classifyPolicy returns the policy table entry of the address. IPv4 addresses
+// are classified as IPv4-mapped IPv6 addresses.
+func classifyPolicy(addr netip.Addr) policyEntry {
+	if addr.Is4() {
+		addr = netip.AddrFrom16(addr.As16())
+	}
+	for _, p := range policyTable {
+		if p.prefix.Contains(addr) {
+			return p
+		}
+	}
+	return policyEntry{}
+}
+
+// siteLocalPrefix is the deprecated IPv6 site-local prefix.
+var siteLocalPrefix = netip.MustParsePrefix("fec0::/10")
+
+// Address scopes of RFC 4291, section 2.7.
+const (
+	scopeInterfaceLocal = 0x1
+	scopeLinkLocal      = 0x2
+	scopeSiteLocal      = 0x5
+	scopeGlobal         = 0xe
+)
+
+// addrScope returns the scope of the address as defined by RFC 6724, section 3.
+func addrScope(addr netip.Addr) uint8 {
+	switch {
+	case addr.Is4():
+		// IPv4 loopback and link-local addresses have link-local scope (section 3.2).
+		if addr.IsLoopback() || addr.IsLinkLocalUnicast() {
+			return scopeLinkLocal
+		}
+		return scopeGlobal
+	case addr.IsMulticast():
+		return addr.As16()[1] & 0xf
+	case addr.IsLoopback(), addr.IsLinkLocalUnicast():
+		return scopeLinkLocal
+	case siteLocalPrefix.Contains(addr):
+		return scopeSiteLocal
+	case !addr.IsValid():
+		return scopeInterfaceLocal
+	default:
+		return scopeGlobal
+	}
+}
+
+// commonPrefixLen returns the length of the common prefix of two addresses of
+// the same family. For IPv6, only the first 64 bits are compared.
+func commonPrefixLen(a, b netip.Addr) int {
+	if a.Is4() != b.Is4() {
+		return 0
+	}
+
+	x, y := a.AsSlice(), b.AsSlice()
+	if len(x) == net.IPv6len {
+		x, y = x[:8], y[:8]
+	}
+
+	n := 0
+	for i := range x {
+		d := x[i] ^ y[i]
+		if d == 0 {
+			n += 8
+			continue
+		}
+		for d&0x80 == 0 {
+			n++
+			d <<= 1
+		}
+		break
+	}
+	return n
+}
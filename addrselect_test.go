@@ -0,0 +1,110 @@
+package dnscache
+
+import (
+	"net"
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestSortByRFC6724(t *testing.T) {
+	origFunc := sourceAddr
+	defer func() {
+		sourceAddr = origFunc
+	}()
+
+	cases := []struct {
+		name string
+		srcs map[string]string // destination to source address
+		ips  []string
+		want []string
+	}{
+		{
+			name: "prefer usable destination",
+			srcs: map[string]string{"198.51.100.121": "198.51.100.117"},
+			ips:  []string{"2001:db8:1::1", "198.51.100.121"},
+			want: []string{"198.51.100.121", "2001:db8:1::1"},
+		},
+		{
+			name: "prefer higher precedence",
+			srcs: map[string]string{
+				"2001:db8:1::1":  "2001:db8:1::2",
+				"198.51.100.121": "198.51.100.117",
+			},
+			ips:  []string{"198.51.100.121", "2001:db8:1::1"},
+			want: []string{"2001:db8:1::1", "198.51.100.121"},
+		},
+		{
+			name: "prefer matching scope",
+			srcs: map[string]string{
+				"2001:db8:1::1":  "fe80::1",
+				"198.51.100.121": "198.51.100.117",
+			},
+			ips:  []string{"2001:db8:1::1", "198.51.100.121"},
+			want: []string{"198.51.100.121", "2001:db8:1::1"},
+		},
+		{
+			name: "prefer smaller scope",
+			srcs: map[string]string{
+				"2001:db8:1::1": "2001:db8:1::2",
+				"fe80::1":       "fe80::2",
+			},
+			ips:  []string{"2001:db8:1::1", "fe80::1"},
+			want: []string{"fe80::1", "2001:db8:1::1"},
+		},
+		{
+			name: "use longest matching prefix",
+			srcs: map[string]string{
+				"2001:db8:1::1": "2001:db8:3::2",
+				"2001:db8:3::1": "2001:db8:3::2",
+			},
+			ips:  []string{"2001:db8:1::1", "2001:db8:3::1"},
+			want: []string{"2001:db8:3::1", "2001:db8:1::1"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			sourceAddr = func(dst net.IP) (net.IP, bool) {
+				src, ok := tc.srcs[dst.String()]
+				if !ok {
+					return nil, false
+				}
+				return net.ParseIP(src), true
+			}
+
+			ips := make([]net.IP, len(tc.ips))
+			for i, ip := range tc.ips {
+				ips[i] = net.ParseIP(ip)
+			}
+			sortByRFC6724(ips)
+
+			got := make([]string, len(ips))
+			for i, ip := range ips {
+				got[i] = ip.String()
+			}
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Fatalf("want %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestAddrScope(t *testing.T) {
+	cases := map[string]uint8{
+		"127.0.0.1":   scopeLinkLocal,
+		"169.254.1.1": scopeLinkLocal,
+		"10.0.0.1":    scopeGlobal,
+		"::1":         scopeLinkLocal,
+		"fe80::1":     scopeLinkLocal,
+		"fec0::1":     scopeSiteLocal,
+		"ff05::1":     scopeSiteLocal,
+		"2001:db8::1": scopeGlobal,
+	}
+
+	for addr, want := range cases {
+		if got := addrScope(netip.MustParseAddr(addr)); got != want {
+			t.Fatalf("%s: got scope %d, want %d", addr, got, want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package dnscache
+
+import (
+	"sync"
+	"time"
+)
+
+// WithRefreshBackoff makes background refreshes skip a host which keeps failing
+// with exponential backoff, starting from base after the first failure and
+// doubling with every consecutive failure up to max, rather than looking it up at
+// every tick forever. The cached IPs of the host are kept while backing off. A
+// successful lookup of the host resets the backoff. Explicit refreshes are not
+// affected.
+func WithRefreshBackoff(base, max time.Duration) Option {
+	return Option{apply: func(r *Resolver) {
+		if base < 0 || max < 0 {
+			r.invalid("WithRefreshBackoff", "negative delay")
+			return
+		}
+		if base > 0 {
+			if max < base {
+				max = base
+			}
+			r.backoff = &refreshBackoff{base: base, max: max, hosts: make(map[string]*backoffState)}
+		}
+	}}
+}
+
+// refreshBackoff tracks the consecutive refresh failures per host.
+type refreshBackoff struct {
+	base time.Duration
+	max  time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*backoffState
+}
+
+type backoffState struct {
+	failures int
+	next     time.Time
+}
+
+// allow reports whether host may be refreshed at now.
+func (b *refreshBackoff) allow(host string, now time.Time) bool {
+	if b == nil {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, ok := b.hosts[host]
+	return !ok || !now.Before(s.next)
+}
+
+// fail records a refresh failure of host at now and schedules the next attempt.
+func (b *refreshBackoff) fail(host string, now time.Time) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, ok := b.hosts[host]
+	if !ok {
+		s = &backoffState{}
+		b.hosts[host] = s
+	}
+	s.failures++
+
+	d := b.base
+	for i := 1; i < s.failures && d < b.max; i++ {
+		d *= 2
+	}
+	if d > b.max {
+		d = b.max
+	}
+	s.next = now.Add(d)
+}
+
+// reset forgets the failures of host.
+func (b *refreshBackoff) reset(host string) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.hosts, host)
+}
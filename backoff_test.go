@@ -0,0 +1,72 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRefreshBackoff(t *testing.T) {
+	clock := newFakeClock()
+	failing := true
+	lookups := 0
+	r := &Resolver{
+		cache: map[string]*entry{
+			"deeeet.com": {ips: []net.IP{net.ParseIP("127.0.0.1")}},
+		},
+		lookupTimeout: time.Second,
+		lookupIPFn: func(ctx context.Context, network, host string) ([]net.IP, error) {
+			lookups++
+			if failing {
+				return nil, errors.New("lookup failed")
+			}
+			return []net.IP{net.ParseIP("127.0.0.1")}, nil
+		},
+		defaultLookupTimeout: time.Second,
+		clock:                clock,
+		logger:               slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	WithRefreshBackoff(time.Second, 4*time.Second).apply(r)
+
+	cases := []struct {
+		after time.Duration
+		want  int
+	}{
+		{0, 1},                      // fails and backs off 1s
+		{500 * time.Millisecond, 1}, // skipped
+		{500 * time.Millisecond, 2}, // fails and backs off 2s
+		{time.Second, 2},            // skipped
+		{time.Second, 3},            // fails and backs off 4s
+		{4 * time.Second, 4},        // fails and backs off 4s, the max
+		{3 * time.Second, 4},        // skipped
+		{time.Second, 5},            // fails
+	}
+	for i, tc := range cases {
+		clock.Advance(tc.after)
+		r.refresh(context.Background(), true)
+		if lookups != tc.want {
+			t.Fatalf("#%d: got %d lookups; want %d", i, lookups, tc.want)
+		}
+	}
+
+	// Explicit refreshes are not affected.
+	r.Refresh()
+	if lookups != 6 {
+		t.Fatalf("got %d lookups; want 6", lookups)
+	}
+
+	// A success resets the backoff.
+	failing = false
+	clock.Advance(4 * time.Second)
+	r.refresh(context.Background(), true)
+	failing = true
+	clock.Advance(time.Millisecond)
+	r.refresh(context.Background(), true)
+	if lookups != 8 {
+		t.Fatalf("got %d lookups; want 8", lookups)
+	}
+}
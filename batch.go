@@ -0,0 +1,102 @@
+package dnscache
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// batchConcurrency is the maximum number of concurrent lookups of LookupIPs.
+const batchConcurrency = 16
+
+// BatchError is returned by LookupIPs when some of the hosts failed to be looked up.
+type BatchError struct {
+	// Errors holds the lookup error of each failed host.
+	Errors map[string]error
+}
+
+// Error returns the errors of the failed hosts in host order.
+func (e *BatchError) Error() string {
+	hosts := make([]string, 0, len(e.Errors))
+	for host := range e.Errors {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	msgs := make([]string, len(hosts))
+	for i, host := range hosts {
+		msgs[i] = fmt.Sprintf("%s: %s", host, e.Errors[host])
+	}
+	return fmt.Sprintf("dnscache: failed to look up %d hosts: %s", len(hosts), strings.Join(msgs, "; "))
+}
+
+// LookupIPs lookups IP lists of the given hosts concurrently like LookupIP and saves
+// the results in the cache. It returns the IP lists of the hosts which succeeded even
+// if others failed, in which case the error is a *BatchError holding the error of
+// each failed host.
+func (r *Resolver) LookupIPs(ctx context.Context, hosts []string) (map[string][]net.IP, error) {
+	var (
+		mu      sync.Mutex
+		results = make(map[string][]net.IP, len(hosts))
+		errs    = make(map[string]error)
+		wg      sync.WaitGroup
+		sem     = make(chan struct{}, batchConcurrency)
+	)
+
+	for _, host := range hosts {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(host string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			ips, err := r.LookupIP(ctx, host)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs[host] = err
+				return
+			}
+			results[host] = ips
+		}(host)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return results, &BatchError{Errors: errs}
+	}
+	return results, nil
+}
+
+// WithWarmup makes New look up the given hosts by LookupIPs synchronously before
+// returning, so that a service can ensure that its critical upstreams are cached
+// before serving traffic. The whole warm-up is bounded by timeout, or by the
+// lookup timeout when it is not positive. New fails with the *BatchError if any
+// of the hosts fails to be looked up.
+func WithWarmup(hosts []string, timeout time.Duration) Option {
+	return Option{apply: func(r *Resolver) {
+		r.invalidDuration("WithWarmup", "timeout", timeout)
+		r.warmup = append(r.warmup, hosts...)
+		r.warmupTimeout = timeout
+	}}
+}
+
+// warmUp looks up the hosts given by WithWarmup.
+func (r *Resolver) warmUp() error {
+	timeout := r.warmupTimeout
+	if timeout <= 0 {
+		timeout = r.lookupTimeout
+	}
+	ctx, cancelF := context.WithTimeout(context.Background(), timeout)
+	defer cancelF()
+
+	_, err := r.LookupIPs(ctx, r.warmup)
+	return err
+}
@@ -0,0 +1,81 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLookupIPs(t *testing.T) {
+	originalFunc := lookupIP
+	defer func() {
+		lookupIP = originalFunc
+	}()
+
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		if host == "broken.io" {
+			return nil, fmt.Errorf("err")
+		}
+		return []net.IP{net.IP(host)}, nil
+	}
+
+	resolver := testResolver(t)
+	defer resolver.Stop()
+
+	hosts := []string{"broken.io"}
+	want := make(map[string][]net.IP)
+	for i := 0; i < 50; i++ {
+		host := fmt.Sprintf("host%d.io", i)
+		hosts = append(hosts, host)
+		want[host] = []net.IP{net.IP(host)}
+	}
+
+	got, err := resolver.LookupIPs(context.Background(), hosts)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("got error %v, want *BatchError", err)
+	}
+	if _, ok := batchErr.Errors["broken.io"]; !ok || len(batchErr.Errors) != 1 {
+		t.Fatalf("got errors %v, want only broken.io", batchErr.Errors)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+
+	// All succeeded hosts are cached.
+	if got, want := len(resolver.cache), 50; got != want {
+		t.Fatalf("got %d cache entries, want %d", got, want)
+	}
+}
+
+func TestWarmup(t *testing.T) {
+	originalFunc := lookupIP
+	defer func() {
+		lookupIP = originalFunc
+	}()
+
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		if host == "broken.io" {
+			return nil, fmt.Errorf("err")
+		}
+		return []net.IP{net.ParseIP("127.0.0.1")}, nil
+	}
+
+	resolver, err := New(testFreq, testDefaultLookupTimeout, WithWarmup([]string{"a.io", "b.io"}, time.Second))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resolver.Stop()
+	if got := resolver.Len(); got != 2 {
+		t.Fatalf("got %d; want the hosts to be cached", got)
+	}
+
+	var batchErr *BatchError
+	if _, err := New(testFreq, testDefaultLookupTimeout, WithWarmup([]string{"a.io", "broken.io"}, time.Second)); !errors.As(err, &batchErr) {
+		t.Fatalf("got error %v, want *BatchError", err)
+	}
+}
@@ -0,0 +1,34 @@
+package dnscache
+
+// maxDialHosts is the maximum number of hosts whose state is kept by a dial
+// option, e.g. the next IP of WithRoundRobin.
+const maxDialHosts = 1024
+
+// makeRoom deletes keys of m, which holds at most max keys, so that a new key can
+// be added. The keys for which stale reports true are deleted first, and then
+// arbitrary ones, to bound the memory. stale may be nil.
+func makeRoom[K comparable, V any](m map[K]V, max int, stale func(K) bool) {
+	if len(m) < max {
+		return
+	}
+	if stale != nil {
+		for key := range m {
+			if stale(key) {
+				delete(m, key)
+			}
+		}
+	}
+	for key := range m {
+		if len(m) < max {
+			return
+		}
+		delete(m, key)
+	}
+}
+
+// cacheAware is implemented by the selectors of this package which keep state
+// per host, so that they can drop the state of the hosts which are no longer
+// cached when they are full.
+type cacheAware interface {
+	setCached(cached func(host string) bool)
+}
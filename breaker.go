@@ -0,0 +1,106 @@
+package dnscache
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// breaker tracks dial outcomes per host and IP and skips the IPs which keep
+// failing for a while.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	// cached reports whether a host is still cached, so that the states of the
+	// hosts which are not are dropped first when states is full.
+	cached func(host string) bool
+
+	// mu guards states, which holds the states of up to maxDialHosts hosts.
+	mu     sync.Mutex
+	states map[string]map[string]*breakerState
+}
+
+// breakerState is the dial health of a single IP of a host. The circuit is open
+// while failures reaches threshold and openUntil is not passed.
+type breakerState struct {
+	failures  int
+	openUntil time.Time
+}
+
+// allow returns the IPs which may be dialed, keeping their order. An IP whose
+// cooldown has passed is moved to the front and let through once as a probe
+// (half-open); its cooldown is re-armed until the outcome of the probe is
+// recorded. When every IP is open, all of them are returned since dialing them
+// is better than failing without trying. t is the current time. The states of the
+// IPs which are no longer in ips are dropped.
+func (b *breaker) allow(host string, ips []net.IP, t time.Time) []net.IP {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	states := b.states[host]
+	for key := range states {
+		if !containsIP(ips, net.ParseIP(key)) {
+			delete(states, key)
+		}
+	}
+	if len(states) == 0 {
+		delete(b.states, host)
+		return ips
+	}
+
+	var probes, closed []net.IP
+	for _, ip := range ips {
+		s, ok := states[ip.String()]
+		switch {
+		case !ok || s.failures < b.threshold:
+			closed = append(closed, ip)
+		case !t.Before(s.openUntil):
+			s.openUntil = t.Add(b.cooldown)
+			probes = append(probes, ip)
+		}
+	}
+
+	allowed := append(probes, closed...)
+	if len(allowed) == 0 {
+		return ips
+	}
+	return allowed
+}
+
+// record updates the health of the IP with the outcome of a dial at now.
+func (b *breaker) record(host string, ip string, err error, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		if states := b.states[host]; states != nil {
+			delete(states, ip)
+			if len(states) == 0 {
+				delete(b.states, host)
+			}
+		}
+		return
+	}
+
+	if b.states == nil {
+		b.states = make(map[string]map[string]*breakerState)
+	}
+	states := b.states[host]
+	if states == nil {
+		makeRoom(b.states, maxDialHosts, func(host string) bool {
+			return b.cached != nil && !b.cached(host)
+		})
+		states = make(map[string]*breakerState)
+		b.states[host] = states
+	}
+	s := states[ip]
+	if s == nil {
+		s = &breakerState{}
+		states[ip] = s
+	}
+	s.failures++
+	if s.failures >= b.threshold {
+		s.openUntil = now.Add(b.cooldown)
+	}
+}
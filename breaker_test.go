@@ -0,0 +1,115 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	current := time.Unix(1000, 0)
+
+	ips := []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("127.0.0.2")}
+	b := &breaker{threshold: 2, cooldown: time.Minute}
+	errDial := errors.New("connection refused")
+
+	b.record("deeeet.com", "127.0.0.1", errDial, current)
+	if got := b.allow("deeeet.com", ips, current); !reflect.DeepEqual(ips, got) {
+		t.Fatalf("expect IP below threshold to be allowed, got %v", got)
+	}
+
+	b.record("deeeet.com", "127.0.0.1", errDial, current)
+	if got, want := b.allow("deeeet.com", ips, current), ips[1:]; !reflect.DeepEqual(want, got) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+
+	// After the cooldown, the IP is probed once first.
+	current = current.Add(time.Minute)
+	if got, want := b.allow("deeeet.com", ips, current), ips; !reflect.DeepEqual(want, got) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+	if got, want := b.allow("deeeet.com", ips, current), ips[1:]; !reflect.DeepEqual(want, got) {
+		t.Fatalf("expect only one probe per cooldown, want %v, got %v", want, got)
+	}
+
+	// A successful probe closes the circuit.
+	b.record("deeeet.com", "127.0.0.1", nil, current)
+	if got := b.allow("deeeet.com", ips, current); !reflect.DeepEqual(ips, got) {
+		t.Fatalf("want %v, got %v", ips, got)
+	}
+}
+
+func TestBreakerAllOpen(t *testing.T) {
+	current := time.Unix(1000, 0)
+	ips := []net.IP{net.ParseIP("127.0.0.1")}
+	b := &breaker{threshold: 1, cooldown: time.Minute}
+	b.record("deeeet.com", "127.0.0.1", errors.New("connection refused"), current)
+
+	if got := b.allow("deeeet.com", ips, current); !reflect.DeepEqual(ips, got) {
+		t.Fatalf("expect all IPs to be dialed when every circuit is open, got %v", got)
+	}
+}
+
+func TestBreakerPrune(t *testing.T) {
+	current := time.Unix(1000, 0)
+	errDial := errors.New("connection refused")
+	b := &breaker{threshold: 1, cooldown: time.Minute, cached: func(host string) bool {
+		return host != "removed.mercari.io"
+	}}
+
+	// The state of an IP which dropped out of the answer is dropped.
+	b.record("deeeet.com", "127.0.0.1", errDial, current)
+	b.allow("deeeet.com", []net.IP{net.ParseIP("127.0.0.2")}, current)
+	if _, ok := b.states["deeeet.com"]; ok {
+		t.Fatalf("expect the state of the IP no longer cached to be dropped")
+	}
+
+	// The hosts which are no longer cached are dropped first when full.
+	b.record("removed.mercari.io", "127.0.0.1", errDial, current)
+	for i := 0; i < maxDialHosts; i++ {
+		b.record(fmt.Sprintf("%d.mercari.io", i), "127.0.0.1", errDial, current)
+	}
+	if got := len(b.states); got != maxDialHosts {
+		t.Fatalf("got %d hosts; want %d", got, maxDialHosts)
+	}
+	if _, ok := b.states["removed.mercari.io"]; ok {
+		t.Fatalf("expect the host which is no longer cached to be dropped first")
+	}
+}
+
+func TestDialFuncCircuitBreaker(t *testing.T) {
+	resolver := &Resolver{
+		cache: map[string]*entry{
+			"deeeet.com": {ips: []net.IP{
+				net.ParseIP("127.0.0.1"),
+				net.ParseIP("127.0.0.2"),
+			}},
+		},
+		lookupTimeout: time.Second,
+	}
+
+	var dialed []string
+	dialF := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		if addr == "127.0.0.1:443" {
+			return nil, errors.New("connection refused")
+		}
+		return nil, nil
+	}
+
+	dial := DialFunc(resolver, dialF, WithRoundRobin(), WithCircuitBreaker(1, time.Minute))
+	for i := 0; i < 4; i++ {
+		if _, err := dial(context.Background(), "tcp", "deeeet.com:443"); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	want := []string{"127.0.0.1:443", "127.0.0.2:443", "127.0.0.2:443", "127.0.0.2:443", "127.0.0.2:443"}
+	if !reflect.DeepEqual(want, dialed) {
+		t.Fatalf("want %v, got %v", want, dialed)
+	}
+}
@@ -0,0 +1,101 @@
+package dnscache
+
+import (
+	"net"
+	"sort"
+	"sync"
+)
+
+// listener is called with the old and the new IP set of a host which changed.
+type listener func(host string, old, new []net.IP)
+
+// listeners is the list of listeners of IP set changes.
+type listeners struct {
+	mu   sync.Mutex
+	list []*listener
+}
+
+// add adds the listener and returns the function to remove it.
+func (ls *listeners) add(fn listener) (remove func()) {
+	l := &fn
+
+	ls.mu.Lock()
+	ls.list = append(ls.list, l)
+	ls.mu.Unlock()
+
+	return func() {
+		ls.mu.Lock()
+		defer ls.mu.Unlock()
+		for i := range ls.list {
+			if ls.list[i] == l {
+				ls.list = append(ls.list[:i:i], ls.list[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// notify calls the listeners synchronously.
+func (ls *listeners) notify(host string, old, new []net.IP) {
+	ls.mu.Lock()
+	list := ls.list
+	ls.mu.Unlock()
+
+	for _, l := range list {
+		(*l)(host, old, new)
+	}
+}
+
+// sameIPs reports whether a and b are the same set of IPs regardless of order.
+func sameIPs(a, b []net.IP) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	x, y := ipStrings(a), ipStrings(b)
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func ipStrings(ips []net.IP) []string {
+	s := make([]string, len(ips))
+	for i, ip := range ips {
+		s[i] = ip.String()
+	}
+	sort.Strings(s)
+	return s
+}
+
+// OnChange registers fn to be called like the function of WithOnChange, for
+// users which get a resolver created elsewhere, e.g. integrations. Call the
+// returned function to unregister it.
+func (r *Resolver) OnChange(fn func(host string, old, new []net.IP)) (remove func()) {
+	return r.listeners.add(fn)
+}
+
+// BindTransport makes the resolver call CloseIdleConnections of the transport,
+// e.g. `http.Transport` or `http.Client`, whenever the IP set of a cached host
+// changes, so that keep-alive connections do not keep pinning traffic to the
+// backends which were removed from DNS. All idle connections of the transport
+// are closed, since it can not close those of a single host. Connections in use
+// are not affected. Call the returned function to unbind the transport.
+func (r *Resolver) BindTransport(t interface{ CloseIdleConnections() }) (unbind func()) {
+	return r.listeners.add(func(host string, old, new []net.IP) {
+		t.CloseIdleConnections()
+	})
+}
+
+// WithOnChange sets a function called with the old and the new IPs whenever the
+// IP set of a cached host actually changes, e.g. to rotate connection pools
+// exactly when backends move. Lookups returning the same IPs in a different
+// order are not changes, and neither is the first lookup of a host. It is called
+// synchronously from the lookup which detected the change.
+func WithOnChange(fn func(host string, old, new []net.IP)) Option {
+	return Option{apply: func(r *Resolver) {
+		r.listeners.add(fn)
+	}}
+}
@@ -0,0 +1,76 @@
+package dnscache
+
+import (
+	"net"
+	"testing"
+)
+
+type testTransport struct {
+	closed int
+}
+
+func (t *testTransport) CloseIdleConnections() {
+	t.closed++
+}
+
+func TestBindTransport(t *testing.T) {
+	r := &Resolver{cache: map[string]*entry{}}
+	tr := &testTransport{}
+	unbind := r.BindTransport(tr)
+
+	// The first lookup of a host is not a change.
+	r.store("deeeet.com", &entry{ips: []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("127.0.0.2")}})
+	if tr.closed != 0 {
+		t.Fatalf("expect idle connections not to be closed")
+	}
+
+	// A different order is not a change either.
+	r.store("deeeet.com", &entry{ips: []net.IP{net.ParseIP("127.0.0.2"), net.ParseIP("127.0.0.1")}})
+	if tr.closed != 0 {
+		t.Fatalf("expect idle connections not to be closed")
+	}
+
+	r.store("deeeet.com", &entry{ips: []net.IP{net.ParseIP("127.0.0.3")}})
+	if tr.closed != 1 {
+		t.Fatalf("expect idle connections to be closed once, got %d", tr.closed)
+	}
+
+	unbind()
+	r.store("deeeet.com", &entry{ips: []net.IP{net.ParseIP("127.0.0.4")}})
+	if tr.closed != 1 {
+		t.Fatalf("expect unbound transport not to be called, got %d", tr.closed)
+	}
+}
+
+func TestSameIPs(t *testing.T) {
+	a := []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("::1")}
+	if !sameIPs(a, []net.IP{net.ParseIP("::1"), net.ParseIP("127.0.0.1")}) {
+		t.Fatalf("expect to be same")
+	}
+	if sameIPs(a, []net.IP{net.ParseIP("::1"), net.ParseIP("127.0.0.2")}) || sameIPs(a, a[:1]) {
+		t.Fatalf("expect not to be same")
+	}
+}
+
+func TestWithOnChange(t *testing.T) {
+	type change struct {
+		host     string
+		old, new []net.IP
+	}
+	var changes []change
+	r := &Resolver{cache: map[string]*entry{}}
+	WithOnChange(func(host string, old, new []net.IP) {
+		changes = append(changes, change{host, old, new})
+	}).apply(r)
+
+	r.store("deeeet.com", &entry{ips: []net.IP{net.ParseIP("127.0.0.1")}})
+	r.store("deeeet.com", &entry{ips: []net.IP{net.ParseIP("127.0.0.1")}})
+	r.store("deeeet.com", &entry{ips: []net.IP{net.ParseIP("127.0.0.2")}})
+
+	if len(changes) != 1 {
+		t.Fatalf("got %d changes; want 1", len(changes))
+	}
+	if c := changes[0]; c.host != "deeeet.com" || !c.old[0].Equal(net.ParseIP("127.0.0.1")) || !c.new[0].Equal(net.ParseIP("127.0.0.2")) {
+		t.Fatalf("got %+v", c)
+	}
+}
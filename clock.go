@@ -0,0 +1,72 @@
+package dnscache
+
+import "time"
+
+// Clock is the source of time of a resolver. It drives the background refresh
+// loop and the age of cached entries, so that they can be controlled by a fake
+// clock in tests.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// NewTicker returns a ticker which ticks every d.
+	NewTicker(d time.Duration) Ticker
+
+	// After waits for d and then sends the current time on the returned channel.
+	After(d time.Duration) <-chan time.Time
+}
+
+// Ticker is a ticker returned by Clock.NewTicker, which behaves like
+// `time.Ticker`.
+type Ticker interface {
+	// C returns the channel on which the ticks are delivered.
+	C() <-chan time.Time
+
+	// Reset stops the ticker and resets its period to d.
+	Reset(d time.Duration)
+
+	// Stop turns off the ticker.
+	Stop()
+}
+
+// WithClock sets the clock of the resolver, used for the refresh ticker, entry
+// ages, backoffs, the fallback delay of WithHappyEyeballs and timestamps of
+// events, history and errors. The default is the system clock.
+func WithClock(c Clock) Option {
+	return Option{apply: func(r *Resolver) {
+		if c != nil {
+			r.clock = c
+		}
+	}}
+}
+
+// systemClock is the Clock of the time package.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) NewTicker(d time.Duration) Ticker { return systemTicker{time.NewTicker(d)} }
+
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+type systemTicker struct {
+	*time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time { return t.Ticker.C }
+
+// now returns the current time of the clock of the resolver.
+func (r *Resolver) now() time.Time {
+	if r.clock == nil {
+		return time.Now()
+	}
+	return r.clock.Now()
+}
+
+// after is Clock.After of the clock of the resolver.
+func (r *Resolver) after(d time.Duration) <-chan time.Time {
+	if r.clock == nil {
+		return time.After(d)
+	}
+	return r.clock.After(d)
+}
@@ -0,0 +1,189 @@
+package dnscache
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock which only moves forward by Advance.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	c  chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(0, 0)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) Ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTicker{clock: c, c: make(chan time.Time, 1), period: d, next: c.now.Add(d)}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), c: ch})
+	return ch
+}
+
+// Advance moves the clock forward by d and fires the tickers and waiters which
+// are due. Like `time.Ticker`, ticks are dropped for slow receivers.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	for _, t := range c.tickers {
+		if t.stopped || c.now.Before(t.next) {
+			continue
+		}
+		select {
+		case t.c <- c.now:
+		default:
+		}
+		t.next = c.now.Add(t.period)
+	}
+
+	waiters := c.waiters[:0]
+	for _, w := range c.waiters {
+		if c.now.Before(w.at) {
+			waiters = append(waiters, w)
+			continue
+		}
+		w.c <- c.now
+	}
+	c.waiters = waiters
+}
+
+type fakeTicker struct {
+	clock   *fakeClock
+	c       chan time.Time
+	period  time.Duration
+	next    time.Time
+	stopped bool
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.c }
+
+func (t *fakeTicker) Reset(d time.Duration) {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.period, t.next, t.stopped = d, t.clock.now.Add(d), false
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.stopped = true
+}
+
+func TestWithClock(t *testing.T) {
+	originalFunc := lookupIP
+	defer func() {
+		lookupIP = originalFunc
+	}()
+
+	var mu sync.Mutex
+	lookups := 0
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		lookups++
+		return []net.IP{net.ParseIP("127.0.0.1")}, nil
+	}
+
+	clock := newFakeClock()
+	r, err := New(time.Minute, time.Second, WithClock(clock), WithOnDemand(time.Hour), WithHistory(2))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer r.Stop()
+	events := r.Events()
+
+	if _, err := r.Fetch(context.Background(), "deeeet.com"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if e, _ := r.cached("deeeet.com"); !e.updated.Equal(clock.Now()) {
+		t.Fatalf("got %v; want the entry to be stored at %v", e.updated, clock.Now())
+	}
+	if e := <-events; !e.Time.Equal(clock.Now()) {
+		t.Fatalf("got %v; want the event to be sent at %v", e.Time, clock.Now())
+	}
+	if h := r.History("deeeet.com"); len(h) != 1 || !h[0].Time.Equal(clock.Now()) {
+		t.Fatalf("got %+v; want the lookup to be recorded at %v", h, clock.Now())
+	}
+
+	// The on-demand resolver re-resolves by the age of the clock.
+	clock.Advance(time.Hour)
+	if _, err := r.Fetch(context.Background(), "deeeet.com"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if lookups != 2 {
+		t.Fatalf("got %d lookups; want 2", lookups)
+	}
+}
+
+func TestWithClock_refresh(t *testing.T) {
+	originalFunc := lookupIP
+	defer func() {
+		lookupIP = originalFunc
+	}()
+
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("127.0.0.1")}, nil
+	}
+
+	clock := newFakeClock()
+	refreshed := make(chan RefreshSummary, 1)
+	r, err := New(time.Minute, time.Second,
+		WithClock(clock),
+		WithRefreshListener(func(s RefreshSummary) { refreshed <- s }),
+	)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer r.Stop()
+
+	if _, err := r.Fetch(context.Background(), "deeeet.com"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	clock.Advance(59 * time.Second)
+	select {
+	case <-refreshed:
+		t.Fatalf("expect not to be refreshed before the interval")
+	default:
+	}
+
+	clock.Advance(time.Second)
+	if s := <-refreshed; s.Hosts != 1 {
+		t.Fatalf("got %d hosts; want 1", s.Hosts)
+	}
+	if got := r.DebugState().Stats.LastRefresh; !got.Equal(clock.Now()) {
+		t.Fatalf("got %v; want %v", got, clock.Now())
+	}
+}
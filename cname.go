@@ -0,0 +1,48 @@
+package dnscache
+
+import "context"
+
+// CanonicalName returns the canonical name of addr, that is the final target
+// of its CNAME chain as a FQDN, or addr itself when it has no CNAME.
+//
+// The result is cached together with the IP list of addr and kept fresh by
+// refreshing. With the system resolver, the canonical name is looked up
+// separately on the first call and then on every refresh of addr.
+func (r *Resolver) CanonicalName(ctx context.Context, addr string) (string, error) {
+	if _, ok := r.static[addr]; ok {
+		return fqdn(addr), nil
+	}
+
+	if _, err := r.Fetch(ctx, addr); err != nil {
+		return "", err
+	}
+
+	r.lock.RLock()
+	name := addr
+	if alias, ok := r.aliases[addr]; ok {
+		name = alias
+	}
+	e, ok := r.cache[name]
+	cname := ""
+	if ok {
+		cname = e.cname
+	}
+	r.lock.RUnlock()
+	if cname != "" {
+		return cname, nil
+	}
+
+	cname, err := lookupCNAME(ctx, name)
+	if err != nil {
+		return "", err
+	}
+
+	r.lock.Lock()
+	if e, ok := r.cache[name]; ok {
+		withCNAME := e.clone()
+		withCNAME.cname = cname
+		r.cache[name] = withCNAME
+	}
+	r.lock.Unlock()
+	return cname, nil
+}
@@ -0,0 +1,120 @@
+package dnscache
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+)
+
+func TestCanonicalName(t *testing.T) {
+	originalFunc := lookupIP
+	originalCNAME := lookupCNAME
+	defer func() {
+		lookupIP = originalFunc
+		lookupCNAME = originalCNAME
+	}()
+
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("10.0.0.1")}, nil
+	}
+
+	mu := new(sync.Mutex)
+	cname := "vendor-a.example.net."
+	lookupCNAME = func(ctx context.Context, host string) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return cname, nil
+	}
+
+	resolver := testResolver(t)
+	defer resolver.Stop()
+
+	ctx := context.Background()
+	got, err := resolver.CanonicalName(ctx, "api.mercari.io")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if want := "vendor-a.example.net."; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	// The vendor migrated the endpoint behind the alias.
+	mu.Lock()
+	cname = "vendor-b.example.net."
+	mu.Unlock()
+	resolver.Refresh()
+
+	got, err = resolver.CanonicalName(ctx, "api.mercari.io")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if want := "vendor-b.example.net."; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestCanonicalNameReplacesEntry(t *testing.T) {
+	originalCNAME := lookupCNAME
+	defer func() {
+		lookupCNAME = originalCNAME
+	}()
+	lookupCNAME = func(ctx context.Context, host string) (string, error) {
+		return "vendor-a.example.net.", nil
+	}
+
+	cached := &entry{ips: []net.IP{net.ParseIP("10.0.0.1")}}
+	resolver := &Resolver{cache: map[string]*entry{"api.mercari.io": cached}}
+	if _, err := resolver.CanonicalName(context.Background(), "api.mercari.io"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The cached entry may be read without the lock, so it is replaced rather than
+	// modified.
+	if cached.cname != "" {
+		t.Fatalf("expect the cached entry not to be modified, got %q", cached.cname)
+	}
+	if e, _ := resolver.cached("api.mercari.io"); e == cached || e.cname != "vendor-a.example.net." {
+		t.Fatalf("expect the entry to be replaced by one with the canonical name")
+	}
+}
+
+func TestCanonicalNameNameserver(t *testing.T) {
+	originalCNAME := lookupCNAME
+	defer func() {
+		lookupCNAME = originalCNAME
+	}()
+	lookupCNAME = func(ctx context.Context, host string) (string, error) {
+		t.Fatalf("expect canonical name to be taken from the answer")
+		return "", nil
+	}
+
+	addr := testNameserver(t, func(q *dnsMessage, tcp bool) *dnsMessage {
+		edge, _ := appendName(nil, "edge.mercari.io.")
+		cdn, _ := appendName(nil, "cdn.example.net.")
+		m := &dnsMessage{
+			answers: []dnsRR{
+				{name: "edge.mercari.io.", typ: typeCNAME, class: classINET, ttl: 60, data: cdn},
+				{name: "api.mercari.io.", typ: typeCNAME, class: classINET, ttl: 60, data: edge},
+			},
+		}
+		if q.questions[0].typ == typeA {
+			m.answers = append(m.answers, dnsRR{name: "cdn.example.net.", typ: typeA, class: classINET, ttl: 60, data: []byte{10, 0, 0, 1}})
+		}
+		return m
+	})
+
+	resolver, err := New(testFreq, testDefaultLookupTimeout, WithNameserver(addr))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resolver.Stop()
+
+	got, err := resolver.CanonicalName(context.Background(), "api.mercari.io")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if want := "cdn.example.net."; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,113 @@
+package dnscache
+
+import "time"
+
+// Config is the configuration of a resolver built by NewFromConfig. It can be
+// populated from configuration files or environment variables. The zero value of
+// every field means the default.
+type Config struct {
+	// RefreshInterval is the frequency of background refreshes.
+	RefreshInterval time.Duration `json:"refresh_interval" yaml:"refresh_interval"`
+
+	// LookupTimeout is the timeout of foreground lookups and RefreshTimeout is the
+	// timeout of each lookup of background refreshes. RefreshTimeout defaults to
+	// LookupTimeout.
+	LookupTimeout  time.Duration `json:"lookup_timeout" yaml:"lookup_timeout"`
+	RefreshTimeout time.Duration `json:"refresh_timeout" yaml:"refresh_timeout"`
+
+	// Network is the address family to look up: "ip", "ip4" or "ip6".
+	Network string `json:"network" yaml:"network"`
+
+	// Nameserver is the DNS server to query instead of the system resolver.
+	Nameserver string `json:"nameserver" yaml:"nameserver"`
+
+	// HostsFile is the hosts file consulted before DNS. Set "system" for the
+	// system hosts file.
+	HostsFile string `json:"hosts_file" yaml:"hosts_file"`
+
+	// SearchDomains and Ndots are used for unqualified names. They conflict with
+	// ResolvConf, which reads them and the nameserver from a resolv.conf file.
+	// Set "system" for the system resolv.conf. Ndots is a pointer so that ndots:0
+	// can be set; nil means the default.
+	SearchDomains []string `json:"search_domains" yaml:"search_domains"`
+	Ndots         *int     `json:"ndots" yaml:"ndots"`
+	ResolvConf    string   `json:"resolv_conf" yaml:"resolv_conf"`
+
+	// RetryAttempts, RetryBaseDelay and RetryJitter configure retries of failed
+	// lookups like WithRetry.
+	RetryAttempts  int           `json:"retry_attempts" yaml:"retry_attempts"`
+	RetryBaseDelay time.Duration `json:"retry_base_delay" yaml:"retry_base_delay"`
+	RetryJitter    float64       `json:"retry_jitter" yaml:"retry_jitter"`
+
+	// CacheCapacity is the initial capacity of the cache.
+	CacheCapacity int `json:"cache_capacity" yaml:"cache_capacity"`
+
+	// UnhealthyAfter is the number of consecutive failed refresh cycles after
+	// which Healthy reports an error.
+	UnhealthyAfter int `json:"unhealthy_after" yaml:"unhealthy_after"`
+
+	// Options are applied after the fields above.
+	Options []Option `json:"-" yaml:"-"`
+}
+
+// systemPath is the value of path fields of Config meaning the system file.
+const systemPath = "system"
+
+// checkRefreshInterval records a negative refresh interval of the configuration
+// as invalid, since New takes it as the default.
+func checkRefreshInterval(d time.Duration) Option {
+	return Option{apply: func(r *Resolver) {
+		r.invalidDuration("RefreshInterval", "interval", d)
+	}}
+}
+
+// options returns the options equivalent to the configuration.
+func (c *Config) options() []Option {
+	options := []Option{checkRefreshInterval(c.RefreshInterval)}
+	if c.LookupTimeout != 0 {
+		options = append(options, WithLookupTimeout(c.LookupTimeout))
+	}
+	if c.RefreshTimeout != 0 {
+		options = append(options, WithRefreshTimeout(c.RefreshTimeout))
+	}
+	if c.Network != "" {
+		options = append(options, WithNetwork(c.Network))
+	}
+	if c.Nameserver != "" {
+		options = append(options, WithNameserver(c.Nameserver))
+	}
+	if c.HostsFile == systemPath {
+		options = append(options, WithHostsFile(""))
+	} else if c.HostsFile != "" {
+		options = append(options, WithHostsFile(c.HostsFile))
+	}
+	if len(c.SearchDomains) > 0 || c.Ndots != nil {
+		ndots := defaultNdots
+		if c.Ndots != nil {
+			ndots = *c.Ndots
+		}
+		options = append(options, WithSearchDomains(c.SearchDomains, ndots))
+	}
+	if c.ResolvConf == systemPath {
+		options = append(options, WithResolvConf(""))
+	} else if c.ResolvConf != "" {
+		options = append(options, WithResolvConf(c.ResolvConf))
+	}
+	if c.RetryAttempts != 0 || c.RetryBaseDelay != 0 || c.RetryJitter != 0 {
+		options = append(options, WithRetry(c.RetryAttempts, c.RetryBaseDelay, c.RetryJitter))
+	}
+	if c.CacheCapacity != 0 {
+		options = append(options, WithCacheCapacity(c.CacheCapacity))
+	}
+	if c.UnhealthyAfter != 0 {
+		options = append(options, WithUnhealthyAfter(c.UnhealthyAfter))
+	}
+	return append(options, c.Options...)
+}
+
+// NewFromConfig initializes DNS cache resolver by New with the options equivalent
+// to the configuration, so that New reports every invalid field like invalid
+// options.
+func NewFromConfig(cfg Config) (*Resolver, error) {
+	return New(cfg.RefreshInterval, cfg.LookupTimeout, cfg.options()...)
+}
@@ -0,0 +1,80 @@
+package dnscache
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewFromConfig(t *testing.T) {
+	var cfg Config
+	if err := json.Unmarshal([]byte(`{"lookup_timeout": 2000000000, "network": "ip4", "search_domains": ["deeeet.com"], "retry_attempts": 3}`), &cfg); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	cfg.Options = []Option{WithName("config")}
+
+	r, err := NewFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer r.Stop()
+
+	if r.lookupTimeout != 2*time.Second || r.defaultLookupTimeout != 2*time.Second {
+		t.Fatalf("got %v and %v; want 2s", r.lookupTimeout, r.defaultLookupTimeout)
+	}
+	if r.freq != defaultFreq || r.network != "ip4" || r.ndots != defaultNdots || len(r.search) != 1 || r.retry.attempts != 3 || r.name != "config" {
+		t.Fatalf("got unexpected configuration %+v", r)
+	}
+}
+
+func TestNewFromConfigInvalid(t *testing.T) {
+	cases := map[string]Config{
+		"negative interval": {RefreshInterval: -time.Second},
+		"negative timeout":  {LookupTimeout: -time.Second},
+		"conflict":          {SearchDomains: []string{"deeeet.com"}, ResolvConf: systemPath},
+		"jitter":            {RetryAttempts: 2, RetryJitter: 2},
+		"network":           {Network: "tcp"},
+		"ndots":             {Ndots: ptr(-1)},
+		"capacity":          {CacheCapacity: -1},
+	}
+	for name, cfg := range cases {
+		if r, err := NewFromConfig(cfg); err == nil {
+			r.Stop()
+			t.Errorf("%s: expect to be failed", name)
+		}
+	}
+}
+
+func TestNewFromConfigAllErrors(t *testing.T) {
+	_, err := NewFromConfig(Config{RefreshInterval: -time.Second, LookupTimeout: -time.Second, RetryJitter: 2})
+	if err == nil {
+		t.Fatal("expect to be failed")
+	}
+	for _, want := range []string{"RefreshInterval", "WithLookupTimeout", "jitter"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("got %q; want it to mention %s", err, want)
+		}
+	}
+}
+
+func TestNewFromConfigNdotsZero(t *testing.T) {
+	var cfg Config
+	if err := json.Unmarshal([]byte(`{"search_domains": ["deeeet.com"], "ndots": 0}`), &cfg); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	r, err := NewFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer r.Stop()
+
+	if r.ndots != 0 {
+		t.Fatalf("got ndots %d; want 0", r.ndots)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
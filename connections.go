@@ -0,0 +1,80 @@
+package dnscache
+
+import (
+	"context"
+	"net"
+	"sync"
+)
+
+// connCounter counts the open connections per IP.
+type connCounter struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// count returns the number of open connections to ip.
+func (c *connCounter) count(ip net.IP) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counts[ip.String()]
+}
+
+func (c *connCounter) add(ip string, delta int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = make(map[string]int)
+	}
+	c.counts[ip] += delta
+	if c.counts[ip] <= 0 {
+		delete(c.counts, ip)
+	}
+}
+
+// track wraps the dial function to count the connections it opens until they
+// are closed.
+func (c *connCounter) track(baseDialFunc dialFunc) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := baseDialFunc(ctx, network, addr)
+		if err != nil || conn == nil {
+			return conn, err
+		}
+		ip, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return conn, nil
+		}
+		c.add(ip, 1)
+		return &trackedConn{Conn: conn, release: func() { c.add(ip, -1) }}, nil
+	}
+}
+
+// trackedConn is a connection which is counted until it is closed.
+type trackedConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(c.release)
+	return c.Conn.Close()
+}
+
+// leastConnSelector picks the IP with the fewest open connections, breaking ties
+// randomly.
+type leastConnSelector struct {
+	conns *connCounter
+}
+
+func (s *leastConnSelector) Pick(host string, ips []net.IP) net.IP {
+	var (
+		picked net.IP
+		least  int
+	)
+	for _, i := range randPerm(len(ips)) {
+		if n := s.conns.count(ips[i]); picked == nil || n < least {
+			picked, least = ips[i], n
+		}
+	}
+	return picked
+}
@@ -0,0 +1,62 @@
+package dnscache
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+func TestDialerLeastConnections(t *testing.T) {
+	resolver := &Resolver{
+		cache: map[string]*entry{
+			"deeeet.com": {ips: []net.IP{
+				net.ParseIP("127.0.0.1"),
+				net.ParseIP("127.0.0.2"),
+			}},
+		},
+	}
+
+	dialF := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		c1, c2 := net.Pipe()
+		c2.Close()
+		return c1, nil
+	}
+
+	d := NewDialer(resolver, dialF, WithLeastConnections())
+	conn1, err := d.DialContext(context.Background(), "tcp", "deeeet.com:443")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The second connection goes to the other IP.
+	conn2, err := d.DialContext(context.Background(), "tcp", "deeeet.com:443")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer conn2.Close()
+
+	conns := d.cfg.conns
+	if conns.count(net.ParseIP("127.0.0.1")) != 1 || conns.count(net.ParseIP("127.0.0.2")) != 1 {
+		t.Fatalf("expect a connection per IP, got %v", conns.counts)
+	}
+
+	// Closing twice releases the connection once.
+	conn1.Close()
+	conn1.Close()
+	if got := len(conns.counts); got != 1 {
+		t.Fatalf("expect a connection to be left, got %v", conns.counts)
+	}
+}
+
+func TestLeastConnSelector(t *testing.T) {
+	conns := &connCounter{}
+	conns.add("127.0.0.1", 2)
+	conns.add("127.0.0.2", 1)
+	conns.add("127.0.0.3", 3)
+
+	s := &leastConnSelector{conns: conns}
+	ips := []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("127.0.0.2"), net.ParseIP("127.0.0.3")}
+	if got := s.Pick("deeeet.com", ips); !got.Equal(net.ParseIP("127.0.0.2")) {
+		t.Fatalf("want 127.0.0.2, got %v", got)
+	}
+}
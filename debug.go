@@ -0,0 +1,123 @@
+package dnscache
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DebugState is the state of a resolver rendered by DebugHandler.
+type DebugState struct {
+	Stats   DebugStats   `json:"stats"`
+	Entries []DebugEntry `json:"entries"`
+	Errors  []DebugError `json:"errors"`
+}
+
+// DebugStats are the counters of a resolver.
+type DebugStats struct {
+	CacheSize    int       `json:"cache_size"`
+	CacheHits    int64     `json:"cache_hits"`
+	CacheMisses  int64     `json:"cache_misses"`
+	Lookups      int64     `json:"lookups"`
+	LookupErrors int64     `json:"lookup_errors"`
+	LastRefresh  time.Time `json:"last_refresh"`
+}
+
+// DebugEntry is a cached host. Age is zero for static entries.
+type DebugEntry struct {
+	Host   string        `json:"host"`
+	IPs    []string      `json:"ips"`
+	Static bool          `json:"static,omitempty"`
+	Age    time.Duration `json:"age"`
+}
+
+// DebugError is the last lookup error of a host.
+type DebugError struct {
+	Host  string    `json:"host"`
+	Error string    `json:"error"`
+	Time  time.Time `json:"time"`
+}
+
+// DebugState returns a snapshot of the cache, the last lookup errors and the
+// counters of the resolver, sorted by host.
+func (r *Resolver) DebugState() DebugState {
+	now := r.now()
+	state := DebugState{
+		Stats: DebugStats{
+			CacheHits:    r.stats.hits.Load(),
+			CacheMisses:  r.stats.misses.Load(),
+			Lookups:      r.stats.lookups.Load(),
+			LookupErrors: r.stats.lookupErrors.Load(),
+		},
+	}
+	if t := r.stats.lastRefresh.Load(); t != 0 {
+		state.Stats.LastRefresh = time.Unix(0, t)
+	}
+
+	for host, e := range r.static {
+		state.Entries = append(state.Entries, DebugEntry{Host: host, IPs: ipStrings(e.ips), Static: true})
+	}
+
+	r.lock.RLock()
+	state.Stats.CacheSize = len(r.cache)
+	for host, e := range r.cache {
+		state.Entries = append(state.Entries, DebugEntry{Host: host, IPs: ipStrings(e.ips), Age: now.Sub(e.updated)})
+	}
+	for host, e := range r.lookupErrors {
+		state.Errors = append(state.Errors, DebugError{Host: host, Error: e.err.Error(), Time: e.time})
+	}
+	r.lock.RUnlock()
+
+	sort.Slice(state.Entries, func(i, j int) bool { return state.Entries[i].Host < state.Entries[j].Host })
+	sort.Slice(state.Errors, func(i, j int) bool { return state.Errors[i].Host < state.Errors[j].Host })
+	return state
+}
+
+// DebugHandler returns an http.Handler which renders DebugState, e.g. to be
+// mounted at /debug/dnscache for on-call engineers. It renders JSON when the
+// request has `?format=json` or accepts application/json, and HTML otherwise.
+// Do not expose it publicly since it reveals the hosts the application talks to.
+func (r *Resolver) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		state := r.DebugState()
+		if req.URL.Query().Get("format") == "json" || strings.Contains(req.Header.Get("Accept"), "application/json") {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(state)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		debugTemplate.Execute(w, state)
+	})
+}
+
+var debugTemplate = template.Must(template.New("dnscache").Parse(`<!DOCTYPE html>
+<html>
+<head><title>dnscache</title></head>
+<body>
+<h1>dnscache</h1>
+<h2>Stats</h2>
+<table>
+<tr><td>Cache size</td><td>{{.Stats.CacheSize}}</td></tr>
+<tr><td>Cache hits</td><td>{{.Stats.CacheHits}}</td></tr>
+<tr><td>Cache misses</td><td>{{.Stats.CacheMisses}}</td></tr>
+<tr><td>Lookups</td><td>{{.Stats.Lookups}}</td></tr>
+<tr><td>Lookup errors</td><td>{{.Stats.LookupErrors}}</td></tr>
+<tr><td>Last refresh</td><td>{{if not .Stats.LastRefresh.IsZero}}{{.Stats.LastRefresh}}{{end}}</td></tr>
+</table>
+<h2>Entries</h2>
+<table>
+<tr><th>Host</th><th>IPs</th><th>Age</th></tr>
+{{range .Entries}}<tr><td>{{.Host}}</td><td>{{range $i, $ip := .IPs}}{{if $i}}, {{end}}{{$ip}}{{end}}</td><td>{{if .Static}}static{{else}}{{.Age}}{{end}}</td></tr>
+{{end}}</table>
+<h2>Errors</h2>
+<table>
+<tr><th>Host</th><th>Error</th><th>Time</th></tr>
+{{range .Errors}}<tr><td>{{.Host}}</td><td>{{.Error}}</td><td>{{.Time}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
@@ -0,0 +1,79 @@
+package dnscache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDebugHandler(t *testing.T) {
+	r := &Resolver{
+		cache:         map[string]*entry{},
+		static:        map[string]*entry{"static.deeeet.com": {ips: []net.IP{net.ParseIP("127.0.0.9")}}},
+		lookupTimeout: time.Second,
+		lookupIPFn: func(ctx context.Context, network, host string) ([]net.IP, error) {
+			if host == "fail.deeeet.com" {
+				return nil, errors.New("lookup failed")
+			}
+			return []net.IP{net.ParseIP("127.0.0.1")}, nil
+		},
+	}
+	r.Fetch(context.Background(), "deeeet.com")
+	r.Fetch(context.Background(), "deeeet.com")
+	r.Fetch(context.Background(), "fail.deeeet.com")
+
+	rec := httptest.NewRecorder()
+	r.DebugHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/dnscache?format=json", nil))
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Fatalf("got %q; want application/json", got)
+	}
+
+	var state DebugState
+	if err := json.Unmarshal(rec.Body.Bytes(), &state); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if s := state.Stats; s.CacheSize != 1 || s.CacheHits != 1 || s.CacheMisses != 2 || s.Lookups != 2 || s.LookupErrors != 1 {
+		t.Fatalf("got %+v", s)
+	}
+	if len(state.Entries) != 2 || state.Entries[0].Host != "deeeet.com" || !state.Entries[1].Static {
+		t.Fatalf("got %+v", state.Entries)
+	}
+	if len(state.Errors) != 1 || state.Errors[0].Host != "fail.deeeet.com" || state.Errors[0].Error != "lookup failed" {
+		t.Fatalf("got %+v", state.Errors)
+	}
+
+	rec = httptest.NewRecorder()
+	r.DebugHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/dnscache", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "<td>deeeet.com</td><td>127.0.0.1</td>") || !strings.Contains(body, "lookup failed") {
+		t.Fatalf("got %s", body)
+	}
+}
+
+func TestDebugStateClearsErrors(t *testing.T) {
+	fail := true
+	r := &Resolver{
+		cache:         map[string]*entry{},
+		lookupTimeout: time.Second,
+		lookupIPFn: func(ctx context.Context, network, host string) ([]net.IP, error) {
+			if fail {
+				return nil, errors.New("lookup failed")
+			}
+			return []net.IP{net.ParseIP("127.0.0.1")}, nil
+		},
+	}
+	r.LookupIP(context.Background(), "deeeet.com")
+	if got := len(r.DebugState().Errors); got != 1 {
+		t.Fatalf("got %d errors; want 1", got)
+	}
+
+	fail = false
+	r.LookupIP(context.Background(), "deeeet.com")
+	if got := len(r.DebugState().Errors); got != 0 {
+		t.Fatalf("got %d errors; want the error to be cleared", got)
+	}
+}
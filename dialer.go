@@ -0,0 +1,263 @@
+package dnscache
+
+import (
+	"context"
+	"net"
+	"net/http/httptrace"
+	"strings"
+	"time"
+)
+
+// Dialer dials the IPs of hosts cached by a resolver, in the same way as the
+// dial function returned by DialFunc does. It implements the context dialer
+// interface used by many libraries, e.g. `proxy.ContextDialer` of
+// golang.org/x/net/proxy, so it can be passed to them as it is.
+type Dialer struct {
+	resolver *Resolver
+	cfg      *dialConfig
+
+	// hosts holds the configurations of the hosts with their own policy.
+	hosts map[string]*dialConfig
+
+	// stats records the dial statistics of the hosts with WithDialStats.
+	stats *dialStats
+}
+
+// NewDialer returns a Dialer which fetches IPs from the resolver and dials them
+// by the given dial function. If no baseDialFunc is given, it sets the dial
+// function of WithNetDialer or default one. The options configure how IPs are
+// selected like DialFunc.
+func NewDialer(resolver *Resolver, baseDialFunc dialFunc, options ...DialOption) *Dialer {
+	d := &Dialer{
+		resolver: resolver,
+		cfg:      newDialConfig(resolver, baseDialFunc, options),
+	}
+	d.stats = &dialStats{}
+
+	for host, hostOptions := range d.cfg.hostOptions {
+		if d.hosts == nil {
+			d.hosts = make(map[string]*dialConfig)
+		}
+		// The policy of a host overrides the options for all hosts.
+		d.hosts[host] = newDialConfig(resolver, baseDialFunc, append(options[:len(options):len(options)], hostOptions...))
+	}
+	return d
+}
+
+// newDialConfig returns the configuration of the given options to dial the hosts
+// cached by resolver.
+func newDialConfig(resolver *Resolver, baseDialFunc dialFunc, options []DialOption) *dialConfig {
+	cfg := &dialConfig{}
+	for _, o := range options {
+		o.apply(cfg)
+	}
+	if s, ok := cfg.selector.(cacheAware); ok {
+		s.setCached(resolver.isCached)
+	}
+	if cfg.breaker != nil {
+		cfg.breaker.cached = resolver.isCached
+	}
+
+	cfg.dial = baseDialFunc
+	if cfg.dial == nil {
+		if cfg.netDialer != nil {
+			cfg.dial = cfg.netDialer.DialContext
+		} else {
+			cfg.dial = defaultDialFunc()
+		}
+	}
+	return cfg
+}
+
+// config returns the configuration of the host.
+func (d *Dialer) config(host string) *dialConfig {
+	if cfg, ok := d.hosts[normalizeHost(host)]; ok {
+		return cfg
+	}
+	return d.cfg
+}
+
+// Dial connects to the address on the named network.
+func (d *Dialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+// DialContext connects to the address on the named network using the provided
+// context. The host of addr is resolved by the cache and its IPs are dialed one
+// by one until a connection is established. The network must be one of "tcp",
+// "tcp4", "tcp6", "udp", "udp4" and "udp6", where only the IPs of the family are
+// dialed for the networks ending in "4" or "6".
+func (d *Dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	h, p, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	family, ok := networkFamily(network)
+	if !ok {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: net.UnknownNetworkError(network)}
+	}
+
+	cfg := d.config(h)
+	if !cfg.allows(network) {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: net.UnknownNetworkError(network)}
+	}
+	if cfg.timeout > 0 {
+		var cancelF context.CancelFunc
+		ctx, cancelF = context.WithTimeout(ctx, cfg.timeout)
+		defer cancelF()
+	}
+	if cfg.socks5 != nil {
+		if !strings.HasPrefix(network, "tcp") {
+			return nil, &net.OpError{Op: "dial", Net: network, Err: net.UnknownNetworkError(network)}
+		}
+		if cfg.socks5.remote {
+			return cfg.socks5.dial(cfg.dial)(ctx, network, addr)
+		}
+	}
+
+	// Fetch DNS result from cache.
+	//
+	// ctxLookup is only used for cancelling DNS Lookup.
+	ctxLookup, cancelF := context.WithTimeout(ctx, d.resolver.lookupTimeout)
+	defer cancelF()
+	ips, err := d.fetch(ctxLookup, h)
+	if err != nil {
+		return nil, err
+	}
+
+	if ips = filterFamily(family, ips); len(ips) == 0 {
+		return nil, &net.DNSError{Err: "no suitable address found", Name: h}
+	}
+	ips = cfg.order(d.resolver, h, ips)
+
+	dialF := cfg.dial
+	if cfg.socks5 != nil {
+		dialF = cfg.socks5.dial(dialF)
+	}
+	if cfg.stats {
+		cached, metrics := ips, d.resolver.metrics
+		dialF = observeDials(ctx, dialF, func(ip net.IP, latency time.Duration, err error) {
+			d.stats.record(h, ip, cached, latency, err)
+			metrics.dialDone(h, ip, latency, err)
+		})
+	}
+	if cfg.conns != nil {
+		dialF = cfg.conns.track(dialF)
+	}
+	if cfg.breaker != nil {
+		ips = cfg.breaker.allow(h, ips, d.resolver.now())
+		dialF = observeDials(ctx, dialF, func(ip net.IP, _ time.Duration, err error) {
+			cfg.breaker.record(h, ip.String(), err, d.resolver.now())
+		})
+	}
+	if obs, ok := cfg.selector.(Observer); ok {
+		dialF = observeDials(ctx, dialF, func(ip net.IP, _ time.Duration, err error) {
+			obs.Observe(h, ip, err)
+		})
+	}
+	if cfg.onDialError != nil {
+		dialF = notifyDialErrors(h, dialF, cfg.onDialError)
+	}
+	if cfg.feedback {
+		dialF = observeDials(ctx, dialF, func(ip net.IP, _ time.Duration, err error) {
+			if err != nil {
+				d.resolver.ReportDialFailure(h, ip)
+			}
+		})
+	}
+	if cfg.fallbackDelay > 0 {
+		preferIPv4 := d.resolver.ipVersion == PreferIPv4 || d.resolver.ipVersion == IPv4Only
+		primaries, fallbacks := partitionFamily(ips, preferIPv4)
+		return dialParallel(ctx, dialF, network, primaries, fallbacks, p, cfg.fallbackDelay, d.resolver.after)
+	}
+	return dialIPsInOrder(ctx, dialF, network, ips, p)
+}
+
+// fetch fetches the IPs of host from the cache. When ctx carries an
+// httptrace.ClientTrace, its DNSStart and DNSDone are called around the fetch.
+// Coalesced of DNSDoneInfo, which means a lookup shared with another one, is not
+// set; cache hits are reported to the Tracer of the resolver instead, whose
+// Fetch span is started with ctx.
+func (d *Dialer) fetch(ctx context.Context, host string) ([]net.IP, error) {
+	trace := httptrace.ContextClientTrace(ctx)
+	if trace == nil || (trace.DNSStart == nil && trace.DNSDone == nil) {
+		return d.resolver.Fetch(ctx, host)
+	}
+
+	if trace.DNSStart != nil {
+		trace.DNSStart(httptrace.DNSStartInfo{Host: host})
+	}
+	ips, err := d.resolver.Fetch(ctx, host)
+	if trace.DNSDone != nil {
+		addrs := make([]net.IPAddr, len(ips))
+		for i, ip := range ips {
+			addrs[i] = net.IPAddr{IP: ip}
+		}
+		trace.DNSDone(httptrace.DNSDoneInfo{Addrs: addrs, Err: err})
+	}
+	return ips, err
+}
+
+// observeDials wraps the dial function to pass the IP, the connect latency and
+// the error of every dial to observe, e.g. to record them in the dial statistics
+// or the circuit breaker. Failures caused by cancellation of ctx, the context of
+// the caller, are not observed since they say nothing about the IP.
+func observeDials(ctx context.Context, baseDialFunc dialFunc, observe func(ip net.IP, latency time.Duration, err error)) dialFunc {
+	return func(dialCtx context.Context, network, addr string) (net.Conn, error) {
+		start := time.Now()
+		conn, err := baseDialFunc(dialCtx, network, addr)
+		if err != nil && ctx.Err() != nil {
+			return conn, err
+		}
+		if host, _, splitErr := net.SplitHostPort(addr); splitErr == nil {
+			if ip := net.ParseIP(host); ip != nil {
+				observe(ip, time.Since(start), err)
+			}
+		}
+		return conn, err
+	}
+}
+
+// notifyDialErrors wraps the dial function to call fn on every dial failure of
+// the host.
+func notifyDialErrors(host string, baseDialFunc dialFunc, fn func(host string, ip net.IP, network string, err error)) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := baseDialFunc(ctx, network, addr)
+		if err != nil {
+			ip, _, _ := net.SplitHostPort(addr)
+			fn(host, net.ParseIP(ip), network, err)
+		}
+		return conn, err
+	}
+}
+
+// networkFamily returns the IP family of the network, "4", "6" or "" for both. It
+// reports false if the network is not supported.
+func networkFamily(network string) (string, bool) {
+	switch network {
+	case "tcp", "udp":
+		return "", true
+	case "tcp4", "udp4":
+		return "4", true
+	case "tcp6", "udp6":
+		return "6", true
+	default:
+		return "", false
+	}
+}
+
+// filterFamily returns the IPs of the family among ips.
+func filterFamily(family string, ips []net.IP) []net.IP {
+	if family == "" {
+		return ips
+	}
+
+	filtered := make([]net.IP, 0, len(ips))
+	for _, ip := range ips {
+		if (ip.To4() != nil) == (family == "4") {
+			filtered = append(filtered, ip)
+		}
+	}
+	return filtered
+}
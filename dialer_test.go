@@ -0,0 +1,341 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http/httptrace"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// contextDialer is same as golang.org/x/net/proxy.ContextDialer.
+type contextDialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// proxyDialer is same as golang.org/x/net/proxy.Dialer.
+type proxyDialer interface {
+	Dial(network, addr string) (net.Conn, error)
+}
+
+var (
+	_ contextDialer = (*Dialer)(nil)
+	_ proxyDialer   = (*Dialer)(nil)
+)
+
+func TestDialer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	resolver := &Resolver{
+		cache: map[string]*entry{
+			"deeeet.com": {ips: []net.IP{net.ParseIP("127.0.0.1")}},
+		},
+		lookupTimeout: time.Second,
+	}
+
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	d := NewDialer(resolver, nil)
+	conn, err := d.Dial("tcp", net.JoinHostPort("deeeet.com", port))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer conn.Close()
+
+	if got := conn.RemoteAddr().String(); got != ln.Addr().String() {
+		t.Fatalf("want %s, got %s", ln.Addr(), got)
+	}
+}
+
+func TestDialerError(t *testing.T) {
+	d := NewDialer(&Resolver{}, nil)
+	if _, err := d.DialContext(context.Background(), "tcp", "deeeet.com"); err == nil {
+		t.Fatalf("expect to be failed")
+	}
+}
+
+func TestDialerOnDialError(t *testing.T) {
+	resolver := &Resolver{
+		cache: map[string]*entry{
+			"deeeet.com": {ips: []net.IP{
+				net.ParseIP("127.0.0.1"),
+				net.ParseIP("127.0.0.2"),
+			}},
+		},
+	}
+
+	errRefused := errors.New("connection refused")
+	dialF := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if addr == "127.0.0.1:443" {
+			return nil, errRefused
+		}
+		return nil, nil
+	}
+
+	var failed []string
+	d := NewDialer(resolver, dialF, WithSelector(firstSelector{}), WithOnDialError(func(host string, ip net.IP, network string, err error) {
+		if err != errRefused {
+			t.Errorf("want %v, got %v", errRefused, err)
+		}
+		failed = append(failed, host+"/"+ip.String()+"/"+network)
+	}))
+	if _, err := d.DialContext(context.Background(), "tcp", "deeeet.com:443"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if want := []string{"deeeet.com/127.0.0.1/tcp"}; !reflect.DeepEqual(want, failed) {
+		t.Fatalf("want %v, got %v", want, failed)
+	}
+}
+
+func TestDialerNetDialer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	resolver := &Resolver{
+		cache: map[string]*entry{
+			"deeeet.com": {ips: []net.IP{net.ParseIP("127.0.0.1")}},
+		},
+		lookupTimeout: time.Second,
+	}
+
+	var controlled []string
+	nd := &net.Dialer{
+		Timeout: time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			controlled = append(controlled, address)
+			return nil
+		},
+	}
+
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	conn, err := NewDialer(resolver, nil, WithNetDialer(nd)).Dial("tcp", net.JoinHostPort("deeeet.com", port))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	conn.Close()
+
+	if want := []string{ln.Addr().String()}; !reflect.DeepEqual(want, controlled) {
+		t.Fatalf("want %v, got %v", want, controlled)
+	}
+}
+
+func TestDialerHostPolicy(t *testing.T) {
+	resolver := &Resolver{
+		cache: map[string]*entry{
+			"api.mercari.io": {ips: []net.IP{
+				net.ParseIP("127.0.0.1"),
+				net.ParseIP("127.0.0.2"),
+			}},
+			"batch.mercari.io": {ips: []net.IP{
+				net.ParseIP("127.0.0.3"),
+				net.ParseIP("127.0.0.4"),
+			}},
+		},
+		lookupTimeout: time.Second,
+	}
+
+	var (
+		dialed    []string
+		deadlines = map[string]bool{}
+	)
+	dialF := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		_, ok := ctx.Deadline()
+		deadlines[addr] = ok
+		return nil, nil
+	}
+
+	d := NewDialer(resolver, dialF,
+		WithSelector(firstSelector{}),
+		WithHostPolicy("API.mercari.io.",
+			WithSelector(lastSelector{}),
+			WithDialTimeout(200*time.Millisecond),
+			WithAllowedNetworks("tcp4"),
+		),
+	)
+
+	if _, err := d.DialContext(context.Background(), "tcp4", "api.mercari.io:443"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := d.DialContext(context.Background(), "tcp", "batch.mercari.io:443"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if want := []string{"127.0.0.2:443", "127.0.0.3:443"}; !reflect.DeepEqual(want, dialed) {
+		t.Fatalf("want %v, got %v", want, dialed)
+	}
+	if !deadlines["127.0.0.2:443"] || deadlines["127.0.0.3:443"] {
+		t.Fatalf("expect the timeout to be applied only to api.mercari.io, got %v", deadlines)
+	}
+
+	if _, err := d.DialContext(context.Background(), "tcp6", "api.mercari.io:443"); err == nil {
+		t.Fatalf("expect not allowed network to fail")
+	}
+}
+
+func TestDialerUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer pc.Close()
+
+	resolver := &Resolver{
+		cache: map[string]*entry{
+			"deeeet.com": {ips: []net.IP{net.ParseIP("::1"), net.ParseIP("127.0.0.1")}},
+		},
+		lookupTimeout: time.Second,
+	}
+
+	_, port, _ := net.SplitHostPort(pc.LocalAddr().String())
+	conn, err := NewDialer(resolver, nil).Dial("udp4", net.JoinHostPort("deeeet.com", port))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	buf := make([]byte, 4)
+	pc.SetReadDeadline(time.Now().Add(time.Second))
+	if n, _, err := pc.ReadFrom(buf); err != nil || string(buf[:n]) != "ping" {
+		t.Fatalf("expect ping to be received, got %q, err: %v", buf[:n], err)
+	}
+}
+
+func TestDialerNetwork(t *testing.T) {
+	resolver := &Resolver{
+		cache: map[string]*entry{
+			"deeeet.com": {ips: []net.IP{net.ParseIP("::1"), net.ParseIP("127.0.0.1")}},
+			"v4.com":     {ips: []net.IP{net.ParseIP("127.0.0.1")}},
+		},
+		lookupTimeout: time.Second,
+	}
+
+	var dialed []string
+	dialF := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = append(dialed, network+"/"+addr)
+		return nil, errors.New("connection refused")
+	}
+
+	d := NewDialer(resolver, dialF)
+	d.DialContext(context.Background(), "tcp6", "deeeet.com:443")
+	if want := []string{"tcp6/[::1]:443"}; !reflect.DeepEqual(want, dialed) {
+		t.Fatalf("want %v, got %v", want, dialed)
+	}
+
+	if _, err := d.DialContext(context.Background(), "udp6", "v4.com:53"); err == nil {
+		t.Fatalf("expect host without IPv6 to fail")
+	}
+	if _, err := d.DialContext(context.Background(), "unix", "deeeet.com:443"); err == nil {
+		t.Fatalf("expect unsupported network to fail")
+	}
+}
+
+func TestDialerClientTrace(t *testing.T) {
+	resolver := &Resolver{
+		cache:         map[string]*entry{},
+		lookupTimeout: time.Second,
+		lookupIPFn: func(ctx context.Context, network, host string) ([]net.IP, error) {
+			return []net.IP{net.ParseIP("127.0.0.1")}, nil
+		},
+	}
+	nopDial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		c, _ := net.Pipe()
+		return c, nil
+	}
+	tracer := &testTracer{}
+	resolver.tracer = tracer
+	d := NewDialer(resolver, nopDial)
+
+	var (
+		starts []string
+		dones  []httptrace.DNSDoneInfo
+	)
+	ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			starts = append(starts, info.Host)
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			dones = append(dones, info)
+		},
+	})
+
+	for i := 0; i < 2; i++ {
+		conn, err := d.DialContext(ctx, "tcp", "deeeet.com:80")
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		conn.Close()
+	}
+
+	if !reflect.DeepEqual(starts, []string{"deeeet.com", "deeeet.com"}) {
+		t.Fatalf("got %v", starts)
+	}
+	if len(dones) != 2 || dones[0].Coalesced || dones[1].Coalesced {
+		t.Fatalf("got %+v; want DNSDone twice without Coalesced", dones)
+	}
+	// The cache hit is reported to the tracer of the resolver.
+	if want := []string{SpanFetch + " deeeet.com > " + SpanLookupIP + " deeeet.com", SpanFetch + " deeeet.com", SpanFetch + " deeeet.com hit"}; !reflect.DeepEqual(tracer.spans, want) {
+		t.Fatalf("got %v; want %v", tracer.spans, want)
+	}
+	if len(dones[0].Addrs) != 1 || !dones[0].Addrs[0].IP.Equal(net.ParseIP("127.0.0.1")) || dones[0].Err != nil {
+		t.Fatalf("got %+v", dones[0])
+	}
+}
+
+func TestObserveDials(t *testing.T) {
+	errDial := errors.New("connection refused")
+	var observed []string
+	dialF := observeDials(context.Background(), func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, errDial
+	}, func(ip net.IP, latency time.Duration, err error) {
+		if err != errDial {
+			t.Fatalf("want %v, got %v", errDial, err)
+		}
+		observed = append(observed, ip.String())
+	})
+	dialF(context.Background(), "tcp", "127.0.0.1:443")
+	if want := []string{"127.0.0.1"}; !reflect.DeepEqual(want, observed) {
+		t.Fatalf("want %v, got %v", want, observed)
+	}
+
+	// Failures caused by cancellation of the caller are not observed.
+	ctx, cancelF := context.WithCancel(context.Background())
+	cancelF()
+	dialF = observeDials(ctx, func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, ctx.Err()
+	}, func(ip net.IP, latency time.Duration, err error) {
+		t.Fatalf("expect cancellation not to be observed, got %v", err)
+	})
+	dialF(ctx, "tcp", "127.0.0.1:443")
+}
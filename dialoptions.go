@@ -0,0 +1,353 @@
+package dnscache
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// DialOption configures the dial function returned by DialFunc.
+type DialOption struct {
+	apply func(c *dialConfig)
+}
+
+// dialConfig is the configuration of a dial function.
+type dialConfig struct {
+	// dial dials an IP. It is set from the base dial function or netDialer.
+	dial dialFunc
+
+	// netDialer dials IPs when no base dial function is given.
+	netDialer *net.Dialer
+
+	// timeout limits the whole dial including the lookup when it is positive.
+	timeout time.Duration
+
+	// networks is the set of the networks allowed to dial. All networks are
+	// allowed when it is empty.
+	networks map[string]bool
+
+	// hostOptions holds the options of the hosts with their own policy.
+	hostOptions map[string][]DialOption
+
+	// selector picks the first IP to dial. IPs are dialed in random order when
+	// it is nil.
+	selector Selector
+
+	// conns counts the open connections per IP when it is not nil.
+	conns *connCounter
+
+	// breaker skips the IPs which keep failing when it is not nil.
+	breaker *breaker
+
+	// onDialError is called on every dial failure when it is not nil.
+	onDialError func(host string, ip net.IP, network string, err error)
+
+	// socks5 is the proxy to dial through when it is not nil.
+	socks5 *socks5
+
+	// stats enables recording the dial statistics per host and IP.
+	stats bool
+
+	// feedback reports dial failures to the resolver.
+	feedback bool
+
+	// fallbackDelay is the delay to start dialing the other address family
+	// concurrently. Happy Eyeballs is disabled when it is zero.
+	fallbackDelay time.Duration
+}
+
+// Selector picks the IP to dial first among the cached IPs of a host. It lets
+// users plug in their own policy such as zone affinity or hashing by a key.
+// Pick must be safe for concurrent use. When the picked IP fails, the remaining
+// IPs are dialed in order following it.
+type Selector interface {
+	// Pick returns one of ips. The IPs are dialed in the given order when it
+	// returns nil or an IP which is not in ips.
+	Pick(host string, ips []net.IP) net.IP
+}
+
+// Observer is implemented by Selectors which need the outcome of dials. Observe
+// is called after each IP picked or not is dialed, with the error of the dial.
+// Failures caused by cancellation of the context of the caller are not observed.
+type Observer interface {
+	Observe(host string, ip net.IP, err error)
+}
+
+// WithNetDialer makes the dial function dial IPs by the given `net.Dialer`, e.g.
+// with a specific timeout, LocalAddr or Control function to set socket options,
+// instead of the default one. It is used only when no base dial function is
+// given, which is always the case for NewTransport and NewHTTPClient.
+func WithNetDialer(d *net.Dialer) DialOption {
+	return DialOption{apply: func(c *dialConfig) {
+		c.netDialer = d
+	}}
+}
+
+// WithHostPolicy overrides the options for dials to the given host, e.g. to apply
+// a strict timeout only to a latency-critical API while sharing one cache and
+// dialer with other hosts. The options for all hosts are applied first, then the
+// given ones. State such as round-robin positions, circuit breakers and connection
+// counts of the host is kept apart from the other hosts.
+func WithHostPolicy(host string, options ...DialOption) DialOption {
+	return DialOption{apply: func(c *dialConfig) {
+		if c.hostOptions == nil {
+			c.hostOptions = make(map[string][]DialOption)
+		}
+		host := normalizeHost(host)
+		c.hostOptions[host] = append(c.hostOptions[host], options...)
+	}}
+}
+
+// WithDialTimeout limits the time of a whole dial, including the lookup and the
+// dials of all IPs, in addition to the deadline of the given context.
+func WithDialTimeout(timeout time.Duration) DialOption {
+	return DialOption{apply: func(c *dialConfig) {
+		c.timeout = timeout
+	}}
+}
+
+// WithAllowedNetworks restricts the networks the dial function accepts, e.g.
+// "tcp4". Dials of the other networks fail without being tried.
+func WithAllowedNetworks(networks ...string) DialOption {
+	return DialOption{apply: func(c *dialConfig) {
+		c.networks = make(map[string]bool, len(networks))
+		for _, network := range networks {
+			c.networks[network] = true
+		}
+	}}
+}
+
+// allows reports whether the network is allowed to dial.
+func (c *dialConfig) allows(network string) bool {
+	return len(c.networks) == 0 || c.networks[network]
+}
+
+// WithSelector makes the dial function pick the first IP to dial by the given
+// selector instead of randomly.
+func WithSelector(s Selector) DialOption {
+	return DialOption{apply: func(c *dialConfig) {
+		c.selector = s
+	}}
+}
+
+// WithRoundRobin makes the dial function rotate across the cached IPs of each host
+// instead of picking them randomly, which gives an even distribution of connections.
+// The remaining IPs are dialed in order when the first one fails.
+func WithRoundRobin() DialOption {
+	return WithSelector(&roundRobinSelector{})
+}
+
+// WithSticky makes the dial function keep dialing the IP of each host which was
+// connected last, which keeps connections on the same backend. The other IPs are
+// dialed when it fails, and the first IP is picked randomly.
+func WithSticky() DialOption {
+	return WithSelector(&stickySelector{})
+}
+
+// WithLeastConnections makes the dial function track the open connections per IP
+// and dial the IP with the fewest of them first, which spreads load better than
+// random when a few long-lived connections dominate. Connections are counted until
+// they are closed, so the returned `net.Conn` wraps the one of the base dial function.
+func WithLeastConnections() DialOption {
+	return DialOption{apply: func(c *dialConfig) {
+		c.conns = &connCounter{}
+		c.selector = &leastConnSelector{conns: c.conns}
+	}}
+}
+
+// WithCircuitBreaker makes the dial function track dial outcomes per host and IP.
+// An IP which fails to be dialed failures times in a row is skipped for cooldown,
+// after which a single dial is let through to probe it. A successful dial
+// brings the IP back. When every IP of a host is skipped, all of them are dialed.
+func WithCircuitBreaker(failures int, cooldown time.Duration) DialOption {
+	return DialOption{apply: func(c *dialConfig) {
+		if failures < 1 {
+			failures = 1
+		}
+		c.breaker = &breaker{threshold: failures, cooldown: cooldown}
+	}}
+}
+
+// WithFailureFeedback makes the dial function report dial failures to the resolver
+// by ReportDialFailure, which demotes the failed IP in the cache and looks up the
+// host again early.
+func WithFailureFeedback() DialOption {
+	return DialOption{apply: func(c *dialConfig) {
+		c.feedback = true
+	}}
+}
+
+// WithOnDialError sets the hook called on every failure to dial an IP with the
+// host, the IP, the network and the error, e.g. to emit metrics of the cached IPs
+// which are failing. It is called synchronously from the dial function.
+func WithOnDialError(fn func(host string, ip net.IP, network string, err error)) DialOption {
+	return DialOption{apply: func(c *dialConfig) {
+		c.onDialError = fn
+	}}
+}
+
+// WithSOCKS5 makes the dial function connect through the SOCKS5 proxy at addr. The
+// host is resolved by the cache and the proxy is asked to CONNECT to the selected
+// IP, failing over to the other IPs like direct dials. If remoteResolve is true,
+// the host is sent to the proxy to resolve instead. username and password are used
+// for the username/password authentication when username is not empty. Only TCP
+// networks can be dialed through the proxy.
+func WithSOCKS5(addr, username, password string, remoteResolve bool) DialOption {
+	return DialOption{apply: func(c *dialConfig) {
+		c.socks5 = &socks5{
+			addr:     addr,
+			username: username,
+			password: password,
+			remote:   remoteResolve,
+		}
+	}}
+}
+
+// WithDialStats makes the Dialer record the connect latency and the outcome of
+// dials per host and IP, which can be read by Stats, to see the IPs which are slow
+// before they fail outright.
+func WithDialStats() DialOption {
+	return DialOption{apply: func(c *dialConfig) {
+		c.stats = true
+	}}
+}
+
+// defaultFallbackDelay is the delay of Happy Eyeballs used when none is given, which
+// is same as `net.Dialer` uses.
+const defaultFallbackDelay = 300 * time.Millisecond
+
+// WithHappyEyeballs makes the dial function dial IPv4 and IPv6 in parallel when a
+// host has both A and AAAA records (RFC 8305). The IPv6 addresses are dialed
+// first, or the IPv4 ones with WithIPVersionPreference(PreferIPv4), and the IPs
+// of the other family are started after delay or when the first family fails,
+// whichever comes first. The first established connection is used. If delay is
+// zero or negative, 300ms is used.
+func WithHappyEyeballs(delay time.Duration) DialOption {
+	return DialOption{apply: func(c *dialConfig) {
+		if delay <= 0 {
+			delay = defaultFallbackDelay
+		}
+		c.fallbackDelay = delay
+	}}
+}
+
+// order returns ips in the order to dial them, keeping the preferred address
+// family of the resolver first.
+func (c *dialConfig) order(resolver *Resolver, host string, ips []net.IP) []net.IP {
+	if c.selector == nil {
+		return shuffleIPs(resolver, ips)
+	}
+	return resolver.ipVersion.apply(rotateTo(ips, c.selector.Pick(host, ips)))
+}
+
+// rotateTo returns a copy of ips starting from the given IP and wrapping around.
+// ips is copied as it is when it does not contain ip.
+func rotateTo(ips []net.IP, ip net.IP) []net.IP {
+	start := 0
+	for i := range ips {
+		if ips[i].Equal(ip) {
+			start = i
+			break
+		}
+	}
+
+	rotated := make([]net.IP, 0, len(ips))
+	rotated = append(rotated, ips[start:]...)
+	return append(rotated, ips[:start]...)
+}
+
+// roundRobinSelector picks the cached IPs of each host in turn.
+type roundRobinSelector struct {
+	// mu guards next, which holds the index of the next IP to pick per host for
+	// up to maxDialHosts hosts, and cached.
+	mu     sync.Mutex
+	next   map[string]int
+	cached func(host string) bool
+}
+
+func (s *roundRobinSelector) Pick(host string, ips []net.IP) net.IP {
+	if len(ips) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.next == nil {
+		s.next = make(map[string]int)
+	}
+	next, ok := s.next[host]
+	if !ok {
+		makeRoom(s.next, maxDialHosts, s.stale)
+	}
+	i := next % len(ips)
+	s.next[host] = i + 1
+	return ips[i]
+}
+
+func (s *roundRobinSelector) setCached(cached func(host string) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cached = cached
+}
+
+// stale reports whether host is no longer cached. s.mu must be held.
+func (s *roundRobinSelector) stale(host string) bool {
+	return s.cached != nil && !s.cached(host)
+}
+
+// stickySelector picks the IP of each host which was connected last.
+type stickySelector struct {
+	// mu guards last, which holds the IP connected last per host for up to
+	// maxDialHosts hosts, and cached.
+	mu     sync.Mutex
+	last   map[string]net.IP
+	cached func(host string) bool
+}
+
+func (s *stickySelector) Pick(host string, ips []net.IP) net.IP {
+	if len(ips) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	last, ok := s.last[host]
+	s.mu.Unlock()
+	if ok {
+		for _, ip := range ips {
+			if ip.Equal(last) {
+				return ip
+			}
+		}
+	}
+	return ips[randPerm(len(ips))[0]]
+}
+
+func (s *stickySelector) Observe(host string, ip net.IP, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err != nil {
+		if s.last[host].Equal(ip) {
+			delete(s.last, host)
+		}
+		return
+	}
+	if s.last == nil {
+		s.last = make(map[string]net.IP)
+	}
+	if _, ok := s.last[host]; !ok {
+		makeRoom(s.last, maxDialHosts, s.stale)
+	}
+	s.last[host] = ip
+}
+
+func (s *stickySelector) setCached(cached func(host string) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cached = cached
+}
+
+// stale reports whether host is no longer cached. s.mu must be held.
+func (s *stickySelector) stale(host string) bool {
+	return s.cached != nil && !s.cached(host)
+}
@@ -0,0 +1,123 @@
+package dnscache
+
+import (
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// IPDialStats is the statistics of dials to an IP of a host.
+type IPDialStats struct {
+	Host string
+	IP   net.IP
+
+	// Successes and Failures are the numbers of successful and failed dials.
+	Successes uint64
+	Failures  uint64
+
+	// TotalLatency is the sum of the connect latency of successful dials, and
+	// LastLatency is the latency of the last dial whether it failed or not.
+	TotalLatency time.Duration
+	LastLatency  time.Duration
+}
+
+// SuccessRate returns the ratio of successful dials to all dials.
+func (s IPDialStats) SuccessRate() float64 {
+	total := s.Successes + s.Failures
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Successes) / float64(total)
+}
+
+// AverageLatency returns the average connect latency of successful dials.
+func (s IPDialStats) AverageLatency() time.Duration {
+	if s.Successes == 0 {
+		return 0
+	}
+	return s.TotalLatency / time.Duration(s.Successes)
+}
+
+// dialStats records IPDialStats per host and IP for up to maxDialHosts hosts.
+type dialStats struct {
+	mu    sync.Mutex
+	stats map[string]map[string]*IPDialStats
+}
+
+// record records a dial of ip of host, one of the IPs cached for host. The
+// statistics of the other IPs of host, which are no longer cached, are dropped.
+func (s *dialStats) record(host string, ip net.IP, cached []net.IP, latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stats == nil {
+		s.stats = make(map[string]map[string]*IPDialStats)
+	}
+	ips, ok := s.stats[host]
+	if !ok {
+		makeRoom(s.stats, maxDialHosts, nil)
+		ips = make(map[string]*IPDialStats)
+		s.stats[host] = ips
+	}
+	for key, st := range ips {
+		if !containsIP(cached, st.IP) {
+			delete(ips, key)
+		}
+	}
+	st, ok := ips[ip.String()]
+	if !ok {
+		st = &IPDialStats{Host: host, IP: ip}
+		ips[ip.String()] = st
+	}
+
+	st.LastLatency = latency
+	if err != nil {
+		st.Failures++
+		return
+	}
+	st.Successes++
+	st.TotalLatency += latency
+}
+
+// snapshot returns a copy of the statistics sorted by host and IP. The hosts
+// which have been removed from the cache of resolver are dropped.
+func (s *dialStats) snapshot(resolver *Resolver) []IPDialStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var all []IPDialStats
+	for host, ips := range s.stats {
+		if !resolver.isCached(host) {
+			delete(s.stats, host)
+			continue
+		}
+		for _, st := range ips {
+			all = append(all, *st)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Host != all[j].Host {
+			return all[i].Host < all[j].Host
+		}
+		return all[i].IP.String() < all[j].IP.String()
+	})
+	return all
+}
+
+// containsIP reports whether ips contains ip.
+func containsIP(ips []net.IP, ip net.IP) bool {
+	for _, cached := range ips {
+		if cached.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Stats returns the dial statistics per host and IP sorted by host and IP. Only
+// the hosts dialed with WithDialStats are recorded, and only while they are
+// cached, up to 1024 hosts.
+func (d *Dialer) Stats() []IPDialStats {
+	return d.stats.snapshot(d.resolver)
+}
@@ -0,0 +1,111 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDialerStats(t *testing.T) {
+	resolver := &Resolver{
+		cache: map[string]*entry{
+			"deeeet.com": {ips: []net.IP{
+				net.ParseIP("127.0.0.1"),
+				net.ParseIP("127.0.0.2"),
+			}},
+		},
+	}
+
+	dialF := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if addr == "127.0.0.1:443" {
+			return nil, errors.New("connection refused")
+		}
+		time.Sleep(time.Millisecond)
+		return nil, nil
+	}
+
+	d := NewDialer(resolver, dialF, WithSelector(firstSelector{}), WithDialStats())
+	for i := 0; i < 2; i++ {
+		if _, err := d.DialContext(context.Background(), "tcp", "deeeet.com:443"); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	stats := d.Stats()
+	if len(stats) != 2 {
+		t.Fatalf("expect stats of 2 IPs, got %v", stats)
+	}
+	if s := stats[0]; !s.IP.Equal(net.ParseIP("127.0.0.1")) || s.Failures != 2 || s.SuccessRate() != 0 {
+		t.Fatalf("unexpected stats: %+v", s)
+	}
+	if s := stats[1]; s.Successes != 2 || s.SuccessRate() != 1 || s.AverageLatency() < time.Millisecond {
+		t.Fatalf("unexpected stats: %+v", s)
+	}
+
+	d = NewDialer(resolver, dialF)
+	d.DialContext(context.Background(), "tcp", "deeeet.com:443")
+	if d.Stats() != nil {
+		t.Fatalf("expect no stats without WithDialStats")
+	}
+}
+
+type testDialMetrics struct {
+	testMetrics
+	dials []string
+}
+
+func (m *testDialMetrics) DialDone(host string, ip net.IP, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dials = append(m.dials, host+" "+ip.String())
+}
+
+func TestDialerStats_prune(t *testing.T) {
+	m := &testDialMetrics{}
+	resolver := &Resolver{
+		cache: map[string]*entry{
+			"deeeet.com": {ips: []net.IP{net.ParseIP("127.0.0.1")}},
+		},
+		metrics: metricsList{m},
+	}
+
+	dialF := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, nil
+	}
+	d := NewDialer(resolver, dialF, WithDialStats())
+	if _, err := d.DialContext(context.Background(), "tcp", "deeeet.com:443"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(m.dials) != 1 || m.dials[0] != "deeeet.com 127.0.0.1" {
+		t.Fatalf("got %v; want the dial to be reported to the metrics", m.dials)
+	}
+
+	// The stats of IPs which are no longer cached are dropped.
+	resolver.cache["deeeet.com"] = &entry{ips: []net.IP{net.ParseIP("127.0.0.2")}}
+	if _, err := d.DialContext(context.Background(), "tcp", "deeeet.com:443"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if stats := d.Stats(); len(stats) != 1 || !stats[0].IP.Equal(net.ParseIP("127.0.0.2")) {
+		t.Fatalf("got %v; want only the stats of the cached IP", stats)
+	}
+
+	// The stats of hosts removed from the cache are dropped.
+	resolver.Remove("deeeet.com")
+	if stats := d.Stats(); len(stats) != 0 {
+		t.Fatalf("got %v; want the stats of the removed host to be dropped", stats)
+	}
+}
+
+func TestDialStatsLimit(t *testing.T) {
+	s := &dialStats{}
+	ip := net.ParseIP("127.0.0.1")
+	for i := 0; i < maxDialHosts+10; i++ {
+		s.record(fmt.Sprintf("host%d", i), ip, []net.IP{ip}, time.Millisecond, nil)
+	}
+	if got := len(s.stats); got != maxDialHosts {
+		t.Fatalf("got %d hosts; want %d", got, maxDialHosts)
+	}
+}
@@ -2,9 +2,14 @@ package dnscache
 
 import (
 	"context"
+	"errors"
 	"log/slog"
+	"math/rand"
 	"net"
+	"net/netip"
+	"runtime/pprof"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -19,42 +24,254 @@ var (
 	defaultLookupTimeout = 10 * time.Second
 )
 
-// lookupIP is a wrapper of net.DefaultResolver.LookupIPAddr.
+// systemResolver is net.DefaultResolver at start-up. It is kept even after
+// net.DefaultResolver is replaced by NetResolver so that lookups do not loop back
+// to the cache.
+var systemResolver = net.DefaultResolver
+
+// lookupIP is a wrapper of systemResolver.LookupIP.
 // This is used to replace lookup function when test.
-var lookupIP = func(ctx context.Context, host string) ([]net.IP, error) {
-	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
-	if err != nil {
-		return nil, err
-	}
+var lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+	return systemResolver.LookupIP(ctx, network, host)
+}
 
-	ips := make([]net.IP, len(addrs))
-	for i, ia := range addrs {
-		ips[i] = ia.IP
-	}
+// lookupCNAME is a wrapper of systemResolver.LookupCNAME.
+// This is used to replace lookup function when test.
+var lookupCNAME = func(ctx context.Context, host string) (string, error) {
+	return systemResolver.LookupCNAME(ctx, host)
+}
 
-	return ips, nil
+// onRefreshed is called with the summary when DNS are refreshed.
+var onRefreshed = func(RefreshSummary) {}
+
+// randFloat64 is a wrapper of rand.Float64 used for retry jitter.
+// This is used to replace random function when test.
+var randFloat64 = func() float64 {
+	return rand.Float64()
 }
 
-// onRefreshed is called when DNS are refreshed.
-var onRefreshed = func() {}
+// entry is a cached lookup result of a host.
+type entry struct {
+	ips []net.IP
+
+	// validation is the DNSSEC validation status of the result.
+	validation Validation
+
+	// cname is the canonical name of the host. It is empty unless the backend
+	// reported it or it has been requested by CanonicalName.
+	cname string
+
+	// messages are the raw response messages of the lookup in wire format mode.
+	messages []Message
+
+	// updated is when the entry was stored in the cache.
+	updated time.Time
+
+	// interval is the refresh interval of the entry with WithAdaptiveRefresh.
+	interval time.Duration
+
+	// rotation counts Fetch calls to rotate ips in round-robin.
+	rotation atomic.Uint32
+}
+
+// clone returns a copy of e. Cached entries may be read without the lock, so
+// they are replaced by a modified copy instead of being modified.
+func (e *entry) clone() *entry {
+	c := &entry{
+		ips:        e.ips,
+		validation: e.validation,
+		cname:      e.cname,
+		messages:   e.messages,
+		updated:    e.updated,
+		interval:   e.interval,
+	}
+	c.rotation.Store(e.rotation.Load())
+	return c
+}
 
 // Resolver is DNS cache resolver which cache DNS resolve results in memory.
 type Resolver struct {
-	lookupIPFn    func(ctx context.Context, host string) ([]net.IP, error)
+	lookupIPFn func(ctx context.Context, network, host string) ([]net.IP, error)
+
+	// lookupTimeout is used for foreground lookups when the context has no deadline.
 	lookupTimeout time.Duration
 
+	// network is the address family to look up: "ip", "ip4" or "ip6".
+	network string
+
 	lock  sync.RWMutex
-	cache map[string][]net.IP
+	cache map[string]*entry
+
+	// nameserver is used for lookups instead of the system resolver when set.
+	nameserver   *nameserver
+	clientSubnet netip.Prefix
+	dnssec       dnssecMode
+	wireFormat   bool
+
+	// mdns resolves .local names by multicast DNS.
+	mdns bool
+
+	// ipVersion orders or filters looked up IPs by address family.
+	ipVersion IPVersionPreference
+
+	// sortAddrs sorts looked up IPs by RFC 6724.
+	sortAddrs bool
+
+	// ipFilter validates or transforms looked up IPs before caching.
+	ipFilter func(host string, ips []net.IP) ([]net.IP, error)
+
+	// static holds immutable entries which are never looked up nor refreshed.
+	static map[string]*entry
+
+	// rotation rotates IPs returned by Fetch.
+	rotation Rotation
+
+	// search domains are tried for names with fewer dots than ndots, and
+	// aliases maps such names to the names they resolved to.
+	search  []string
+	ndots   int
+	aliases map[string]string
+
+	// resolvConfPath is the resolv.conf file to read search domains from, which
+	// was modified at resolvConfModTime when it was read. resolvConfNameserver
+	// is the client of the first nameserver of the file, used unless nameserver
+	// is set.
+	resolvConfPath       string
+	resolvConfModTime    time.Time
+	resolvConfNameserver atomic.Pointer[nameserver]
+
+	// reverse indexes cached hosts by IP when enabled.
+	reverse reverseIndex
+
+	// group deduplicates concurrent lookups for the same addr.
+	group group
+
+	// listeners are called when the IP set of a cached host changes.
+	listeners listeners
+
+	// events delivers changes of the cache to Events.
+	events events
+
+	// records caches record sets other than IPs, e.g. MX records, by type and
+	// name. It is guarded by lock.
+	records map[recordKey]*recordSet
+
+	// metrics receive measurements of lookups and refreshes, and stats count
+	// them for expvar and the debug handler.
+	metrics metricsList
+	stats   resolverStats
+
+	// lookupErrors are the last lookup errors per host. They are guarded by lock.
+	lookupErrors map[string]lookupError
+
+	// tracer starts spans around lookups and refreshes when set.
+	tracer Tracer
+
+	// expvarPrefix is the prefix to publish the state of the resolver by expvar.
+	expvarPrefix string
+
+	// hooks are fired around every lookup.
+	hooks Hooks
 
 	// defaultLookupTimeout is used when refreshing DNS cache
 	defaultLookupTimeout time.Duration
-	logger               *slog.Logger
+	logger               Logger
+	logAttrs             []slog.Attr
+
+	// name identifies the resolver in profiles.
+	name string
+
+	// history keeps the last lookup results per host when set.
+	history *history
+
+	// latency keeps lookup latency histograms per host when set.
+	latency *latencyStats
+
+	// slowLookup is the duration from which lookups are logged as slow.
+	slowLookup time.Duration
+
+	// unhealthyAfter is the number of consecutive failed refresh cycles after
+	// which Healthy reports an error.
+	unhealthyAfter int
+
+	// errorLog limits the logs of refresh failures when set.
+	errorLog *errorLogLimiter
+
+	// onRefreshError is called with each host which failed to be refreshed.
+	onRefreshError func(host string, err error)
+
+	// onRefresh is called with the summary of each refresh cycle.
+	onRefresh func(RefreshSummary)
+
+	// onRefreshPanic is called with the values recovered from panics of
+	// background refreshes.
+	onRefreshPanic func(host string, recovered any)
+
+	retry retryPolicy
+
+	// hosts is consulted before DNS when a hosts file is configured.
+	hostsPath string
+	hosts     *hostsFile
+
+	// optionErrs are the errors of invalid options, which are returned by New.
+	optionErrs []error
+
+	// clock is the source of time of the refresh loop and the cache.
+	clock Clock
+
+	// ticker triggers background refreshes, which are skipped while paused.
+	// It ticks at the shortest one of freq and the intervals of hosts.
+	ticker    Ticker
+	freq      time.Duration
+	intervals []hostInterval
+
+	// backoff skips hosts which keep failing in background refreshes when set.
+	backoff *refreshBackoff
+
+	// emptyResults is how lookups without any IP are handled.
+	emptyResults EmptyResultPolicy
+
+	// staleFallback serves cached entries stored within maxStale, or any if it is
+	// 0, when foreground lookups fail.
+	staleFallback bool
+	maxStale      time.Duration
+
+	// adaptive adapts the refresh intervals of hosts to their change rate when set.
+	adaptive *adaptiveRefresh
+
+	// refreshLimit limits the rate of refresh lookups when set.
+	refreshLimit *tokenBucket
+
+	// dialRefreshes are the hosts being refreshed after a dial failure.
+	dialRefreshes map[string]struct{}
+
+	// warmup are the hosts looked up by New within warmupTimeout.
+	warmup        []string
+	warmupTimeout time.Duration
+
+	// onDemand disables background refreshing. Fetch re-resolves entries older
+	// than maxAge, if set, with or without it.
+	onDemand bool
+	maxAge   time.Duration
+	paused   atomic.Bool
 
 	closer func()
+
+	// done is closed when the background refreshing goroutine exits.
+	done chan struct{}
+
+	// stopped is done when the resolver is stopped, which cancels refreshes in
+	// progress.
+	stopped context.Context
 }
 
 // New initializes DNS cache resolver and starts auto refreshing in a new goroutine.
 // To stop refreshing, call `Stop()` function.
+//
+// freq is the frequency of refreshing. lookupTimeout is the timeout of both
+// foreground lookups and background refreshes unless they are set separately by
+// WithLookupTimeout and WithRefreshTimeout. It returns an error describing
+// every invalid option and conflicting combination of options, if any.
 func New(freq time.Duration, lookupTimeout time.Duration, options ...Option) (*Resolver, error) {
 	if freq <= 0 {
 		freq = defaultFreq
@@ -64,13 +281,6 @@ func New(freq time.Duration, lookupTimeout time.Duration, options ...Option) (*R
 		lookupTimeout = defaultLookupTimeout
 	}
 
-	ticker := time.NewTicker(freq)
-	ch := make(chan struct{})
-	closer := func() {
-		ticker.Stop()
-		close(ch)
-	}
-
 	// copy handler function to avoid race
 	onRefreshedFn := onRefreshed
 	lookupIPFn := lookupIP
@@ -78,79 +288,694 @@ func New(freq time.Duration, lookupTimeout time.Duration, options ...Option) (*R
 	r := &Resolver{
 		lookupIPFn:           lookupIPFn,
 		lookupTimeout:        lookupTimeout,
-		cache:                make(map[string][]net.IP, cacheSize),
+		network:              "ip",
+		cache:                make(map[string]*entry, cacheSize),
+		aliases:              make(map[string]string),
+		ndots:                defaultNdots,
 		defaultLookupTimeout: lookupTimeout,
 		logger:               slog.Default(),
-		closer:               closer,
+		clock:                systemClock{},
+		freq:                 freq,
+		done:                 make(chan struct{}),
 	}
 
 	for _, o := range options {
 		o.apply(r)
 	}
 
-	go func() {
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+
+	ticker := r.clock.NewTicker(freq)
+	ch := make(chan struct{})
+	stopped, cancelStopped := context.WithCancel(context.Background())
+	closer := func() {
+		ticker.Stop()
+		close(ch)
+		cancelStopped()
+		r.unpublishExpvar()
+	}
+	r.ticker, r.closer, r.stopped = ticker, closer, stopped
+
+	if len(r.logAttrs) > 0 {
+		args := make([]any, len(r.logAttrs))
+		for i, attr := range r.logAttrs {
+			args[i] = attr
+		}
+		r.logger = withArgs(r.logger, args)
+	}
+
+	if r.nameserver != nil {
+		r.nameserver.subnet = r.clientSubnet
+		r.nameserver.dnssec = r.dnssec
+		r.nameserver.wireFormat = r.wireFormat
+	}
+
+	if r.reverse != nil {
+		for host, e := range r.static {
+			r.reverse.add(host, e.ips)
+		}
+	}
+
+	if r.resolvConfPath != "" {
+		if err := r.reloadResolvConf(); err != nil {
+			closer()
+			return nil, err
+		}
+	}
+
+	if r.hostsPath != "" {
+		hosts, err := newHostsFile(r.hostsPath)
+		if err != nil {
+			closer()
+			return nil, err
+		}
+		r.hosts = hosts
+	}
+
+	if tick := r.tickInterval(); tick != freq {
+		ticker.Reset(tick)
+	}
+
+	if len(r.warmup) > 0 {
+		if err := r.warmUp(); err != nil {
+			closer()
+			return nil, err
+		}
+	}
+
+	// Publishing is the last fallible step, as it is not undone on failure.
+	if r.expvarPrefix != "" {
+		if err := r.publishExpvar(); err != nil {
+			closer()
+			return nil, err
+		}
+	}
+
+	if r.onDemand {
+		// Entries are only re-resolved by Fetch when they expire.
+		ticker.Stop()
+		close(r.done)
+		return r, nil
+	}
+
+	go pprof.Do(context.Background(), r.refreshLabels(), func(context.Context) {
+		defer close(r.done)
 		for {
 			select {
-			case <-ticker.C:
-				r.Refresh()
-				onRefreshedFn()
+			case <-ticker.C():
+				if r.paused.Load() {
+					continue
+				}
+				r.scheduledRefresh(onRefreshedFn)
 			case <-ch:
 				return
 			}
 		}
-	}()
+	})
 
 	return r, nil
 }
 
+// NewWithContext is same as New, except that the background refreshing stops
+// automatically when ctx is done as if `Stop()` is called, so that the lifetime
+// of the resolver can be bound to e.g. the context of a server or an errgroup.
+func NewWithContext(ctx context.Context, freq time.Duration, lookupTimeout time.Duration, options ...Option) (*Resolver, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r, err := New(freq, lookupTimeout, options...)
+	if err != nil {
+		return nil, err
+	}
+	context.AfterFunc(ctx, r.Stop)
+	return r, nil
+}
+
 // LookupIP lookups IP list from DNS server then it saves result in the cache.
 // If you want to get result from the cache use `Fetch` function.
-func (r *Resolver) LookupIP(ctx context.Context, addr string) ([]net.IP, error) {
-	ips, err := r.lookupIPFn(ctx, addr)
-	if err != nil {
+//
+// Concurrent calls for the same addr share one lookup and its result.
+// If ctx has no deadline, the lookup timeout of the resolver is applied.
+// IP literals, including bracketed IPv6 ones, are returned as they are without
+// being looked up nor cached. It returns an *InvalidHostError, which matches
+// ErrInvalidHost, if addr is not a valid domain name, and a *LookupError, which
+// matches ErrTimeout and ErrNotFound, if the lookup fails.
+// If search domains are configured, only the entry of the name which finally
+// resolved is kept in the cache. With WithStaleFallback, the cached IPs are
+// returned when the lookup fails.
+func (r *Resolver) LookupIP(ctx context.Context, addr string) (ips []net.IP, err error) {
+	if ip := ipLiteral(addr); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	if e, ok := r.static[addr]; ok {
+		return e.ips, nil
+	}
+	if err := validateHost(addr); err != nil {
 		return nil, err
 	}
 
+	ctx, end := r.startSpan(ctx, SpanLookupIP, addr, false)
+	done := r.hooks.lookupStart(ctx, addr)
+	var staleErr error
+	defer func() {
+		done(ips, err, staleErr)
+		end(err)
+	}()
+
+	if _, ok := ctx.Deadline(); !ok && r.lookupTimeout > 0 {
+		var cancelF context.CancelFunc
+		ctx, cancelF = context.WithTimeout(ctx, r.lookupTimeout)
+		defer cancelF()
+	}
+
+	r.lock.RLock()
+	search := len(r.search) > 0
+	r.lock.RUnlock()
+	if search {
+		ips, err = r.lookupSearch(ctx, addr)
+	} else {
+		ips, err = r.lookupIP(ctx, addr)
+	}
+	if err != nil {
+		err = wrapLookupError(addr, err)
+		if stale, ok := r.staleIPs(ctx, addr, err); ok {
+			ips, err, staleErr = stale, nil, err
+		}
+	}
+	return ips, err
+}
+
+// lookupIP looks up addr as it is and saves the result in the cache.
+//
+// The lookup is shared by concurrent callers, so it does not run on the context
+// of the caller which started it, but on a context detached from its
+// cancellation, bounded by the lookup timeout of the resolver and cancelled by
+// Stop. Each caller waits for it until its own ctx is done. A panic of the
+// lookup is raised again in every foreground caller, and recovered as the
+// failure of refreshes.
+func (r *Resolver) lookupIP(ctx context.Context, addr string) ([]net.IP, error) {
+	c := r.group.do(addr, func() (*entry, error) {
+		ctx, cancelF := context.WithDeadline(context.WithoutCancel(ctx), r.sharedLookupDeadline(ctx))
+		defer cancelF()
+		if r.stopped != nil {
+			stop := context.AfterFunc(r.stopped, cancelF)
+			defer stop()
+		}
+
+		start := time.Now()
+		e, err := r.lookup(ctx, addr)
+		d := time.Since(start)
+		r.metrics.lookupDone(addr, d, err)
+		r.latency.observe(addr, d)
+		if r.slowLookup > 0 && d >= r.slowLookup {
+			r.logger.Warn("slow DNS lookup",
+				"addr", addr,
+				"duration", d,
+				"refresh", isRefresh(ctx),
+			)
+		}
+		r.stats.lookups.Add(1)
+		if err != nil {
+			r.recordError(addr, err)
+			r.history.add(addr, nil, err, r.now())
+			return nil, err
+		}
+
+		if len(e.ips) == 0 && r.emptyResults != EmptyResultCache {
+			if r.emptyResults == EmptyResultSkip {
+				r.history.add(addr, nil, nil, r.now())
+				return e, nil
+			}
+			err = errEmptyResult(addr)
+			r.history.add(addr, nil, err, r.now())
+			return nil, err
+		}
+
+		if e.ips, err = r.filter(addr, e.ips); err != nil {
+			r.history.add(addr, nil, err, r.now())
+			return nil, err
+		}
+
+		r.lock.RLock()
+		old, ok := r.cache[addr]
+		r.lock.RUnlock()
+		if ok && old.cname != "" && e.cname == "" {
+			// Keep the canonical name requested by CanonicalName fresh.
+			e.cname = old.cname
+			if cname, err := lookupCNAME(ctx, addr); err == nil {
+				e.cname = cname
+			}
+		}
+
+		r.store(addr, e)
+		r.history.add(addr, e.ips, nil, r.now())
+		return e, nil
+	})
+
+	select {
+	case <-c.done:
+		if c.panicked {
+			if !isRefresh(ctx) {
+				panic(c.recovered)
+			}
+			r.recoverRefresh(addr, c.recovered, c.stack)
+			return nil, panicError(addr, c.recovered)
+		}
+		if c.err != nil {
+			return nil, c.err
+		}
+		return c.entry.ips, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// sharedLookupDeadline returns the deadline of a lookup shared with the caller
+// of ctx. It is the deadline of ctx, but never earlier than the lookup timeout
+// of the resolver for that kind of caller, so that a caller with a short
+// deadline does not fail the lookup for the others.
+func (r *Resolver) sharedLookupDeadline(ctx context.Context) time.Time {
+	timeout := r.lookupTimeout
+	if isRefresh(ctx) {
+		timeout = r.defaultLookupTimeout
+	}
+	if timeout <= 0 {
+		timeout = defaultLookupTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.After(deadline) {
+		deadline = d
+	}
+	return deadline
+}
+
+// store saves the entry of addr in the cache, notifies the listeners when the
+// IP set of addr changes and sends the event of the change.
+func (r *Resolver) store(addr string, e *entry) {
+	e.updated = r.now()
+
 	r.lock.Lock()
-	r.cache[addr] = ips
+	old, ok := r.cache[addr]
+	delete(r.lookupErrors, addr)
+	r.backoff.reset(addr)
+	if r.adaptive != nil {
+		e.interval = r.adaptive.next(old, e, r.intervalFor(addr))
+	}
+	if r.reverse != nil {
+		if ok {
+			r.reverse.remove(addr, old.ips)
+		}
+		r.reverse.add(addr, e.ips)
+	}
+	r.cache[addr] = e
 	r.lock.Unlock()
+
+	switch {
+	case !ok:
+		r.events.send(Event{Type: EntryAdded, Host: addr, New: e.ips, Time: r.now()})
+	case !sameIPs(old.ips, e.ips):
+		r.listeners.notify(addr, old.ips, e.ips)
+		r.events.send(Event{Type: EntryUpdated, Host: addr, Old: old.ips, New: e.ips, Time: r.now()})
+	}
+}
+
+// filter applies the IP filter, address sorting and IP version preference to a
+// lookup result before it is cached. It fails when they leave no IP of a result
+// which had some; empty results are handled by WithEmptyResults instead.
+func (r *Resolver) filter(addr string, ips []net.IP) ([]net.IP, error) {
+	found := len(ips) > 0
+	if r.ipFilter != nil {
+		var err error
+		if ips, err = r.ipFilter(addr, ips); err != nil {
+			return nil, err
+		}
+	}
+
+	if r.sortAddrs {
+		ips = append([]net.IP(nil), ips...)
+		sortByRFC6724(ips)
+	}
+
+	if ips = r.ipVersion.apply(ips); len(ips) == 0 && found {
+		return nil, &net.DNSError{Err: "no suitable address found", Name: addr, IsNotFound: true}
+	}
 	return ips, nil
 }
 
+// lookup calls the lookup function and retries it according to the retry policy.
+// Entries of the hosts file, if configured, take precedence over the lookup function.
+func (r *Resolver) lookup(ctx context.Context, addr string) (*entry, error) {
+	if r.hosts != nil {
+		if ips, ok := r.hosts.lookup(addr); ok {
+			return &entry{ips: ips}, nil
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		e, err := r.lookupUpstream(ctx, addr)
+		if err == nil || attempt >= r.retry.attempts || !retryable(err) {
+			return e, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-r.after(r.retry.delay(attempt)):
+		}
+	}
+}
+
+// lookupUpstream looks up addr once by multicast DNS for .local names if enabled,
+// by the nameserver if configured or read from resolv.conf, or by the lookup
+// function otherwise.
+func (r *Resolver) lookupUpstream(ctx context.Context, addr string) (*entry, error) {
+	if r.mdns && isMDNSName(addr) {
+		return lookupMDNS(ctx, r.network, addr)
+	}
+	if r.nameserver != nil {
+		return r.nameserver.lookup(ctx, r.network, addr)
+	}
+	if ns := r.resolvConfNameserver.Load(); ns != nil {
+		return ns.lookup(ctx, r.network, addr)
+	}
+
+	ips, err := r.lookupIPFn(ctx, r.network, addr)
+	if err != nil {
+		return nil, err
+	}
+	return &entry{ips: ips}, nil
+}
+
+// retryable reports whether a failed lookup is worth retrying.
+// Non-existent hosts, insecure answers and cancelled contexts are not retried.
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrInsecure) {
+		return false
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return false
+	}
+	return true
+}
+
+// retryPolicy describes how failed lookups are retried.
+type retryPolicy struct {
+	attempts  int
+	baseDelay time.Duration
+	jitter    float64
+}
+
+// maxRetryDelay caps the delay before a retry, which doubles for each attempt.
+const maxRetryDelay = time.Minute
+
+// delay returns the backoff before the next try after the given attempt.
+func (p retryPolicy) delay(attempt int) time.Duration {
+	if p.baseDelay <= 0 || attempt < 1 {
+		return 0
+	}
+	d := maxRetryDelay
+	// Shifting by attempt-1 overflows for large attempts, so the delay is only
+	// doubled while it stays below the cap.
+	if shift := attempt - 1; shift < 63 && p.baseDelay <= maxRetryDelay>>shift {
+		d = p.baseDelay << shift
+	}
+	if p.jitter > 0 {
+		d += time.Duration((2*randFloat64() - 1) * p.jitter * float64(d))
+	}
+	return min(d, maxRetryDelay)
+}
+
 // Fetch fetches IP list from the cache. If IP list of the given addr is not in the cache,
-// then it lookups from DNS server by `Lookup` function.
+// then it lookups from DNS server by `Lookup` function. Concurrent misses for the
+// same addr share one upstream lookup. IP literals are returned as they are.
 func (r *Resolver) Fetch(ctx context.Context, addr string) ([]net.IP, error) {
-	r.lock.RLock()
-	ips, ok := r.cache[addr]
-	r.lock.RUnlock()
-	if ok {
+	if ip := ipLiteral(addr); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	if e, ok := r.static[addr]; ok {
+		return r.rotate(e), nil
+	}
+
+	e, ok := r.cached(addr)
+	if ok && r.fresh(e) {
+		r.metrics.cacheHit(addr)
+		r.stats.hits.Add(1)
+		_, end := r.startSpan(ctx, SpanFetch, addr, true)
+		end(nil)
+		return r.rotate(e), nil
+	}
+
+	r.metrics.cacheMiss(addr)
+	r.stats.misses.Add(1)
+	ctx, end := r.startSpan(ctx, SpanFetch, addr, false)
+	ips, err := r.LookupIP(ctx, addr)
+	end(err)
+	if err != nil || r.rotation == NoRotation {
+		return ips, err
+	}
+
+	e, ok = r.cached(addr)
+	if !ok {
 		return ips, nil
 	}
-	return r.LookupIP(ctx, addr)
+	return r.rotate(e), nil
+}
+
+// FetchCached returns the IP list of addr only if Fetch would serve it from the
+// cache, and never looks it up, for latency-critical code paths which would rather
+// fail fast than block on DNS. IP literals and static entries are returned as
+// Fetch does. It reports false if addr is not cached.
+func (r *Resolver) FetchCached(addr string) ([]net.IP, bool) {
+	if ip := ipLiteral(addr); ip != nil {
+		return []net.IP{ip}, true
+	}
+	if e, ok := r.static[addr]; ok {
+		return r.rotate(e), true
+	}
+
+	e, ok := r.cached(addr)
+	if !ok || !r.fresh(e) {
+		return nil, false
+	}
+	r.metrics.cacheHit(addr)
+	r.stats.hits.Add(1)
+	return r.rotate(e), true
+}
+
+// ipLiteral returns the IP of addr if it is an IP literal, which may be an IPv6
+// one in brackets, or nil otherwise.
+func ipLiteral(addr string) net.IP {
+	if len(addr) > 2 && addr[0] == '[' && addr[len(addr)-1] == ']' {
+		addr = addr[1 : len(addr)-1]
+	}
+	return net.ParseIP(addr)
+}
+
+// cached returns the cache entry of addr, following the search name it resolved to.
+func (r *Resolver) cached(addr string) (*entry, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	if name, ok := r.aliases[addr]; ok {
+		addr = name
+	}
+	e, ok := r.cache[addr]
+	return e, ok
 }
 
 // Refresh refreshes IP list cache.
 func (r *Resolver) Refresh() {
+	r.refresh(context.Background(), false)
+}
+
+// RefreshContext refreshes IP list cache like Refresh, but the lookups are
+// cancelled when ctx is done, in which case the remaining hosts are not refreshed
+// and ctx.Err() is returned. Each lookup is still bounded by the refresh timeout.
+func (r *Resolver) RefreshContext(ctx context.Context) error {
+	r.refresh(ctx, false)
+	return ctx.Err()
+}
+
+// RefreshHost forces re-resolution of host without waiting for the next refresh,
+// e.g. when the application knows that the host has just moved, and returns the
+// fresh result, which also replaces the cached one. Unlike Fetch, it never
+// returns the cached IPs. Static entries are returned as they are.
+func (r *Resolver) RefreshHost(ctx context.Context, host string) ([]net.IP, error) {
+	return r.LookupIP(context.WithValue(ctx, noStaleKey{}, true), host)
+}
+
+// refresh refreshes IP list cache and notifies the refresh listener of the
+// summary of the cycle, which is also returned. If scheduled is true, only the
+// hosts whose refresh interval has passed are refreshed.
+func (r *Resolver) refresh(ctx context.Context, scheduled bool) RefreshSummary {
+	start := time.Now()
+	if r.stopped != nil {
+		var cancelF context.CancelFunc
+		ctx, cancelF = context.WithCancel(ctx)
+		defer cancelF()
+		stop := context.AfterFunc(r.stopped, cancelF)
+		defer stop()
+	}
+	refreshCtx, end := r.startSpan(context.WithValue(ctx, refreshKey{}, true), SpanRefresh, "", false)
+	if r.hosts != nil {
+		if err := r.hosts.reload(); err != nil {
+			r.logger.Error("failed to reload hosts file",
+				"error", err,
+				"path", r.hosts.path,
+			)
+		}
+	}
+	if r.resolvConfPath != "" {
+		if err := r.reloadResolvConf(); err != nil {
+			r.logger.Error("failed to reload resolv.conf",
+				"error", err,
+				"path", r.resolvConfPath,
+			)
+		}
+	}
+
+	now := r.now()
 	r.lock.RLock()
 	addrs := make([]string, 0, len(r.cache))
-	for addr := range r.cache {
+	olds := make([][]net.IP, 0, len(r.cache))
+	for addr, e := range r.cache {
+		if scheduled && (!r.due(addr, e, now) || !r.backoff.allow(addr, now)) {
+			continue
+		}
 		addrs = append(addrs, addr)
+		olds = append(olds, e.ips)
 	}
 	r.lock.RUnlock()
 
-	for _, addr := range addrs {
-		ctx, cancelF := context.WithTimeout(context.Background(), r.defaultLookupTimeout)
-		if _, err := r.LookupIP(ctx, addr); err != nil {
-			r.logger.Error("failed to refresh DNS cache",
-				"error", err,
-				"addr", addr,
-			)
+	summary := RefreshSummary{Hosts: len(addrs)}
+	for i, addr := range addrs {
+		if refreshCtx.Err() != nil || r.isStopped() {
+			break
 		}
-		cancelF()
+		pprof.Do(refreshCtx, r.refreshLabels("dnscache.host", addr), func(ctx context.Context) {
+			if r.refreshLimit.wait(ctx, r.now(), r.after) == nil {
+				r.refreshHost(ctx, addr, olds[i], &summary)
+			}
+		})
 	}
+
+	r.refreshRecords(refreshCtx, scheduled, &summary)
+
+	var err error
+	if summary.Failures > 0 {
+		err = refreshError(summary.Failures)
+	}
+	end(err)
+
+	summary.Duration = time.Since(start)
+	r.stats.lastRefresh.Store(r.now().UnixNano())
+	r.stats.lastRefreshFailures.Store(int64(summary.Failures))
+	if summary.Failures > 0 {
+		r.stats.failedRefreshes.Add(1)
+	} else {
+		r.stats.failedRefreshes.Store(0)
+	}
+	r.metrics.refreshDone(summary.Duration, summary.Failures)
+	if r.onRefresh != nil {
+		r.onRefresh(summary)
+	}
+	return summary
+}
+
+// refreshHost refreshes addr whose cached IPs were old and counts the result in
+// summary.
+func (r *Resolver) refreshHost(ctx context.Context, addr string, old []net.IP, summary *RefreshSummary) {
+	lookupCtx, cancelF := context.WithTimeout(ctx, r.defaultLookupTimeout)
+	defer cancelF()
+
+	if _, err := r.LookupIP(lookupCtx, addr); err != nil {
+		if ctx.Err() != nil || r.isStopped() {
+			// The refresh was cancelled, e.g. by Stop, rather than the host failed.
+			// The shared lookup may see Stop before ctx does.
+			return
+		}
+		summary.Failures++
+		r.backoff.fail(addr, r.now())
+		r.logRefreshError(addr, err)
+		err = &RefreshError{Host: addr, Err: err}
+		if r.onRefreshError != nil {
+			r.onRefreshError(addr, err)
+		}
+		r.events.send(Event{Type: RefreshFailed, Host: addr, Old: old, Err: err, Time: r.now()})
+		return
+	}
+
+	r.errorLog.reset(addr)
+	if e, ok := r.cached(addr); ok && !sameIPs(old, e.ips) {
+		summary.Changed++
+	}
+}
+
+// SetRefreshInterval changes the frequency of background refreshes given to New,
+// e.g. to refresh more often during a planned DNS migration. The intervals set by
+// WithHostRefreshInterval are kept. The next tick happens after the new interval
+// from now. It is safe to call concurrently and does nothing after Stop.
+func (r *Resolver) SetRefreshInterval(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.freq = d
+	if r.closer != nil && !r.onDemand {
+		r.ticker.Reset(r.tickInterval())
+	}
+}
+
+// Pause pauses background refreshing, e.g. during a known maintenance window of
+// the upstream resolver, while keeping serving the cache. Foreground lookups and
+// explicit refreshes are not affected. A refresh in progress is not interrupted.
+func (r *Resolver) Pause() {
+	r.paused.Store(true)
+}
+
+// Resume resumes background refreshing paused by Pause from the next tick.
+func (r *Resolver) Resume() {
+	r.paused.Store(false)
+}
+
+// Close stops auto refreshing like Stop so that the resolver can be managed as
+// an io.Closer. The backends of the resolver do not keep connections between
+// lookups, so there is nothing else to release. It always returns nil.
+func (r *Resolver) Close() error {
+	r.Stop()
+	return nil
+}
+
+// StopWait stops auto refreshing like Stop and waits until a refresh in
+// progress, if any, finishes and the background goroutine exits, e.g. before
+// tearing down a test or exiting a process. It returns ctx.Err() if ctx is done
+// before that.
+func (r *Resolver) StopWait(ctx context.Context) error {
+	r.Stop()
+	if r.done == nil {
+		return nil
+	}
+
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isStopped reports whether Stop has been called.
+func (r *Resolver) isStopped() bool {
+	return r.stopped != nil && r.stopped.Err() != nil
 }
 
-// Stop stops auto refreshing.
+// Stop stops auto refreshing. A refresh in progress, including one started by
+// Refresh, stops between hosts and its outstanding lookups are cancelled.
 func (r *Resolver) Stop() {
 	r.lock.Lock()
 	defer r.lock.Unlock()
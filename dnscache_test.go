@@ -3,10 +3,13 @@ package dnscache
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net"
 	"reflect"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -45,6 +48,105 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestNewWithContext(t *testing.T) {
+	ctx, cancelF := context.WithCancel(context.Background())
+	resolver, err := NewWithContext(ctx, testFreq, testDefaultLookupTimeout)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := resolver.Healthy(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cancelF()
+	deadline := time.Now().Add(time.Second)
+	for resolver.Healthy() == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("expect resolver to be stopped")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := NewWithContext(ctx, testFreq, testDefaultLookupTimeout); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v; want context.Canceled", err)
+	}
+}
+
+func TestCacheCapacity(t *testing.T) {
+	resolver, err := New(testFreq, testDefaultLookupTimeout, WithCacheCapacity(10000))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resolver.Stop()
+
+	if resolver.cache == nil || resolver.Len() != 0 {
+		t.Fatalf("expect an empty cache")
+	}
+	resolver.store("deeeet.com", &entry{ips: []net.IP{net.ParseIP("127.0.0.1")}})
+	if got := resolver.Len(); got != 1 {
+		t.Fatalf("got %d; want 1", got)
+	}
+}
+
+func TestClose(t *testing.T) {
+	var closer io.Closer = testResolver(t)
+	if err := closer.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := closer.(*Resolver).Healthy(); !errors.Is(err, ErrResolverStopped) {
+		t.Fatalf("got %v; want ErrResolverStopped", err)
+	}
+	// Closing twice is safe.
+	if err := closer.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestStopWait(t *testing.T) {
+	originalFunc := lookupIP
+	defer func() {
+		lookupIP = originalFunc
+	}()
+
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("127.0.0.1")}, nil
+	}
+
+	// Stop cancels the lookups of a refresh in progress, so block the refresh
+	// listener to keep the background goroutine refreshing.
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	var finished atomic.Bool
+	listener := func(RefreshSummary) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		finished.Store(true)
+	}
+
+	resolver, err := New(10*time.Millisecond, testDefaultLookupTimeout, WithRefreshListener(listener))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	<-started
+
+	ctx, cancelF := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelF()
+	if err := resolver.StopWait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v; want to time out while refreshing", err)
+	}
+
+	close(release)
+	if err := resolver.StopWait(context.Background()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !finished.Load() {
+		t.Fatalf("expect the refresh to be finished")
+	}
+}
+
 func TestLookup(t *testing.T) {
 	cases := []struct {
 		name string
@@ -88,7 +190,7 @@ func TestLookupCache(t *testing.T) {
 	want := []net.IP{
 		net.IP("35.190.50.136"),
 	}
-	lookupIP = func(ctx context.Context, host string) ([]net.IP, error) {
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
 		return want, nil
 	}
 
@@ -110,8 +212,8 @@ func TestLookupCache(t *testing.T) {
 		t.Fatalf("expect cache to be created")
 	}
 
-	if !reflect.DeepEqual(want, got2) {
-		t.Fatalf("want %#v, got %#v", want, got2)
+	if !reflect.DeepEqual(want, got2.ips) {
+		t.Fatalf("want %#v, got %#v", want, got2.ips)
 	}
 }
 
@@ -123,7 +225,7 @@ func TestLookupTimeout(t *testing.T) {
 
 	ctx, cancelF := context.WithTimeout(context.Background(), 100*time.Millisecond)
 	defer cancelF()
-	lookupIP = func(ctx context.Context, host string) ([]net.IP, error) {
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
 		for {
 			select {
 			case <-ctx.Done():
@@ -152,22 +254,22 @@ func TestRefresh(t *testing.T) {
 	want := []net.IP{
 		net.IP("4.4.4.4"),
 	}
-	lookupIP = func(ctx context.Context, host string) ([]net.IP, error) {
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
 		return want, nil
 	}
 
 	resolver := testResolver(t)
 	defer resolver.Stop()
-	resolver.cache = map[string][]net.IP{
-		"deeeet.jp": {
+	resolver.cache = map[string]*entry{
+		"deeeet.jp": {ips: []net.IP{
 			net.IP("1.1.1.1"),
-		},
-		"deeeet.us": {
+		}},
+		"deeeet.us": {ips: []net.IP{
 			net.IP("2.2.2.2"),
-		},
-		"deeeet.uk": {
+		}},
+		"deeeet.uk": {ips: []net.IP{
 			net.IP("3.3.3.3"),
-		},
+		}},
 	}
 
 	// Refresh all IP to same one
@@ -175,12 +277,131 @@ func TestRefresh(t *testing.T) {
 
 	// Ensure all cache are refreshed
 	for _, got := range resolver.cache {
-		if !reflect.DeepEqual(want, got) {
-			t.Fatalf("want %#v, got %#v", want, got)
+		if !reflect.DeepEqual(want, got.ips) {
+			t.Fatalf("want %#v, got %#v", want, got.ips)
 		}
 	}
 }
 
+func TestRefreshContext(t *testing.T) {
+	ctx, cancelF := context.WithCancel(context.Background())
+	lookups := 0
+	r := &Resolver{
+		cache: map[string]*entry{
+			"a.deeeet.com": {ips: []net.IP{net.ParseIP("127.0.0.1")}},
+			"b.deeeet.com": {ips: []net.IP{net.ParseIP("127.0.0.1")}},
+		},
+		lookupIPFn: func(ctx context.Context, network, host string) ([]net.IP, error) {
+			lookups++
+			cancelF()
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+		defaultLookupTimeout: time.Minute,
+		logger:               slog.New(slog.NewTextHandler(new(bytes.Buffer), nil)),
+	}
+
+	if err := r.RefreshContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v; want context.Canceled", err)
+	}
+	if lookups != 1 {
+		t.Fatalf("got %d lookups; want the remaining hosts to be skipped", lookups)
+	}
+}
+
+func TestRefreshHost(t *testing.T) {
+	r := &Resolver{
+		cache: map[string]*entry{
+			"deeeet.com": {ips: []net.IP{net.ParseIP("127.0.0.1")}},
+		},
+		lookupTimeout: time.Second,
+		lookupIPFn: func(ctx context.Context, network, host string) ([]net.IP, error) {
+			return []net.IP{net.ParseIP("127.0.0.2")}, nil
+		},
+	}
+
+	ips, err := r.RefreshHost(context.Background(), "deeeet.com")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(ips) != 1 || !ips[0].Equal(net.ParseIP("127.0.0.2")) {
+		t.Fatalf("got %v; want the fresh IP", ips)
+	}
+	if e, _ := r.cached("deeeet.com"); !e.ips[0].Equal(net.ParseIP("127.0.0.2")) {
+		t.Fatalf("got %v; want the cache to be updated", e.ips)
+	}
+}
+
+func TestSetRefreshInterval(t *testing.T) {
+	originalFunc := onRefreshed
+	defer func() {
+		onRefreshed = originalFunc
+	}()
+
+	refreshed := make(chan struct{}, 1)
+	onRefreshed = func(RefreshSummary) {
+		select {
+		case refreshed <- struct{}{}:
+		default:
+		}
+	}
+
+	resolver, err := New(time.Hour, testDefaultLookupTimeout)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resolver.Stop()
+
+	resolver.SetRefreshInterval(10 * time.Millisecond)
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Fatalf("expect to be refreshed with the new interval")
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	originalFunc := onRefreshed
+	defer func() {
+		onRefreshed = originalFunc
+	}()
+
+	refreshed := make(chan struct{}, 1)
+	onRefreshed = func(RefreshSummary) {
+		select {
+		case refreshed <- struct{}{}:
+		default:
+		}
+	}
+
+	resolver, err := New(10*time.Millisecond, testDefaultLookupTimeout)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resolver.Stop()
+
+	resolver.Pause()
+	// Drain a refresh which may have started before Pause.
+	time.Sleep(20 * time.Millisecond)
+	select {
+	case <-refreshed:
+	default:
+	}
+
+	select {
+	case <-refreshed:
+		t.Fatalf("expect not to be refreshed while paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	resolver.Resume()
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Fatalf("expect to be refreshed after Resume")
+	}
+}
+
 func TestRefreshed(t *testing.T) {
 	originalFunc := onRefreshed
 	defer func() {
@@ -188,7 +409,7 @@ func TestRefreshed(t *testing.T) {
 	}()
 
 	var counter int32
-	onRefreshed = func() {
+	onRefreshed = func(RefreshSummary) {
 		atomic.AddInt32(&counter, 1)
 	}
 
@@ -214,7 +435,7 @@ func TestFetch(t *testing.T) {
 	}()
 
 	var returnIPs []net.IP
-	lookupIP = func(ctx context.Context, host string) ([]net.IP, error) {
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
 		mu.Lock()
 		ips := returnIPs
 		mu.Unlock()
@@ -222,7 +443,15 @@ func TestFetch(t *testing.T) {
 	}
 
 	ctx := context.Background()
-	resolver := testResolver(t)
+	clock := newFakeClock()
+	refreshed := make(chan struct{}, 1)
+	resolver, err := New(testFreq, testDefaultLookupTimeout,
+		WithClock(clock),
+		WithRefreshListener(func(RefreshSummary) { refreshed <- struct{}{} }),
+	)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
 	defer resolver.Stop()
 
 	want1 := []net.IP{
@@ -259,7 +488,8 @@ func TestFetch(t *testing.T) {
 	}
 
 	// Wait until cache is refreshed
-	time.Sleep(2 * time.Second)
+	clock.Advance(testFreq)
+	<-refreshed
 
 	got3, err := resolver.Fetch(ctx, "test.com")
 	if err != nil {
@@ -283,11 +513,11 @@ func TestErrorLog(t *testing.T) {
 	}()
 
 	done := make(chan struct{}, 1)
-	onRefreshed = func() {
+	onRefreshed = func(RefreshSummary) {
 		done <- struct{}{}
 	}
 
-	lookupIP = func(ctx context.Context, host string) ([]net.IP, error) {
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
 		return nil, fmt.Errorf("err")
 	}
 
@@ -305,3 +535,672 @@ func TestErrorLog(t *testing.T) {
 		t.Fatalf("expect logger called more than once")
 	}
 }
+
+func TestLogAttrs(t *testing.T) {
+	buf := new(bytes.Buffer)
+	r, err := New(time.Minute, time.Second,
+		WithLogAttrs(slog.String("resolver", "internal")),
+		WithLogger(slog.New(slog.NewTextHandler(buf, nil))),
+	)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer r.Stop()
+
+	r.logger.Error("failed to refresh DNS cache")
+	if got := buf.String(); !strings.Contains(got, "resolver=internal") {
+		t.Fatalf("got %q; want the attribute to be attached", got)
+	}
+}
+
+func TestRefreshErrorHandler(t *testing.T) {
+	var (
+		hosts []string
+		errs  []error
+	)
+	r := &Resolver{
+		cache: map[string]*entry{
+			"deeeet.com":      {ips: []net.IP{net.ParseIP("127.0.0.1")}},
+			"fail.deeeet.com": {ips: []net.IP{net.ParseIP("127.0.0.2")}},
+		},
+		lookupIPFn: func(ctx context.Context, network, host string) ([]net.IP, error) {
+			if host == "fail.deeeet.com" {
+				return nil, fmt.Errorf("err")
+			}
+			return []net.IP{net.ParseIP("127.0.0.1")}, nil
+		},
+		defaultLookupTimeout: time.Second,
+		logger:               slog.New(slog.NewTextHandler(new(bytes.Buffer), nil)),
+	}
+	WithRefreshErrorHandler(func(host string, err error) {
+		hosts = append(hosts, host)
+		errs = append(errs, err)
+	}).apply(r)
+
+	r.Refresh()
+	if len(hosts) != 1 || hosts[0] != "fail.deeeet.com" || errs[0] == nil {
+		t.Fatalf("got %v, %v; want an error of fail.deeeet.com", hosts, errs)
+	}
+}
+
+func TestSlowLookupThreshold(t *testing.T) {
+	buf := new(bytes.Buffer)
+	r := &Resolver{
+		cache:         map[string]*entry{},
+		lookupTimeout: time.Second,
+		lookupIPFn: func(ctx context.Context, network, host string) ([]net.IP, error) {
+			if host == "slow.deeeet.com" {
+				time.Sleep(20 * time.Millisecond)
+			}
+			return []net.IP{net.ParseIP("127.0.0.1")}, nil
+		},
+		logger: slog.New(slog.NewTextHandler(buf, nil)),
+	}
+	WithSlowLookupThreshold(10 * time.Millisecond).apply(r)
+
+	r.LookupIP(context.Background(), "deeeet.com")
+	if buf.Len() != 0 {
+		t.Fatalf("got %q; want a fast lookup not to be logged", buf.String())
+	}
+
+	r.LookupIP(context.Background(), "slow.deeeet.com")
+	if got := buf.String(); !strings.Contains(got, "slow DNS lookup") || !strings.Contains(got, "addr=slow.deeeet.com") {
+		t.Fatalf("got %q; want the slow lookup to be logged", got)
+	}
+}
+
+func TestLookupRetry(t *testing.T) {
+	originalFunc := lookupIP
+	defer func() {
+		lookupIP = originalFunc
+	}()
+
+	want := []net.IP{
+		net.IP("10.0.0.1"),
+	}
+	var calls int32
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			return nil, &net.DNSError{Err: "server misbehaving", Name: host, IsTemporary: true}
+		}
+		return want, nil
+	}
+
+	resolver, err := New(testFreq, testDefaultLookupTimeout, WithRetry(3, time.Millisecond, 0.5))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resolver.Stop()
+
+	got, err := resolver.LookupIP(context.Background(), "retry.io")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("want %#v, got %#v", want, got)
+	}
+	if got, want := atomic.LoadInt32(&calls), int32(3); got != want {
+		t.Fatalf("got %d lookups, want %d", got, want)
+	}
+}
+
+func TestLookupRetryNotFound(t *testing.T) {
+	originalFunc := lookupIP
+	defer func() {
+		lookupIP = originalFunc
+	}()
+
+	var calls int32
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	resolver, err := New(testFreq, testDefaultLookupTimeout, WithRetry(3, time.Millisecond, 0))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resolver.Stop()
+
+	if _, err := resolver.LookupIP(context.Background(), "retry.io"); err == nil {
+		t.Fatalf("expect to be failed")
+	}
+	if got, want := atomic.LoadInt32(&calls), int32(1); got != want {
+		t.Fatalf("got %d lookups, want %d", got, want)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	p := retryPolicy{attempts: 100, baseDelay: 100 * time.Millisecond}
+	cases := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{10, 51200 * time.Millisecond},
+		{11, maxRetryDelay},
+		{64, maxRetryDelay},
+		{1000, maxRetryDelay},
+	}
+	for _, tc := range cases {
+		if got := p.delay(tc.attempt); got != tc.want {
+			t.Errorf("attempt %d: got %s; want %s", tc.attempt, got, tc.want)
+		}
+	}
+
+	// The jitter does not exceed the cap either.
+	originalRand := randFloat64
+	defer func() {
+		randFloat64 = originalRand
+	}()
+	randFloat64 = func() float64 { return 1 }
+	p.jitter = 0.5
+	if got := p.delay(1000); got != maxRetryDelay {
+		t.Fatalf("got %s; want %s", got, maxRetryDelay)
+	}
+	if got, want := p.delay(1), 150*time.Millisecond; got != want {
+		t.Fatalf("got %s; want %s", got, want)
+	}
+}
+
+func TestLookupSingleflight(t *testing.T) {
+	originalFunc := lookupIP
+	defer func() {
+		lookupIP = originalFunc
+	}()
+
+	want := []net.IP{
+		net.IP("10.0.0.1"),
+	}
+	var calls int32
+	release := make(chan struct{})
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return want, nil
+	}
+
+	resolver := testResolver(t)
+	defer resolver.Stop()
+
+	const n = 100
+	var wg sync.WaitGroup
+	errCh := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := resolver.Fetch(context.Background(), "cold.io")
+			if err != nil {
+				errCh <- err
+				return
+			}
+			if !reflect.DeepEqual(want, got) {
+				errCh <- fmt.Errorf("want %#v, got %#v", want, got)
+			}
+		}()
+	}
+
+	// Give all goroutines a chance to join the in-flight lookup.
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errCh)
+
+	for err := range errCh {
+		t.Fatalf("err: %s", err)
+	}
+	if got, want := atomic.LoadInt32(&calls), int32(1); got != want {
+		t.Fatalf("got %d lookups, want %d", got, want)
+	}
+}
+
+func TestLookupSingleflight_perHost(t *testing.T) {
+	originalFunc := lookupIP
+	defer func() {
+		lookupIP = originalFunc
+	}()
+
+	var mu sync.Mutex
+	calls := map[string]int{}
+	release := make(chan struct{})
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		mu.Lock()
+		calls[host]++
+		mu.Unlock()
+		<-release
+		return []net.IP{net.IP("10.0.0.1")}, nil
+	}
+
+	resolver := testResolver(t)
+	defer resolver.Stop()
+
+	hosts := []string{"a.io", "b.io", "c.io"}
+	var wg sync.WaitGroup
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func(host string) {
+			defer wg.Done()
+			if _, err := resolver.Fetch(context.Background(), host); err != nil {
+				t.Errorf("err: %s", err)
+			}
+		}(hosts[i%len(hosts)])
+	}
+
+	// Give all goroutines a chance to join the in-flight lookups.
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for _, host := range hosts {
+		if got := calls[host]; got != 1 {
+			t.Fatalf("%s: got %d lookups, want 1", host, got)
+		}
+	}
+}
+
+func TestLookupSingleflight_context(t *testing.T) {
+	originalFunc := lookupIP
+	defer func() {
+		lookupIP = originalFunc
+	}()
+
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		started <- struct{}{}
+		select {
+		case <-release:
+			return []net.IP{net.ParseIP("10.0.0.1")}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	resolver := testResolver(t)
+	defer resolver.Stop()
+
+	// The first caller gives up while the lookup is in flight.
+	ctx, cancelF := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := resolver.LookupIP(ctx, "deeeet.com")
+		firstErr <- err
+	}()
+	<-started
+
+	secondErr := make(chan error, 1)
+	go func() {
+		_, err := resolver.LookupIP(context.Background(), "deeeet.com")
+		secondErr <- err
+	}()
+
+	// Give the second caller a chance to join the in-flight lookup.
+	time.Sleep(50 * time.Millisecond)
+	cancelF()
+	if err := <-firstErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v; want context.Canceled", err)
+	}
+
+	close(release)
+	if err := <-secondErr; err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, ok := resolver.cached("deeeet.com"); !ok {
+		t.Fatalf("expect the result of the shared lookup to be cached")
+	}
+}
+
+func TestLookupSingleflight_panic(t *testing.T) {
+	originalFunc := lookupIP
+	defer func() {
+		lookupIP = originalFunc
+	}()
+
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		panic("lookup panic")
+	}
+
+	resolver := testResolver(t)
+	defer resolver.Stop()
+	resolver.logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	func() {
+		defer func() {
+			if v := recover(); v != "lookup panic" {
+				t.Fatalf("got %v; want the panic of the lookup", v)
+			}
+		}()
+		resolver.LookupIP(context.Background(), "deeeet.com")
+	}()
+
+	// A refresh gets the panic as an error instead.
+	_, err := resolver.lookupIP(context.WithValue(context.Background(), refreshKey{}, true), "deeeet.com")
+	if err == nil {
+		t.Fatalf("expect the panic to be returned as an error")
+	}
+}
+
+func TestStaticEntries(t *testing.T) {
+	originalFunc := lookupIP
+	defer func() {
+		lookupIP = originalFunc
+	}()
+
+	var calls int32
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, fmt.Errorf("err")
+	}
+
+	want := []net.IP{
+		net.IP("10.0.0.1"),
+	}
+	resolver, err := New(testFreq, testDefaultLookupTimeout, WithStaticEntries(map[string][]net.IP{
+		"pinned.io": want,
+	}))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resolver.Stop()
+
+	ctx := context.Background()
+	for _, fn := range []func(context.Context, string) ([]net.IP, error){resolver.Fetch, resolver.LookupIP} {
+		got, err := fn(ctx, "pinned.io")
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Fatalf("want %#v, got %#v", want, got)
+		}
+	}
+
+	resolver.Refresh()
+	if got := atomic.LoadInt32(&calls); got != 0 {
+		t.Fatalf("expect static entry not to be looked up, got %d lookups", got)
+	}
+}
+
+func TestNetwork(t *testing.T) {
+	originalFunc := lookupIP
+	defer func() {
+		lookupIP = originalFunc
+	}()
+
+	got := make(chan string, 1)
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		got <- network
+		return []net.IP{net.ParseIP("10.0.0.1")}, nil
+	}
+
+	resolver, err := New(testFreq, testDefaultLookupTimeout, WithNetwork("ip4"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resolver.Stop()
+
+	if _, err := resolver.LookupIP(context.Background(), "v4.mercari.io"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if got, want := <-got, "ip4"; got != want {
+		t.Fatalf("got network %q, want %q", got, want)
+	}
+
+	if _, err := New(testFreq, testDefaultLookupTimeout, WithNetwork("tcp")); err == nil {
+		t.Fatalf("expect to be failed")
+	}
+}
+
+func TestIPFilter(t *testing.T) {
+	originalFunc := lookupIP
+	defer func() {
+		lookupIP = originalFunc
+	}()
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		return []net.IP{
+			net.ParseIP("169.254.0.1"),
+			net.ParseIP("10.0.0.1"),
+		}, nil
+	}
+
+	errDenied := errors.New("denied")
+	filter := func(host string, ips []net.IP) ([]net.IP, error) {
+		if host == "denied.io" {
+			return nil, errDenied
+		}
+		var filtered []net.IP
+		for _, ip := range ips {
+			if !ip.IsLinkLocalUnicast() {
+				filtered = append(filtered, ip)
+			}
+		}
+		return filtered, nil
+	}
+
+	resolver, err := New(testFreq, testDefaultLookupTimeout, WithIPFilter(filter))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resolver.Stop()
+
+	ctx := context.Background()
+	got, err := resolver.LookupIP(ctx, "allowed.io")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if want := []net.IP{net.ParseIP("10.0.0.1")}; !reflect.DeepEqual(want, got) {
+		t.Fatalf("want %#v, got %#v", want, got)
+	}
+
+	if _, err := resolver.LookupIP(ctx, "denied.io"); !errors.Is(err, errDenied) {
+		t.Fatalf("got error %v, want %v", err, errDenied)
+	}
+	if _, ok := resolver.cache["denied.io"]; ok {
+		t.Fatalf("expect filtered lookup not to be cached")
+	}
+}
+
+func TestLookupAndRefreshTimeout(t *testing.T) {
+	originalFunc := lookupIP
+	defer func() {
+		lookupIP = originalFunc
+	}()
+
+	deadlines := make(chan time.Duration, 2)
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			return nil, fmt.Errorf("expect context to have deadline")
+		}
+		deadlines <- time.Until(deadline)
+		return []net.IP{net.IP("10.0.0.1")}, nil
+	}
+
+	resolver, err := New(time.Hour, time.Hour,
+		WithLookupTimeout(time.Second),
+		WithRefreshTimeout(time.Minute),
+	)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resolver.Stop()
+
+	if _, err := resolver.LookupIP(context.Background(), "gateway.io"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if got := <-deadlines; got > time.Second {
+		t.Fatalf("got foreground timeout %s, want at most 1s", got)
+	}
+
+	// The deadline of the caller is kept as it is.
+	ctx, cancelF := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancelF()
+	if _, err := resolver.LookupIP(ctx, "deadline.io"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if got := <-deadlines; got <= time.Minute {
+		t.Fatalf("got foreground timeout %s, want the deadline of the caller", got)
+	}
+
+	resolver.Refresh()
+	for i := 0; i < 2; i++ {
+		if got := <-deadlines; got <= time.Second || got > time.Minute {
+			t.Fatalf("got refresh timeout %s, want at most 1m", got)
+		}
+	}
+}
+
+func TestIPLiteral(t *testing.T) {
+	originalFunc := lookupIP
+	defer func() {
+		lookupIP = originalFunc
+	}()
+
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		return nil, fmt.Errorf("expect %s not to be looked up", host)
+	}
+
+	resolver := testResolver(t)
+	defer resolver.Stop()
+
+	cases := map[string]string{
+		"10.0.0.1":    "10.0.0.1",
+		"2001:db8::1": "2001:db8::1",
+		"[::1]":       "::1",
+	}
+	for addr, want := range cases {
+		for _, f := range []func(context.Context, string) ([]net.IP, error){resolver.Fetch, resolver.LookupIP} {
+			ips, err := f(context.Background(), addr)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if len(ips) != 1 || !ips[0].Equal(net.ParseIP(want)) {
+				t.Fatalf("%s: got %v; want %s", addr, ips, want)
+			}
+		}
+	}
+	if got := resolver.Len(); got != 0 {
+		t.Fatalf("got %d entries; want literals not to be cached", got)
+	}
+}
+
+func TestFetchCached(t *testing.T) {
+	lookups := 0
+	r := &Resolver{
+		cache: map[string]*entry{
+			"deeeet.com": {ips: []net.IP{net.ParseIP("127.0.0.1")}},
+		},
+		static: map[string]*entry{
+			"static.deeeet.com": {ips: []net.IP{net.ParseIP("127.0.0.2")}},
+		},
+		lookupIPFn: func(ctx context.Context, network, host string) ([]net.IP, error) {
+			lookups++
+			return []net.IP{net.ParseIP("127.0.0.3")}, nil
+		},
+	}
+
+	cases := []struct {
+		host string
+		want string
+	}{
+		{"deeeet.com", "127.0.0.1"},
+		{"static.deeeet.com", "127.0.0.2"},
+		{"10.0.0.1", "10.0.0.1"},
+		{"uncached.deeeet.com", ""},
+	}
+	for _, tc := range cases {
+		ips, ok := r.FetchCached(tc.host)
+		if ok != (tc.want != "") {
+			t.Fatalf("%s: got %v; want %v", tc.host, ok, tc.want != "")
+		}
+		if ok && (len(ips) != 1 || !ips[0].Equal(net.ParseIP(tc.want))) {
+			t.Fatalf("%s: got %v; want %s", tc.host, ips, tc.want)
+		}
+	}
+	if lookups != 0 {
+		t.Fatalf("got %d lookups; want none", lookups)
+	}
+}
+
+func TestStop_cancelUpstream(t *testing.T) {
+	originalFunc := lookupIP
+	defer func() {
+		lookupIP = originalFunc
+	}()
+
+	ctxs := make(chan context.Context, 1)
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		ctxs <- ctx
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	resolver, err := New(time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resolver.cache["a.io"] = &entry{ips: []net.IP{net.IP("10.0.0.1")}}
+
+	go resolver.refresh(context.Background(), false)
+	upstream := <-ctxs
+	resolver.Stop()
+
+	select {
+	case <-upstream.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expect the upstream lookup to be cancelled by Stop")
+	}
+	if e, _ := resolver.cached("a.io"); !e.ips[0].Equal(net.IP("10.0.0.1")) {
+		t.Fatalf("expect the cancelled lookup not to be stored")
+	}
+}
+
+func TestStop_cancelRefresh(t *testing.T) {
+	originalFunc := lookupIP
+	defer func() {
+		lookupIP = originalFunc
+	}()
+
+	started := make(chan struct{}, 2)
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		started <- struct{}{}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	var failures int32
+	resolver, err := New(time.Hour, time.Hour,
+		WithRefreshErrorHandler(func(host string, err error) {
+			atomic.AddInt32(&failures, 1)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resolver.cache["a.io"] = &entry{ips: []net.IP{net.IP("10.0.0.1")}}
+	resolver.cache["b.io"] = &entry{ips: []net.IP{net.IP("10.0.0.1")}}
+
+	done := make(chan RefreshSummary)
+	go func() {
+		done <- resolver.refresh(context.Background(), false)
+	}()
+	<-started
+	resolver.Stop()
+
+	select {
+	case summary := <-done:
+		if summary.Failures != 0 {
+			t.Fatalf("got %d failures; want cancelled lookups not to fail", summary.Failures)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expect the refresh to be cancelled by Stop")
+	}
+	if len(started) != 0 {
+		t.Fatalf("expect the refresh to stop between hosts")
+	}
+	if got := atomic.LoadInt32(&failures); got != 0 {
+		t.Fatalf("got %d refresh errors; want none", got)
+	}
+}
@@ -0,0 +1,315 @@
+package dnscache
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+)
+
+// DNS resource record types used by this package.
+const (
+	typeA     uint16 = 1
+	typeNS    uint16 = 2
+	typeCNAME uint16 = 5
+	typeSOA   uint16 = 6
+	typePTR   uint16 = 12
+	typeMX    uint16 = 15
+	typeTXT   uint16 = 16
+	typeAAAA  uint16 = 28
+	typeSRV   uint16 = 33
+	typeOPT   uint16 = 41
+
+	classINET uint16 = 1
+
+	// classCacheFlush is the cache-flush bit of the class in multicast DNS answers.
+	classCacheFlush uint16 = 1 << 15
+)
+
+// DNS header flags and response codes.
+const (
+	flagQR uint16 = 1 << 15
+	flagAA uint16 = 1 << 10
+	flagTC uint16 = 1 << 9
+	flagRD uint16 = 1 << 8
+	flagRA uint16 = 1 << 7
+	flagAD uint16 = 1 << 5
+
+	rcodeMask     uint16 = 0xf
+	rcodeSuccess  uint16 = 0
+	rcodeServFail uint16 = 2
+	rcodeNXDomain uint16 = 3
+	rcodeNotImp   uint16 = 4
+	rcodeRefused  uint16 = 5
+)
+
+// maxUDPSize is the EDNS0 UDP payload size advertised in queries.
+const maxUDPSize = 1232
+
+var (
+	errShortMessage = errors.New("dnscache: short DNS message")
+	errInvalidName  = errors.New("dnscache: invalid DNS name")
+	errTooManyPtrs  = errors.New("dnscache: too many DNS name compression pointers")
+)
+
+// dnsQuestion is an entry of the question section of a DNS message.
+type dnsQuestion struct {
+	name  string
+	typ   uint16
+	class uint16
+}
+
+// dnsRR is a resource record of a DNS message. Names embedded in data are
+// always stored uncompressed so that data can be packed as it is.
+type dnsRR struct {
+	name  string
+	typ   uint16
+	class uint16
+	ttl   uint32
+	data  []byte
+}
+
+// dnsMessage is a minimal representation of a DNS message (RFC 1035).
+type dnsMessage struct {
+	id          uint16
+	flags       uint16
+	questions   []dnsQuestion
+	answers     []dnsRR
+	authorities []dnsRR
+	additionals []dnsRR
+
+	// raw is the wire format the message was parsed from.
+	raw []byte
+}
+
+// rcode returns the response code of the message.
+func (m *dnsMessage) rcode() uint16 {
+	return m.flags & rcodeMask
+}
+
+// pack encodes the message into wire format without name compression.
+func (m *dnsMessage) pack() ([]byte, error) {
+	b := make([]byte, 12, 512)
+	binary.BigEndian.PutUint16(b[0:], m.id)
+	binary.BigEndian.PutUint16(b[2:], m.flags)
+	binary.BigEndian.PutUint16(b[4:], uint16(len(m.questions)))
+	binary.BigEndian.PutUint16(b[6:], uint16(len(m.answers)))
+	binary.BigEndian.PutUint16(b[8:], uint16(len(m.authorities)))
+	binary.BigEndian.PutUint16(b[10:], uint16(len(m.additionals)))
+
+	var err error
+	for _, q := range m.questions {
+		if b, err = appendName(b, q.name); err != nil {
+			return nil, err
+		}
+		b = binary.BigEndian.AppendUint16(b, q.typ)
+		b = binary.BigEndian.AppendUint16(b, q.class)
+	}
+
+	for _, section := range [][]dnsRR{m.answers, m.authorities, m.additionals} {
+		for _, rr := range section {
+			if b, err = appendName(b, rr.name); err != nil {
+				return nil, err
+			}
+			b = binary.BigEndian.AppendUint16(b, rr.typ)
+			b = binary.BigEndian.AppendUint16(b, rr.class)
+			b = binary.BigEndian.AppendUint32(b, rr.ttl)
+			b = binary.BigEndian.AppendUint16(b, uint16(len(rr.data)))
+			b = append(b, rr.data...)
+		}
+	}
+
+	return b, nil
+}
+
+// parseMessage decodes a DNS message in wire format.
+func parseMessage(b []byte) (*dnsMessage, error) {
+	if len(b) < 12 {
+		return nil, errShortMessage
+	}
+
+	m := &dnsMessage{
+		id:    binary.BigEndian.Uint16(b[0:]),
+		flags: binary.BigEndian.Uint16(b[2:]),
+		raw:   b,
+	}
+	qdcount := int(binary.BigEndian.Uint16(b[4:]))
+	counts := [3]int{
+		int(binary.BigEndian.Uint16(b[6:])),
+		int(binary.BigEndian.Uint16(b[8:])),
+		int(binary.BigEndian.Uint16(b[10:])),
+	}
+
+	off := 12
+	for i := 0; i < qdcount; i++ {
+		name, n, err := readName(b, off)
+		if err != nil {
+			return nil, err
+		}
+		off = n
+		if len(b) < off+4 {
+			return nil, errShortMessage
+		}
+		m.questions = append(m.questions, dnsQuestion{
+			name:  name,
+			typ:   binary.BigEndian.Uint16(b[off:]),
+			class: binary.BigEndian.Uint16(b[off+2:]),
+		})
+		off += 4
+	}
+
+	sections := [3]*[]dnsRR{&m.answers, &m.authorities, &m.additionals}
+	for i, section := range sections {
+		for j := 0; j < counts[i]; j++ {
+			rr, n, err := readRR(b, off)
+			if err != nil {
+				return nil, err
+			}
+			off = n
+			*section = append(*section, rr)
+		}
+	}
+
+	return m, nil
+}
+
+// readRR reads a resource record starting at off and returns it with the offset
+// following it.
+func readRR(b []byte, off int) (dnsRR, int, error) {
+	name, off, err := readName(b, off)
+	if err != nil {
+		return dnsRR{}, 0, err
+	}
+	if len(b) < off+10 {
+		return dnsRR{}, 0, errShortMessage
+	}
+
+	rr := dnsRR{
+		name:  name,
+		typ:   binary.BigEndian.Uint16(b[off:]),
+		class: binary.BigEndian.Uint16(b[off+2:]),
+		ttl:   binary.BigEndian.Uint32(b[off+4:]),
+	}
+	length := int(binary.BigEndian.Uint16(b[off+8:]))
+	off += 10
+	end := off + length
+	if len(b) < end {
+		return dnsRR{}, 0, errShortMessage
+	}
+
+	if rr.data, err = readRData(b, rr.typ, off, end); err != nil {
+		return dnsRR{}, 0, err
+	}
+	return rr, end, nil
+}
+
+// readRData copies the record data in b[off:end], expanding compressed names
+// of the record types which may contain them.
+func readRData(b []byte, typ uint16, off, end int) ([]byte, error) {
+	var (
+		fixed int // length of the fixed-size fields preceding the name
+		names int // number of names to expand
+	)
+	switch typ {
+	case typeCNAME, typeNS, typePTR:
+		names = 1
+	case typeMX:
+		fixed, names = 2, 1
+	case typeSRV:
+		fixed, names = 6, 1
+	case typeSOA:
+		names = 2
+	default:
+		return append([]byte(nil), b[off:end]...), nil
+	}
+
+	if end-off < fixed {
+		return nil, errShortMessage
+	}
+	data := append([]byte(nil), b[off:off+fixed]...)
+	off += fixed
+	for i := 0; i < names; i++ {
+		name, n, err := readName(b[:end], off)
+		if err != nil {
+			return nil, err
+		}
+		if data, err = appendName(data, name); err != nil {
+			return nil, err
+		}
+		off = n
+	}
+	return append(data, b[off:end]...), nil
+}
+
+// readName reads a possibly compressed domain name starting at off and returns
+// it as a FQDN with the offset following it.
+func readName(b []byte, off int) (string, int, error) {
+	var (
+		sb   strings.Builder
+		next = -1
+		ptrs = 0
+	)
+	for {
+		if off >= len(b) {
+			return "", 0, errShortMessage
+		}
+		c := int(b[off])
+		switch c & 0xc0 {
+		case 0x00:
+			if c == 0 {
+				off++
+				if next < 0 {
+					next = off
+				}
+				if sb.Len() == 0 {
+					sb.WriteByte('.')
+				}
+				return sb.String(), next, nil
+			}
+			if len(b) < off+1+c {
+				return "", 0, errShortMessage
+			}
+			sb.Write(b[off+1 : off+1+c])
+			sb.WriteByte('.')
+			off += 1 + c
+		case 0xc0:
+			if len(b) < off+2 {
+				return "", 0, errShortMessage
+			}
+			if ptrs++; ptrs > 10 {
+				return "", 0, errTooManyPtrs
+			}
+			if next < 0 {
+				next = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(b[off:]) & 0x3fff)
+		default:
+			return "", 0, errInvalidName
+		}
+	}
+}
+
+// appendName appends the given domain name to b in uncompressed wire format.
+func appendName(b []byte, name string) ([]byte, error) {
+	name = strings.TrimSuffix(name, ".")
+	if len(name) > 253 {
+		return nil, errInvalidName
+	}
+	if name != "" {
+		for _, label := range strings.Split(name, ".") {
+			if len(label) == 0 || len(label) > 63 {
+				return nil, errInvalidName
+			}
+			b = append(b, byte(len(label)))
+			b = append(b, label...)
+		}
+	}
+	return append(b, 0), nil
+}
+
+// fqdn returns the host as a fully qualified domain name.
+func fqdn(host string) string {
+	if strings.HasSuffix(host, ".") {
+		return host
+	}
+	return host + "."
+}
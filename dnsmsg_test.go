@@ -0,0 +1,85 @@
+package dnscache
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMessagePackParse(t *testing.T) {
+	cname, err := appendName(nil, "edge.mercari.io.")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	want := &dnsMessage{
+		id:    0xbeef,
+		flags: flagQR | flagRD | flagRA,
+		questions: []dnsQuestion{
+			{name: "api.mercari.io.", typ: typeA, class: classINET},
+		},
+		answers: []dnsRR{
+			{name: "api.mercari.io.", typ: typeCNAME, class: classINET, ttl: 300, data: cname},
+			{name: "edge.mercari.io.", typ: typeA, class: classINET, ttl: 60, data: []byte{10, 0, 0, 1}},
+		},
+		additionals: []dnsRR{
+			{name: ".", typ: typeOPT, class: maxUDPSize},
+		},
+	}
+
+	b, err := want.pack()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	got, err := parseMessage(b)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	want.raw = b
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("want %#v, got %#v", want, got)
+	}
+}
+
+func TestParseMessageCompression(t *testing.T) {
+	b := []byte{
+		0x12, 0x34, 0x81, 0x80, 0, 1, 0, 1, 0, 0, 0, 0,
+		// question: api.mercari.io. A IN
+		3, 'a', 'p', 'i', 7, 'm', 'e', 'r', 'c', 'a', 'r', 'i', 2, 'i', 'o', 0, 0, 1, 0, 1,
+		// answer: pointer to the question name, CNAME IN, TTL 60
+		0xc0, 12, 0, 5, 0, 1, 0, 0, 0, 60, 0, 6,
+		// rdata: www + pointer to mercari.io.
+		3, 'w', 'w', 'w', 0xc0, 16,
+	}
+
+	m, err := parseMessage(b)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if got, want := m.answers[0].name, "api.mercari.io."; got != want {
+		t.Fatalf("got name %q, want %q", got, want)
+	}
+
+	target, _, err := readName(m.answers[0].data, 0)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if got, want := target, "www.mercari.io."; got != want {
+		t.Fatalf("got target %q, want %q", got, want)
+	}
+}
+
+func TestParseMessageError(t *testing.T) {
+	cases := [][]byte{
+		{0x12, 0x34},
+		// truncated question name
+		{0x12, 0x34, 0x81, 0x80, 0, 1, 0, 0, 0, 0, 0, 0, 3, 'a'},
+		// pointer loop
+		{0x12, 0x34, 0x81, 0x80, 0, 1, 0, 0, 0, 0, 0, 0, 0xc0, 12, 0, 1, 0, 1},
+	}
+
+	for _, b := range cases {
+		if _, err := parseMessage(b); err == nil {
+			t.Fatalf("expect to be failed: %v", b)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package dnscache
+
+import "errors"
+
+// ErrInsecure is returned when DNSSEC validation is required but the nameserver
+// did not mark the answer as authenticated.
+var ErrInsecure = errors.New("dnscache: DNS answer is not DNSSEC validated")
+
+// Validation is the DNSSEC validation status of a cached entry.
+type Validation int
+
+const (
+	// ValidationNone means that DNSSEC validation is not enabled for the entry.
+	ValidationNone Validation = iota
+
+	// ValidationInsecure means that the nameserver did not authenticate the answer,
+	// e.g. because the zone is not signed.
+	ValidationInsecure
+
+	// ValidationSecure means that the nameserver authenticated the answer by DNSSEC.
+	ValidationSecure
+)
+
+// String returns the name of the validation status.
+func (v Validation) String() string {
+	switch v {
+	case ValidationInsecure:
+		return "insecure"
+	case ValidationSecure:
+		return "secure"
+	default:
+		return "none"
+	}
+}
+
+// dnssecMode controls DNSSEC handling of the nameserver.
+type dnssecMode int
+
+const (
+	dnssecOff dnssecMode = iota
+	dnssecCheck
+	dnssecRequire
+)
+
+// Validation returns the DNSSEC validation status of the cached entry of the given addr.
+// It returns false if addr is not in the cache. Like Messages, it follows the search
+// name addr resolved to.
+func (r *Resolver) Validation(addr string) (Validation, bool) {
+	e, ok := r.cached(addr)
+	if !ok {
+		return ValidationNone, false
+	}
+	return e.validation, true
+}
@@ -0,0 +1,78 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestDNSSEC(t *testing.T) {
+	signed := map[string]bool{
+		"signed.mercari.io.":   true,
+		"unsigned.mercari.io.": false,
+	}
+	addr := testNameserver(t, func(q *dnsMessage, tcp bool) *dnsMessage {
+		var do bool
+		for _, rr := range q.additionals {
+			do = do || rr.typ == typeOPT && rr.ttl&ednsFlagDO != 0
+		}
+		m := answerA(q, net.ParseIP("10.0.0.1"))
+		if do && signed[q.questions[0].name] {
+			m.flags |= flagAD
+		}
+		return m
+	})
+
+	cases := []struct {
+		require bool
+		host    string
+		want    Validation
+		wantErr error
+	}{
+		{false, "signed.mercari.io", ValidationSecure, nil},
+		{false, "unsigned.mercari.io", ValidationInsecure, nil},
+		{true, "signed.mercari.io", ValidationSecure, nil},
+		{true, "unsigned.mercari.io", ValidationNone, ErrInsecure},
+	}
+
+	for _, tc := range cases {
+		resolver, err := New(testFreq, testDefaultLookupTimeout, WithNameserver(addr), WithDNSSEC(tc.require))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		_, err = resolver.LookupIP(context.Background(), tc.host)
+		if !errors.Is(err, tc.wantErr) {
+			t.Fatalf("%s: got error %v, want %v", tc.host, err, tc.wantErr)
+		}
+
+		got, ok := resolver.Validation(tc.host)
+		if ok != (tc.wantErr == nil) {
+			t.Fatalf("%s: expect entry to be cached only when succeeded", tc.host)
+		}
+		if got != tc.want {
+			t.Fatalf("%s: got validation %s, want %s", tc.host, got, tc.want)
+		}
+		resolver.Stop()
+	}
+}
+
+func TestDNSSECWithoutNameserver(t *testing.T) {
+	if _, err := New(testFreq, testDefaultLookupTimeout, WithDNSSEC(true)); err == nil {
+		t.Fatalf("expect to be failed")
+	}
+}
+
+func TestValidationSearchName(t *testing.T) {
+	resolver := &Resolver{
+		cache: map[string]*entry{
+			"api.mercari.io": {ips: []net.IP{net.ParseIP("10.0.0.1")}, validation: ValidationSecure},
+		},
+		aliases: map[string]string{"api": "api.mercari.io"},
+	}
+
+	if got, ok := resolver.Validation("api"); !ok || got != ValidationSecure {
+		t.Fatalf("got validation %s, %v, want %s of the search name", got, ok, ValidationSecure)
+	}
+}
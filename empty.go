@@ -0,0 +1,40 @@
+package dnscache
+
+import (
+	"net"
+	"strconv"
+)
+
+// EmptyResultPolicy controls how lookups which succeed without any IP are handled.
+type EmptyResultPolicy int
+
+const (
+	// EmptyResultCache caches and returns no IPs without an error like any other
+	// result. It is the default.
+	EmptyResultCache EmptyResultPolicy = iota
+
+	// EmptyResultError fails lookups without any IP with a not found
+	// *net.DNSError, so that they are never cached.
+	EmptyResultError
+
+	// EmptyResultSkip returns no IPs without an error but does not cache them, so
+	// that the host is looked up again by the next Fetch.
+	EmptyResultSkip
+)
+
+// WithEmptyResults sets how lookups which succeed without any IP, e.g. of hosts
+// with no A nor AAAA records, are handled. By default they are cached like any
+// other result. With the other policies, cached IPs of the host are kept.
+func WithEmptyResults(p EmptyResultPolicy) Option {
+	return Option{apply: func(r *Resolver) {
+		if p < EmptyResultCache || p > EmptyResultSkip {
+			r.invalid("WithEmptyResults", "unknown policy "+strconv.Itoa(int(p)))
+		}
+		r.emptyResults = p
+	}}
+}
+
+// errEmptyResult returns the error of a lookup of addr without any IP.
+func errEmptyResult(addr string) error {
+	return &net.DNSError{Err: "no addresses found", Name: addr, IsNotFound: true}
+}
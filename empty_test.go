@@ -0,0 +1,64 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestEmptyResults(t *testing.T) {
+	for _, p := range []EmptyResultPolicy{EmptyResultError, EmptyResultSkip} {
+		lookups := 0
+		r := &Resolver{
+			cache:         map[string]*entry{},
+			lookupTimeout: time.Second,
+			lookupIPFn: func(ctx context.Context, network, host string) ([]net.IP, error) {
+				lookups++
+				return []net.IP{}, nil
+			},
+			defaultLookupTimeout: time.Second,
+		}
+		WithEmptyResults(p).apply(r)
+
+		for i := 0; i < 2; i++ {
+			ips, err := r.Fetch(context.Background(), "deeeet.com")
+			switch p {
+			case EmptyResultError:
+				if !errors.Is(err, ErrNotFound) {
+					t.Fatalf("got %v; want ErrNotFound", err)
+				}
+			case EmptyResultSkip:
+				if err != nil || len(ips) != 0 {
+					t.Fatalf("got %v and %v; want no IPs without an error", ips, err)
+				}
+			}
+		}
+		if lookups != 2 || r.Len() != 0 {
+			t.Fatalf("%d: got %d lookups and %d entries; want 2 and 0", p, lookups, r.Len())
+		}
+	}
+}
+
+func TestEmptyResults_default(t *testing.T) {
+	lookups := 0
+	r := &Resolver{
+		cache:         map[string]*entry{},
+		lookupTimeout: time.Second,
+		lookupIPFn: func(ctx context.Context, network, host string) ([]net.IP, error) {
+			lookups++
+			return []net.IP{}, nil
+		},
+		defaultLookupTimeout: time.Second,
+	}
+
+	for i := 0; i < 2; i++ {
+		if ips, err := r.Fetch(context.Background(), "deeeet.com"); err != nil || len(ips) != 0 {
+			t.Fatalf("got %v and %v; want no IPs without an error", ips, err)
+		}
+	}
+	if lookups != 1 || r.Len() != 1 {
+		t.Fatalf("got %d lookups and %d entries; want the empty result to be cached", lookups, r.Len())
+	}
+}
@@ -0,0 +1,74 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+var (
+	// ErrTimeout is matched by errors.Is for lookups which timed out.
+	ErrTimeout = errors.New("dnscache: lookup timed out")
+
+	// ErrNotFound is matched by errors.Is for lookups of hosts which do not exist.
+	ErrNotFound = errors.New("dnscache: host not found")
+)
+
+// LookupError is returned by LookupIP and Fetch when the lookup of a host fails.
+// It wraps the error of the upstream resolver, such as a *net.DNSError, and
+// matches ErrTimeout and ErrNotFound by errors.Is, so that callers do not have
+// to inspect the upstream errors.
+type LookupError struct {
+	Host string
+	Err  error
+}
+
+// Error returns the message of the upstream error as it is.
+func (e *LookupError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *LookupError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrTimeout or ErrNotFound and the lookup failed
+// for the reason.
+func (e *LookupError) Is(target error) bool {
+	switch target {
+	case ErrTimeout:
+		var timeout interface{ Timeout() bool }
+		return errors.Is(e.Err, context.DeadlineExceeded) || (errors.As(e.Err, &timeout) && timeout.Timeout())
+	case ErrNotFound:
+		var dnsErr *net.DNSError
+		return errors.As(e.Err, &dnsErr) && dnsErr.IsNotFound
+	}
+	return false
+}
+
+// RefreshError is the error of a failed background refresh of a cached host,
+// which is passed to the handler of WithRefreshErrorHandler and sent as Err of
+// RefreshFailed events.
+type RefreshError struct {
+	Host string
+	Err  error
+}
+
+func (e *RefreshError) Error() string {
+	return "dnscache: failed to refresh " + e.Host + ": " + e.Err.Error()
+}
+
+func (e *RefreshError) Unwrap() error {
+	return e.Err
+}
+
+// wrapLookupError wraps the error of the lookup of host in a *LookupError unless
+// it is already typed by the resolver.
+func wrapLookupError(host string, err error) error {
+	var hostErr *InvalidHostError
+	var lookupErr *LookupError
+	if errors.As(err, &hostErr) || errors.As(err, &lookupErr) {
+		return err
+	}
+	return &LookupError{Host: host, Err: err}
+}
@@ -0,0 +1,92 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestLookupError(t *testing.T) {
+	r := &Resolver{
+		cache:         map[string]*entry{},
+		lookupTimeout: time.Second,
+		lookupIPFn: func(ctx context.Context, network, host string) ([]net.IP, error) {
+			switch host {
+			case "notfound.deeeet.com":
+				return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+			case "timeout.deeeet.com":
+				return nil, &net.DNSError{Err: "i/o timeout", Name: host, IsTimeout: true}
+			case "deadline.deeeet.com":
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+			return nil, errors.New("server misbehaving")
+		},
+		defaultLookupTimeout: time.Second,
+		logger:               slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	cases := []struct {
+		host     string
+		timeout  bool
+		notFound bool
+	}{
+		{"notfound.deeeet.com", false, true},
+		{"timeout.deeeet.com", true, false},
+		{"deadline.deeeet.com", true, false},
+		{"fail.deeeet.com", false, false},
+	}
+	for _, tc := range cases {
+		ctx, cancelF := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		_, err := r.Fetch(ctx, tc.host)
+		cancelF()
+
+		var lookupErr *LookupError
+		if !errors.As(err, &lookupErr) || lookupErr.Host != tc.host {
+			t.Fatalf("%s: got %v; want *LookupError", tc.host, err)
+		}
+		if got := errors.Is(err, ErrTimeout); got != tc.timeout {
+			t.Errorf("%s: got timeout %v; want %v", tc.host, got, tc.timeout)
+		}
+		if got := errors.Is(err, ErrNotFound); got != tc.notFound {
+			t.Errorf("%s: got not found %v; want %v", tc.host, got, tc.notFound)
+		}
+	}
+
+	// The upstream errors are still available.
+	_, err := r.LookupIP(context.Background(), "notfound.deeeet.com")
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || err.Error() != dnsErr.Error() {
+		t.Fatalf("got %v; want the *net.DNSError to be wrapped", err)
+	}
+}
+
+func TestRefreshError(t *testing.T) {
+	var got error
+	r := &Resolver{
+		cache: map[string]*entry{
+			"deeeet.com": {ips: []net.IP{net.ParseIP("127.0.0.1")}},
+		},
+		lookupIPFn: func(ctx context.Context, network, host string) ([]net.IP, error) {
+			return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		},
+		defaultLookupTimeout: time.Second,
+		logger:               slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	WithRefreshErrorHandler(func(host string, err error) {
+		got = err
+	}).apply(r)
+
+	r.Refresh()
+	var refreshErr *RefreshError
+	if !errors.As(got, &refreshErr) || refreshErr.Host != "deeeet.com" {
+		t.Fatalf("got %v; want *RefreshError", got)
+	}
+	if !errors.Is(got, ErrNotFound) {
+		t.Fatalf("got %v; want ErrNotFound", got)
+	}
+}
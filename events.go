@@ -0,0 +1,123 @@
+package dnscache
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// EventType is the kind of an Event.
+type EventType int
+
+const (
+	// EntryAdded is sent when a host is cached for the first time.
+	EntryAdded EventType = iota + 1
+
+	// EntryUpdated is sent when the IP set of a cached host changes.
+	EntryUpdated
+
+	// EntryRemoved is sent when a host is removed from the cache.
+	EntryRemoved
+
+	// RefreshFailed is sent when a cached host fails to be refreshed.
+	RefreshFailed
+)
+
+// String returns the name of the event type.
+func (t EventType) String() string {
+	switch t {
+	case EntryAdded:
+		return "EntryAdded"
+	case EntryUpdated:
+		return "EntryUpdated"
+	case EntryRemoved:
+		return "EntryRemoved"
+	case RefreshFailed:
+		return "RefreshFailed"
+	default:
+		return "Unknown"
+	}
+}
+
+// Event is a change of the cache.
+type Event struct {
+	Type EventType
+	Host string
+
+	// Old and New are the IP sets before and after the change. Old is nil for
+	// EntryAdded and New is nil for EntryRemoved and RefreshFailed.
+	Old, New []net.IP
+
+	// Err is the lookup error of RefreshFailed.
+	Err error
+
+	Time time.Time
+}
+
+// eventBufferSize is the capacity of the channel returned by Events.
+const eventBufferSize = 128
+
+// events delivers events to the channel once it is requested.
+type events struct {
+	mu sync.Mutex
+	ch chan Event
+}
+
+// channel returns the channel, creating it at the first call.
+func (es *events) channel() chan Event {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	if es.ch == nil {
+		es.ch = make(chan Event, eventBufferSize)
+	}
+	return es.ch
+}
+
+// send sends the event without blocking. It is dropped when nobody has requested
+// the channel or the buffer is full.
+func (es *events) send(e Event) {
+	es.mu.Lock()
+	ch := es.ch
+	es.mu.Unlock()
+	if ch == nil {
+		return
+	}
+
+	select {
+	case ch <- e:
+	default:
+	}
+}
+
+// Events returns the channel delivering changes of the cache, so that other
+// subsystems like connection pools can react to DNS changes. Every call returns
+// the same channel, so events are shared by its receivers. Events are sent only
+// after the first call, and they are dropped rather than blocking lookups when
+// the buffer of the channel is full.
+func (r *Resolver) Events() <-chan Event {
+	return r.events.channel()
+}
+
+// Remove removes host from the cache. It is looked up again by the next Fetch.
+func (r *Resolver) Remove(host string) {
+	r.lock.Lock()
+	e, ok := r.cache[host]
+	if ok {
+		delete(r.cache, host)
+		if r.reverse != nil {
+			r.reverse.remove(host, e.ips)
+		}
+		for name, resolved := range r.aliases {
+			if resolved == host {
+				delete(r.aliases, name)
+			}
+		}
+	}
+	r.lock.Unlock()
+	r.backoff.reset(host)
+	r.removeRecords(host)
+
+	if ok {
+		r.events.send(Event{Type: EntryRemoved, Host: host, Old: e.ips, Time: r.now()})
+	}
+}
@@ -0,0 +1,86 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestEvents(t *testing.T) {
+	fail := false
+	r := &Resolver{
+		cache:         map[string]*entry{},
+		aliases:       map[string]string{},
+		lookupTimeout: time.Second,
+		lookupIPFn: func(ctx context.Context, network, host string) ([]net.IP, error) {
+			if fail {
+				return nil, errors.New("lookup failed")
+			}
+			return []net.IP{net.ParseIP("127.0.0.1")}, nil
+		},
+		defaultLookupTimeout: time.Second,
+		logger:               slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	// Events before the first call of Events are not sent.
+	r.store("before.deeeet.com", &entry{ips: []net.IP{net.ParseIP("127.0.0.1")}})
+	events := r.Events()
+
+	r.LookupIP(context.Background(), "deeeet.com")
+	r.store("deeeet.com", &entry{ips: []net.IP{net.ParseIP("127.0.0.1")}})
+	r.store("deeeet.com", &entry{ips: []net.IP{net.ParseIP("127.0.0.2")}})
+	r.Remove("before.deeeet.com")
+	fail = true
+	r.Remove("deeeet.com")
+	r.store("deeeet.com", &entry{ips: []net.IP{net.ParseIP("127.0.0.3")}})
+	r.Refresh()
+
+	want := []struct {
+		typ  EventType
+		host string
+	}{
+		{EntryAdded, "deeeet.com"},
+		{EntryUpdated, "deeeet.com"},
+		{EntryRemoved, "before.deeeet.com"},
+		{EntryRemoved, "deeeet.com"},
+		{EntryAdded, "deeeet.com"},
+		{RefreshFailed, "deeeet.com"},
+	}
+	for _, w := range want {
+		select {
+		case e := <-events:
+			if e.Type != w.typ || e.Host != w.host || e.Time.IsZero() {
+				t.Fatalf("got %v of %s; want %v of %s", e.Type, e.Host, w.typ, w.host)
+			}
+			if e.Type == RefreshFailed && (e.Err == nil || e.Old[0].String() != "127.0.0.3") {
+				t.Fatalf("got %+v; want the error and the cached IPs", e)
+			}
+		default:
+			t.Fatalf("expect %v of %s to be sent", w.typ, w.host)
+		}
+	}
+	select {
+	case e := <-events:
+		t.Fatalf("got unexpected %v of %s", e.Type, e.Host)
+	default:
+	}
+
+	if _, ok := r.cached("before.deeeet.com"); ok {
+		t.Fatalf("expect the entry to be removed")
+	}
+}
+
+func TestEventsDropped(t *testing.T) {
+	r := &Resolver{cache: map[string]*entry{}}
+	events := r.Events()
+	for i := 0; i < eventBufferSize+1; i++ {
+		r.store("deeeet.com", &entry{ips: []net.IP{net.IPv4(127, 0, byte(i>>8), byte(i))}})
+	}
+	if got := len(events); got != eventBufferSize {
+		t.Fatalf("got %d; want %d", got, eventBufferSize)
+	}
+}
@@ -0,0 +1,94 @@
+package dnscache
+
+import (
+	"errors"
+	"expvar"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultExpvarPrefix is the expvar prefix used when WithExpvar is given no
+// prefix.
+const defaultExpvarPrefix = "dnscache"
+
+// expvars holds the resolvers published by expvar by prefix. The variables of a
+// prefix are published once, as expvar cannot unpublish them, and read the state
+// of the resolver currently published under the prefix, if any.
+var expvars struct {
+	mu        sync.Mutex
+	resolvers map[string]*atomic.Pointer[Resolver]
+}
+
+// WithExpvar publishes the cache size, cache hit and miss counts and the time of
+// the last refresh by expvar as prefix.cache_size, prefix.cache_hits,
+// prefix.cache_misses and prefix.last_refresh, e.g. to be served at /debug/vars.
+// If prefix is empty, "dnscache" is used. New fails if another running resolver
+// is published under the prefix. The variables are cleared by Stop.
+func WithExpvar(prefix string) Option {
+	return Option{apply: func(r *Resolver) {
+		if prefix == "" {
+			prefix = defaultExpvarPrefix
+		}
+		r.expvarPrefix = prefix
+	}}
+}
+
+// publishExpvar publishes the state of r under the expvar prefix.
+func (r *Resolver) publishExpvar() error {
+	expvars.mu.Lock()
+	defer expvars.mu.Unlock()
+
+	p, ok := expvars.resolvers[r.expvarPrefix]
+	if !ok {
+		p = new(atomic.Pointer[Resolver])
+		vars := map[string]func(r *Resolver) any{
+			"cache_size":   func(r *Resolver) any { return r.Len() },
+			"cache_hits":   func(r *Resolver) any { return r.stats.hits.Load() },
+			"cache_misses": func(r *Resolver) any { return r.stats.misses.Load() },
+			"last_refresh": func(r *Resolver) any {
+				if t := r.stats.lastRefresh.Load(); t != 0 {
+					return time.Unix(0, t).UTC().Format(time.RFC3339Nano)
+				}
+				return nil
+			},
+		}
+		for name := range vars {
+			if expvar.Get(r.expvarPrefix+"."+name) != nil {
+				return errors.New("dnscache: expvar " + r.expvarPrefix + "." + name + " is already published")
+			}
+		}
+		for name, fn := range vars {
+			fn := fn
+			expvar.Publish(r.expvarPrefix+"."+name, expvar.Func(func() any {
+				if r := p.Load(); r != nil {
+					return fn(r)
+				}
+				return nil
+			}))
+		}
+		if expvars.resolvers == nil {
+			expvars.resolvers = make(map[string]*atomic.Pointer[Resolver])
+		}
+		expvars.resolvers[r.expvarPrefix] = p
+	}
+
+	if !p.CompareAndSwap(nil, r) {
+		return errors.New("dnscache: expvar " + r.expvarPrefix + " is already published")
+	}
+	return nil
+}
+
+// unpublishExpvar clears the state of r published under the expvar prefix, so
+// that the prefix does not keep r alive and can be used by another resolver.
+func (r *Resolver) unpublishExpvar() {
+	if r.expvarPrefix == "" {
+		return
+	}
+
+	expvars.mu.Lock()
+	defer expvars.mu.Unlock()
+	if p, ok := expvars.resolvers[r.expvarPrefix]; ok {
+		p.CompareAndSwap(r, nil)
+	}
+}
@@ -0,0 +1,84 @@
+package dnscache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestWithExpvar(t *testing.T) {
+	r, err := New(time.Minute, time.Second, WithExpvar("dnscache_test"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer r.Stop()
+
+	r.lookupIPFn = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("127.0.0.1")}, nil
+	}
+	r.Fetch(context.Background(), "deeeet.com")
+	r.Fetch(context.Background(), "deeeet.com")
+	r.Refresh()
+
+	get := func(name string) any {
+		var v any
+		if err := json.Unmarshal([]byte(expvar.Get("dnscache_test."+name).String()), &v); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		return v
+	}
+	if size, hits, misses := get("cache_size"), get("cache_hits"), get("cache_misses"); size != 1.0 || hits != 1.0 || misses != 1.0 {
+		t.Fatalf("got %v, %v and %v; want 1 entry, 1 hit and 1 miss", size, hits, misses)
+	}
+	lastRefresh, err := time.Parse(time.RFC3339Nano, get("last_refresh").(string))
+	if err != nil || time.Since(lastRefresh) > time.Minute {
+		t.Fatalf("got %v; want the time of the refresh", get("last_refresh"))
+	}
+
+	if _, err := New(time.Minute, time.Second, WithExpvar("dnscache_test")); err == nil {
+		t.Fatalf("expect to be failed for the published prefix")
+	}
+
+	// The prefix is released by Stop.
+	r.Stop()
+	if v := get("cache_size"); v != nil {
+		t.Fatalf("got %v; want the stopped resolver to be cleared", v)
+	}
+	r2, err := New(time.Minute, time.Second, WithExpvar("dnscache_test"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer r2.Stop()
+	if v := get("cache_size"); v != 0.0 {
+		t.Fatalf("got %v; want the state of the new resolver", v)
+	}
+}
+
+func TestWithExpvar_failedNew(t *testing.T) {
+	originalFunc := lookupIP
+	defer func() {
+		lookupIP = originalFunc
+	}()
+
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		return nil, errors.New("lookup failed")
+	}
+	if _, err := New(time.Minute, time.Second,
+		WithExpvar("dnscache_test_failed"),
+		WithWarmup([]string{"deeeet.com"}, time.Second),
+	); err == nil {
+		t.Fatalf("expect to be failed")
+	}
+
+	// The name is not published by the failed resolver.
+	lookupIP = originalFunc
+	r, err := New(time.Minute, time.Second, WithExpvar("dnscache_test_failed"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	r.Stop()
+}
@@ -0,0 +1,30 @@
+package dnscache
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// defaultFastHTTPDialTimeout is same as fasthttp.DefaultDialTimeout.
+const defaultFastHTTPDialTimeout = 3 * time.Second
+
+// DialFuncFastHTTP returns a dial function of the `fasthttp.DialFunc` signature,
+// which dials the IPs cached by the resolver like DialFunc. Since the signature
+// has no context, each dial is limited to 3 seconds, same as fasthttp does by
+// default, unless a timeout is given by WithDialTimeout.
+//
+// You can use returned dial function for `fasthttp.Client.Dial`.
+func DialFuncFastHTTP(resolver *Resolver, options ...DialOption) func(addr string) (net.Conn, error) {
+	d := NewDialer(resolver, nil, options...)
+
+	return func(addr string) (net.Conn, error) {
+		ctx := context.Background()
+		if h, _, err := net.SplitHostPort(addr); err == nil && d.config(h).timeout <= 0 {
+			var cancelF context.CancelFunc
+			ctx, cancelF = context.WithTimeout(ctx, defaultFastHTTPDialTimeout)
+			defer cancelF()
+		}
+		return d.DialContext(ctx, "tcp", addr)
+	}
+}
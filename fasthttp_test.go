@@ -0,0 +1,44 @@
+package dnscache
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDialFuncFastHTTP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	resolver := &Resolver{
+		cache: map[string]*entry{
+			"deeeet.com": {ips: []net.IP{net.ParseIP("127.0.0.1")}},
+		},
+		lookupTimeout: time.Second,
+	}
+
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	conn, err := DialFuncFastHTTP(resolver)(net.JoinHostPort("deeeet.com", port))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	conn.Close()
+}
+
+func TestDialFuncFastHTTPError(t *testing.T) {
+	if _, err := DialFuncFastHTTP(&Resolver{})("deeeet.com"); err == nil {
+		t.Fatalf("expect to be failed")
+	}
+}
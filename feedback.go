@@ -0,0 +1,88 @@
+package dnscache
+
+import (
+	"context"
+	"net"
+)
+
+// ReportDialFailure tells the resolver that dialing ip of host failed. The IP is
+// demoted to the end of the cached IP list of the host and the host is refreshed
+// in background without waiting for the next refresh, so that an IP which was
+// removed from DNS is dropped early. The refresh is subject to the refresh rate
+// limit and backoff, and failures reported while it is in progress do not start
+// another one. Static entries, hosts which are not cached and failures reported after
+// Stop are ignored.
+func (r *Resolver) ReportDialFailure(host string, ip net.IP) {
+	if r.isStopped() {
+		return
+	}
+
+	r.lock.Lock()
+	if name, ok := r.aliases[host]; ok {
+		host = name
+	}
+	e, ok := r.cache[host]
+	_, refreshing := r.dialRefreshes[host]
+	if ok {
+		if ips, demoted := demote(e.ips, ip); demoted {
+			demotedEntry := e.clone()
+			demotedEntry.ips = ips
+			r.cache[host] = demotedEntry
+		}
+		if !refreshing {
+			if r.dialRefreshes == nil {
+				r.dialRefreshes = make(map[string]struct{})
+			}
+			r.dialRefreshes[host] = struct{}{}
+		}
+	}
+	r.lock.Unlock()
+
+	if !ok || refreshing {
+		return
+	}
+	go r.refreshAfterDialFailure(host, e.ips)
+}
+
+// refreshAfterDialFailure refreshes host like a refresh cycle does, until the
+// resolver is stopped.
+func (r *Resolver) refreshAfterDialFailure(host string, old []net.IP) {
+	defer func() {
+		r.lock.Lock()
+		delete(r.dialRefreshes, host)
+		r.lock.Unlock()
+	}()
+
+	ctx := context.WithValue(context.Background(), refreshKey{}, true)
+	if r.stopped != nil {
+		var cancelF context.CancelFunc
+		ctx, cancelF = context.WithCancel(ctx)
+		defer cancelF()
+		stop := context.AfterFunc(r.stopped, cancelF)
+		defer stop()
+	}
+
+	if !r.backoff.allow(host, r.now()) || r.refreshLimit.wait(ctx, r.now(), r.after) != nil {
+		return
+	}
+	var summary RefreshSummary
+	r.refreshHost(ctx, host, old, &summary)
+}
+
+// demote returns a copy of ips with ip moved to the end. It reports false if ips
+// does not contain ip.
+func demote(ips []net.IP, ip net.IP) ([]net.IP, bool) {
+	demoted := make([]net.IP, 0, len(ips))
+	found := false
+	for _, cached := range ips {
+		if !found && cached.Equal(ip) {
+			found = true
+			continue
+		}
+		demoted = append(demoted, cached)
+	}
+	if !found {
+		return ips, false
+	}
+	return append(demoted, ip), true
+}
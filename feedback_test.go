@@ -0,0 +1,196 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReportDialFailure(t *testing.T) {
+	originalFunc := lookupIP
+	defer func() {
+		lookupIP = originalFunc
+	}()
+
+	lookedUp := make(chan string, 1)
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		lookedUp <- host
+		return []net.IP{net.ParseIP("127.0.0.2")}, nil
+	}
+
+	resolver := testResolver(t)
+	defer resolver.Stop()
+
+	resolver.lock.Lock()
+	resolver.cache["deeeet.com"] = &entry{ips: []net.IP{
+		net.ParseIP("127.0.0.1"),
+		net.ParseIP("127.0.0.2"),
+	}}
+	resolver.lock.Unlock()
+
+	resolver.ReportDialFailure("deeeet.com", net.ParseIP("127.0.0.1"))
+
+	select {
+	case host := <-lookedUp:
+		if host != "deeeet.com" {
+			t.Fatalf("want deeeet.com, got %s", host)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expect the host to be looked up again")
+	}
+}
+
+func TestReportDialFailure_dedupe(t *testing.T) {
+	originalFunc := lookupIP
+	defer func() {
+		lookupIP = originalFunc
+	}()
+
+	var lookups atomic.Int32
+	release := make(chan struct{})
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		lookups.Add(1)
+		<-release
+		return []net.IP{net.ParseIP("127.0.0.2")}, nil
+	}
+
+	resolver := testResolver(t)
+	resolver.lock.Lock()
+	resolver.cache["deeeet.com"] = &entry{ips: []net.IP{net.ParseIP("127.0.0.1")}}
+	resolver.lock.Unlock()
+
+	for i := 0; i < 3; i++ {
+		resolver.ReportDialFailure("deeeet.com", net.ParseIP("127.0.0.1"))
+	}
+	close(release)
+	for {
+		resolver.lock.RLock()
+		n := len(resolver.dialRefreshes)
+		resolver.lock.RUnlock()
+		if n == 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got := lookups.Load(); got != 1 {
+		t.Fatalf("got %d lookups; want failures to share the refresh", got)
+	}
+
+	// Failures after Stop are ignored.
+	resolver.Stop()
+	resolver.ReportDialFailure("deeeet.com", net.ParseIP("127.0.0.2"))
+	resolver.lock.RLock()
+	n := len(resolver.dialRefreshes)
+	resolver.lock.RUnlock()
+	if n != 0 {
+		t.Fatalf("expect no refresh after Stop")
+	}
+}
+
+func TestReportDialFailure_backoff(t *testing.T) {
+	var lookups atomic.Int32
+	clock := newFakeClock()
+	resolver := &Resolver{
+		cache: map[string]*entry{
+			"deeeet.com": {ips: []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("127.0.0.2")}},
+		},
+		lookupIPFn: func(ctx context.Context, network, host string) ([]net.IP, error) {
+			lookups.Add(1)
+			return nil, errors.New("lookup failed")
+		},
+		lookupTimeout:        time.Second,
+		defaultLookupTimeout: time.Second,
+		clock:                clock,
+		logger:               slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	WithRefreshBackoff(time.Minute, time.Minute).apply(resolver)
+
+	waitRefresh := func() {
+		for {
+			resolver.lock.RLock()
+			n := len(resolver.dialRefreshes)
+			resolver.lock.RUnlock()
+			if n == 0 {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// The first failure refreshes the host, which fails and backs off.
+	resolver.ReportDialFailure("deeeet.com", net.ParseIP("127.0.0.1"))
+	waitRefresh()
+	if got := lookups.Load(); got != 1 {
+		t.Fatalf("got %d lookups; want 1", got)
+	}
+
+	// The host is not refreshed again while backing off.
+	resolver.ReportDialFailure("deeeet.com", net.ParseIP("127.0.0.2"))
+	waitRefresh()
+	if got := lookups.Load(); got != 1 {
+		t.Fatalf("got %d lookups; want the backing off host not to be refreshed", got)
+	}
+
+	clock.Advance(time.Minute)
+	resolver.ReportDialFailure("deeeet.com", net.ParseIP("127.0.0.1"))
+	waitRefresh()
+	if got := lookups.Load(); got != 2 {
+		t.Fatalf("got %d lookups; want 2 after the backoff", got)
+	}
+}
+
+func TestDemote(t *testing.T) {
+	ips := []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("127.0.0.2"), net.ParseIP("127.0.0.3")}
+
+	got, ok := demote(ips, net.ParseIP("127.0.0.1"))
+	want := []net.IP{net.ParseIP("127.0.0.2"), net.ParseIP("127.0.0.3"), net.ParseIP("127.0.0.1")}
+	if !ok || !reflect.DeepEqual(want, got) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+
+	if _, ok := demote(ips, net.ParseIP("127.0.0.4")); ok {
+		t.Fatalf("expect unknown IP not to be demoted")
+	}
+}
+
+func TestDialFuncFailureFeedback(t *testing.T) {
+	resolver := &Resolver{
+		cache: map[string]*entry{
+			"deeeet.com": {ips: []net.IP{
+				net.ParseIP("127.0.0.1"),
+				net.ParseIP("127.0.0.2"),
+			}},
+		},
+		lookupIPFn: func(ctx context.Context, network, host string) ([]net.IP, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+		lookupTimeout:        time.Second,
+		defaultLookupTimeout: time.Millisecond,
+		logger:               slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	dialF := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if addr == "127.0.0.1:443" {
+			return nil, errors.New("connection refused")
+		}
+		return nil, nil
+	}
+
+	dial := DialFunc(resolver, dialF, WithSelector(firstSelector{}), WithFailureFeedback())
+	if _, err := dial(context.Background(), "tcp", "deeeet.com:443"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	e, _ := resolver.cached("deeeet.com")
+	want := []net.IP{net.ParseIP("127.0.0.2"), net.ParseIP("127.0.0.1")}
+	if !reflect.DeepEqual(want, e.ips) {
+		t.Fatalf("want %v, got %v", want, e.ips)
+	}
+}
@@ -0,0 +1,21 @@
+package dnscache
+
+import (
+	"context"
+	"net"
+)
+
+// DialFuncGRPC returns a dial function of the signature `grpc.WithContextDialer`
+// accepts, which dials the IPs cached by the resolver like DialFunc with the given
+// options. This is for users who do not need the resolver.Builder of the
+// grpcresolver package.
+//
+// Use a "passthrough:///host:port" target, the default of `grpc.Dial`, so that the
+// host is given to the dial function as it is instead of being resolved by gRPC.
+func DialFuncGRPC(resolver *Resolver, options ...DialOption) func(ctx context.Context, addr string) (net.Conn, error) {
+	d := NewDialer(resolver, nil, options...)
+
+	return func(ctx context.Context, addr string) (net.Conn, error) {
+		return d.DialContext(ctx, "tcp", addr)
+	}
+}
@@ -0,0 +1,44 @@
+package dnscache
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDialFuncGRPC(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	resolver := &Resolver{
+		cache: map[string]*entry{
+			"deeeet.com": {ips: []net.IP{net.ParseIP("127.0.0.2"), net.ParseIP("127.0.0.1")}},
+		},
+		lookupTimeout: time.Second,
+	}
+
+	// The first IP refuses the connection and the second one is dialed.
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	conn, err := DialFuncGRPC(resolver, WithSelector(firstSelector{}))(context.Background(), net.JoinHostPort("deeeet.com", port))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer conn.Close()
+
+	if got := conn.RemoteAddr().String(); got != ln.Addr().String() {
+		t.Fatalf("want %s, got %s", ln.Addr(), got)
+	}
+}
@@ -0,0 +1,18 @@
+module go.mercari.io/go-dnscache/grpcresolver
+
+go 1.21
+
+require (
+	go.mercari.io/go-dnscache v0.2.0
+	google.golang.org/grpc v1.60.1
+)
+
+require (
+	github.com/golang/protobuf v1.5.3 // indirect
+	google.golang.org/protobuf v1.31.0 // indirect
+)
+
+// The parent module is used from this repository until go-dnscache v0.2.0,
+// which introduces the APIs used by this module, is released. Drop this replace
+// directive when releasing this module.
+replace go.mercari.io/go-dnscache => ../
@@ -0,0 +1,177 @@
+// Package grpcresolver provides a gRPC name resolver backed by go-dnscache.
+// Channels dialed with a `dnscache:///host:port` target get the IP list of the
+// host from the cache, which is refreshed in background, and are updated as soon
+// as the IP list changes.
+package grpcresolver // import "go.mercari.io/go-dnscache/grpcresolver"
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync"
+
+	dnscache "go.mercari.io/go-dnscache"
+	"google.golang.org/grpc/resolver"
+)
+
+// Scheme is the scheme of the targets resolved by the builder.
+const Scheme = "dnscache"
+
+// defaultPort is used when the target has no port, same as the DNS resolver of gRPC.
+const defaultPort = "443"
+
+type builder struct {
+	resolver *dnscache.Resolver
+}
+
+// NewBuilder returns a `resolver.Builder` which resolves targets of the "dnscache"
+// scheme by the given resolver. The addresses of the channel are updated when the
+// resolver finds that the IP list of the host changed, without polling the cache.
+//
+// Register it with `resolver.Register` or pass it by `grpc.WithResolvers`.
+func NewBuilder(r *dnscache.Resolver) resolver.Builder {
+	return &builder{resolver: r}
+}
+
+func (b *builder) Scheme() string {
+	return Scheme
+}
+
+func (b *builder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	host, port, err := splitTarget(target.Endpoint())
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancelF := context.WithCancel(context.Background())
+	r := &ccResolver{
+		resolver:   b.resolver,
+		host:       host,
+		port:       port,
+		cc:         cc,
+		ctx:        ctx,
+		cancelF:    cancelF,
+		resolveNow: make(chan struct{}, 1),
+	}
+	// The host of a change is not compared with the host of the target, which
+	// may be cached by another name, e.g. with a search domain. The addresses are
+	// compared instead.
+	r.removeListener = b.resolver.OnChange(func(string, []net.IP, []net.IP) {
+		r.ResolveNow(resolver.ResolveNowOptions{})
+	})
+	r.wg.Add(1)
+	go r.watch()
+	return r, nil
+}
+
+// splitTarget splits the endpoint of a target into host and port, setting the
+// default port when it is omitted.
+func splitTarget(endpoint string) (string, string, error) {
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		// Try again with the default port in case the port is omitted.
+		var retryErr error
+		host, port, retryErr = net.SplitHostPort(net.JoinHostPort(endpoint, defaultPort))
+		if retryErr != nil {
+			return "", "", err
+		}
+	}
+	if host == "" {
+		return "", "", &net.AddrError{Err: "missing host", Addr: endpoint}
+	}
+	if port == "" {
+		port = defaultPort
+	}
+	return host, port, nil
+}
+
+// ccResolver pushes the addresses of a host to a gRPC channel.
+type ccResolver struct {
+	resolver *dnscache.Resolver
+	host     string
+	port     string
+	cc       resolver.ClientConn
+
+	ctx            context.Context
+	cancelF        context.CancelFunc
+	resolveNow     chan struct{}
+	removeListener func()
+	wg             sync.WaitGroup
+}
+
+// ResolveNow makes the resolver check the cache again. It is also called when an
+// IP list of the cache changes.
+func (r *ccResolver) ResolveNow(resolver.ResolveNowOptions) {
+	select {
+	case r.resolveNow <- struct{}{}:
+	default:
+	}
+}
+
+// Close stops watching the cache.
+func (r *ccResolver) Close() {
+	r.removeListener()
+	r.cancelF()
+	r.wg.Wait()
+}
+
+// watch updates the state of the channel whenever the addresses change until
+// the resolver is closed.
+func (r *ccResolver) watch() {
+	defer r.wg.Done()
+
+	var last []string
+	for {
+		addrs, err := r.addresses()
+		if err != nil {
+			r.cc.ReportError(err)
+		} else if !equal(last, addrs) {
+			state := resolver.State{Addresses: make([]resolver.Address, 0, len(addrs))}
+			for _, addr := range addrs {
+				state.Addresses = append(state.Addresses, resolver.Address{Addr: addr, ServerName: r.host})
+			}
+			if err := r.cc.UpdateState(state); err == nil {
+				last = addrs
+			}
+		}
+
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-r.resolveNow:
+		}
+	}
+}
+
+// addresses returns the sorted addresses of the host fetched from the cache.
+func (r *ccResolver) addresses() ([]string, error) {
+	var ips []net.IP
+	if ip := net.ParseIP(r.host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		var err error
+		ips, err = r.resolver.Fetch(r.ctx, r.host)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	addrs := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, net.JoinHostPort(ip.String(), r.port))
+	}
+	sort.Strings(addrs)
+	return addrs, nil
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
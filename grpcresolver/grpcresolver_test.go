@@ -0,0 +1,151 @@
+package grpcresolver
+
+import (
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	dnscache "go.mercari.io/go-dnscache"
+	"google.golang.org/grpc/resolver"
+)
+
+type testClientConn struct {
+	resolver.ClientConn
+	states chan resolver.State
+}
+
+func (cc *testClientConn) UpdateState(s resolver.State) error {
+	cc.states <- s
+	return nil
+}
+
+func (cc *testClientConn) ReportError(err error) {}
+
+func TestBuilder(t *testing.T) {
+	r, err := dnscache.New(time.Minute, time.Second, dnscache.WithStaticEntries(map[string][]net.IP{
+		"mercari.io": {net.ParseIP("127.0.0.2"), net.ParseIP("127.0.0.1")},
+	}))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer r.Stop()
+
+	b := NewBuilder(r)
+	if b.Scheme() != Scheme {
+		t.Fatalf("want %s, got %s", Scheme, b.Scheme())
+	}
+
+	cc := &testClientConn{states: make(chan resolver.State, 1)}
+	target := resolver.Target{URL: url.URL{Scheme: Scheme, Path: "/mercari.io:50051"}}
+	res, err := b.Build(target, cc, resolver.BuildOptions{})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer res.Close()
+
+	want := []resolver.Address{
+		{Addr: "127.0.0.1:50051", ServerName: "mercari.io"},
+		{Addr: "127.0.0.2:50051", ServerName: "mercari.io"},
+	}
+	select {
+	case s := <-cc.states:
+		if !reflect.DeepEqual(want, s.Addresses) {
+			t.Fatalf("want %v, got %v", want, s.Addresses)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expect state to be updated")
+	}
+
+	// The state is not updated again while the addresses do not change.
+	res.ResolveNow(resolver.ResolveNowOptions{})
+	select {
+	case s := <-cc.states:
+		t.Fatalf("unexpected update: %v", s)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestBuilderOnChange(t *testing.T) {
+	hosts := filepath.Join(t.TempDir(), "hosts")
+	if err := os.WriteFile(hosts, []byte("127.0.0.1 mercari.io\n"), 0o644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	r, err := dnscache.New(time.Hour, time.Second, dnscache.WithHostsFile(hosts))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer r.Stop()
+
+	cc := &testClientConn{states: make(chan resolver.State, 1)}
+	target := resolver.Target{URL: url.URL{Scheme: Scheme, Path: "/mercari.io:50051"}}
+	res, err := NewBuilder(r).Build(target, cc, resolver.BuildOptions{})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer res.Close()
+	<-cc.states
+
+	// The new IP is pushed to the channel as soon as a refresh finds it.
+	if err := os.WriteFile(hosts, []byte("127.0.0.2 mercari.io\n"), 0o644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(hosts, later, later); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	r.Refresh()
+
+	want := []resolver.Address{{Addr: "127.0.0.2:50051", ServerName: "mercari.io"}}
+	select {
+	case s := <-cc.states:
+		if !reflect.DeepEqual(want, s.Addresses) {
+			t.Fatalf("want %v, got %v", want, s.Addresses)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expect state to be updated on the change")
+	}
+}
+
+func TestSplitTarget(t *testing.T) {
+	cases := []struct {
+		endpoint   string
+		host, port string
+		wantErr    bool
+	}{
+		{endpoint: "mercari.io:50051", host: "mercari.io", port: "50051"},
+		{endpoint: "mercari.io", host: "mercari.io", port: defaultPort},
+		{endpoint: "[::1]:50051", host: "::1", port: "50051"},
+		{endpoint: "::1", host: "::1", port: defaultPort},
+		{endpoint: ":50051", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		host, port, err := splitTarget(tc.endpoint)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%s: expect to be failed", tc.endpoint)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: err: %s", tc.endpoint, err)
+			continue
+		}
+		if host != tc.host || port != tc.port {
+			t.Errorf("%s: want %s %s, got %s %s", tc.endpoint, tc.host, tc.port, host, port)
+		}
+	}
+}
+
+func TestEqual(t *testing.T) {
+	if !equal([]string{"a", "b"}, []string{"a", "b"}) {
+		t.Fatalf("expect to be equal")
+	}
+	if equal([]string{"a"}, []string{"a", "b"}) || equal([]string{"a", "c"}, []string{"a", "b"}) {
+		t.Fatalf("expect not to be equal")
+	}
+}
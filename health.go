@@ -0,0 +1,47 @@
+package dnscache
+
+import (
+	"errors"
+	"strconv"
+)
+
+// ErrResolverStopped is returned by Healthy when the resolver has been stopped.
+var ErrResolverStopped = errors.New("dnscache: resolver is stopped")
+
+// WithUnhealthyAfter sets the number of consecutive refresh cycles which must
+// fail for Healthy to report an error. A cycle fails when any cached host fails
+// to be refreshed. The default is 1.
+func WithUnhealthyAfter(cycles int) Option {
+	return Option{apply: func(r *Resolver) {
+		if cycles < 0 {
+			r.invalid("WithUnhealthyAfter", "negative cycles")
+		}
+		if cycles > 0 {
+			r.unhealthyAfter = cycles
+		}
+	}}
+}
+
+// Healthy returns nil if the background refresher is running and the recent
+// refresh cycles succeeded, so that the resolver can be wired into readiness
+// or liveness probes. It returns ErrResolverStopped after Stop, and an error
+// describing the last failed cycle when the number of consecutive failed cycles
+// reaches the threshold set by WithUnhealthyAfter.
+func (r *Resolver) Healthy() error {
+	r.lock.RLock()
+	stopped := r.closer == nil
+	r.lock.RUnlock()
+	if stopped {
+		return ErrResolverStopped
+	}
+
+	threshold := int64(r.unhealthyAfter)
+	if threshold <= 0 {
+		threshold = 1
+	}
+	if n := r.stats.failedRefreshes.Load(); n >= threshold {
+		return errors.New("dnscache: " + strconv.FormatInt(n, 10) + " consecutive refresh cycles failed, last with " +
+			strconv.FormatInt(r.stats.lastRefreshFailures.Load(), 10) + " hosts failing")
+	}
+	return nil
+}
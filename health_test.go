@@ -0,0 +1,50 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestHealthy(t *testing.T) {
+	fail := true
+	r, err := New(time.Minute, time.Second, WithUnhealthyAfter(2), WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	r.lookupIPFn = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		if fail {
+			return nil, errors.New("lookup failed")
+		}
+		return []net.IP{net.ParseIP("127.0.0.1")}, nil
+	}
+	r.cache["deeeet.com"] = &entry{ips: []net.IP{net.ParseIP("127.0.0.1")}}
+
+	if err := r.Healthy(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	r.Refresh()
+	if err := r.Healthy(); err != nil {
+		t.Fatalf("expect a single failed cycle to be tolerated: %s", err)
+	}
+	r.Refresh()
+	if err := r.Healthy(); err == nil {
+		t.Fatalf("expect to be unhealthy after 2 failed cycles")
+	}
+
+	fail = false
+	r.Refresh()
+	if err := r.Healthy(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	r.Stop()
+	if err := r.Healthy(); !errors.Is(err, ErrResolverStopped) {
+		t.Fatalf("got %v; want ErrResolverStopped", err)
+	}
+}
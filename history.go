@@ -0,0 +1,87 @@
+package dnscache
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// maxHistoryHosts is the maximum number of hosts whose history is kept.
+const maxHistoryHosts = 1024
+
+// HistoryEntry is a past lookup result of a host.
+type HistoryEntry struct {
+	Time time.Time
+	IPs  []net.IP
+	Err  error
+}
+
+// WithHistory keeps the last n lookup results of every host, which are queried
+// by `History`, e.g. to analyze when and how the IPs of a host have changed.
+// The history of up to 1024 hosts is kept.
+func WithHistory(n int) Option {
+	return Option{apply: func(r *Resolver) {
+		if n < 0 {
+			r.invalid("WithHistory", "negative size")
+		}
+		if n > 0 {
+			r.history = &history{size: n, hosts: make(map[string]*historyRing)}
+		}
+	}}
+}
+
+// history keeps a ring buffer of lookup results per host.
+type history struct {
+	size int
+
+	mu    sync.Mutex
+	hosts map[string]*historyRing
+}
+
+type historyRing struct {
+	entries []HistoryEntry
+	next    int
+}
+
+// add records a lookup result of host at now.
+func (h *history) add(host string, ips []net.IP, err error, now time.Time) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ring, ok := h.hosts[host]
+	if !ok {
+		makeRoom(h.hosts, maxHistoryHosts, nil)
+		ring = &historyRing{}
+		h.hosts[host] = ring
+	}
+
+	e := HistoryEntry{Time: now, IPs: ips, Err: err}
+	if len(ring.entries) < h.size {
+		ring.entries = append(ring.entries, e)
+		return
+	}
+	ring.entries[ring.next] = e
+	ring.next = (ring.next + 1) % h.size
+}
+
+// History returns the last lookup results of host, oldest first, when
+// WithHistory is given.
+func (r *Resolver) History(host string) []HistoryEntry {
+	h := r.history
+	if h == nil {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ring, ok := h.hosts[host]
+	if !ok {
+		return nil
+	}
+	entries := make([]HistoryEntry, 0, len(ring.entries))
+	entries = append(entries, ring.entries[ring.next:]...)
+	return append(entries, ring.entries[:ring.next]...)
+}
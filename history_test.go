@@ -0,0 +1,51 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestHistory(t *testing.T) {
+	results := []string{"127.0.0.1", "", "127.0.0.2", "127.0.0.3"}
+	i := 0
+	r := &Resolver{
+		cache:         map[string]*entry{},
+		lookupTimeout: time.Second,
+		lookupIPFn: func(ctx context.Context, network, host string) ([]net.IP, error) {
+			result := results[i]
+			i++
+			if result == "" {
+				return nil, errors.New("lookup failed")
+			}
+			return []net.IP{net.ParseIP(result)}, nil
+		},
+	}
+	if got := r.History("deeeet.com"); got != nil {
+		t.Fatalf("got %v; want nil without WithHistory", got)
+	}
+	WithHistory(3).apply(r)
+
+	for range results {
+		r.LookupIP(context.Background(), "deeeet.com")
+	}
+
+	got := r.History("deeeet.com")
+	if len(got) != 3 {
+		t.Fatalf("got %d entries; want 3", len(got))
+	}
+	if got[0].Err == nil || got[0].IPs != nil {
+		t.Fatalf("got %+v; want the failed lookup first", got[0])
+	}
+	if !got[1].IPs[0].Equal(net.ParseIP("127.0.0.2")) || !got[2].IPs[0].Equal(net.ParseIP("127.0.0.3")) {
+		t.Fatalf("got %+v", got[1:])
+	}
+	if got[0].Time.After(got[2].Time) {
+		t.Fatalf("expect entries to be ordered by time")
+	}
+	if got := r.History("unknown.deeeet.com"); got != nil {
+		t.Fatalf("got %v; want nil", got)
+	}
+}
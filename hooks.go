@@ -0,0 +1,87 @@
+package dnscache
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// LookupStartInfo describes a lookup which is about to start.
+type LookupStartInfo struct {
+	Host string
+
+	// Refresh is true for lookups by background refreshes and false for
+	// foreground calls.
+	Refresh bool
+}
+
+// LookupDoneInfo describes a finished lookup.
+type LookupDoneInfo struct {
+	Host     string
+	Refresh  bool
+	Duration time.Duration
+
+	// IPs is the number of IPs of the result.
+	IPs int
+	Err error
+
+	// Stale is true when the lookup failed with Err but the previously cached
+	// IPs were returned by WithStaleFallback.
+	Stale bool
+}
+
+// Hooks are callbacks fired around every lookup of the resolver, e.g. for custom
+// metrics or logging. Either callback may be nil. They are called synchronously
+// and must be safe for concurrent use.
+type Hooks struct {
+	OnLookupStart func(info LookupStartInfo)
+	OnLookupDone  func(info LookupDoneInfo)
+}
+
+// WithHooks sets callbacks fired before and after every lookup by LookupIP,
+// Fetch and background refreshes. Static entries are not looked up, so they do
+// not fire the hooks.
+func WithHooks(hooks Hooks) Option {
+	return Option{apply: func(r *Resolver) {
+		r.hooks = hooks
+	}}
+}
+
+// refreshKey is the context key marking lookups by background refreshes.
+type refreshKey struct{}
+
+// isRefresh reports whether ctx is of a background refresh.
+func isRefresh(ctx context.Context) bool {
+	refresh, _ := ctx.Value(refreshKey{}).(bool)
+	return refresh
+}
+
+// lookupStart fires OnLookupStart and returns a function which fires
+// OnLookupDone with the result. staleErr is the error of the lookup when the
+// stale IPs were returned instead.
+func (h Hooks) lookupStart(ctx context.Context, host string) func(ips []net.IP, err, staleErr error) {
+	if h.OnLookupStart == nil && h.OnLookupDone == nil {
+		return func([]net.IP, error, error) {}
+	}
+
+	refresh := isRefresh(ctx)
+	if h.OnLookupStart != nil {
+		h.OnLookupStart(LookupStartInfo{Host: host, Refresh: refresh})
+	}
+	start := time.Now()
+	return func(ips []net.IP, err, staleErr error) {
+		if h.OnLookupDone != nil {
+			info := LookupDoneInfo{
+				Host:     host,
+				Refresh:  refresh,
+				Duration: time.Since(start),
+				IPs:      len(ips),
+				Err:      err,
+			}
+			if staleErr != nil {
+				info.Err, info.Stale = staleErr, true
+			}
+			h.OnLookupDone(info)
+		}
+	}
+}
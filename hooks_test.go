@@ -0,0 +1,70 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWithHooks(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		starts []LookupStartInfo
+		dones  []LookupDoneInfo
+	)
+	hooks := Hooks{
+		OnLookupStart: func(info LookupStartInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			starts = append(starts, info)
+		},
+		OnLookupDone: func(info LookupDoneInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			dones = append(dones, info)
+		},
+	}
+
+	r := &Resolver{
+		cache:         map[string]*entry{},
+		lookupTimeout: time.Second,
+		lookupIPFn: func(ctx context.Context, network, host string) ([]net.IP, error) {
+			if host == "fail.deeeet.com" {
+				return nil, errors.New("lookup failed")
+			}
+			return []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("127.0.0.2")}, nil
+		},
+		defaultLookupTimeout: time.Second,
+		logger:               slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	WithHooks(hooks).apply(r)
+
+	if _, err := r.Fetch(context.Background(), "deeeet.com"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	// Cache hits are not lookups.
+	if _, err := r.Fetch(context.Background(), "deeeet.com"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	r.cache["fail.deeeet.com"] = &entry{ips: []net.IP{net.ParseIP("127.0.0.3")}}
+	delete(r.cache, "deeeet.com")
+	r.Refresh()
+
+	if len(starts) != 2 || len(dones) != 2 {
+		t.Fatalf("got %d starts and %d dones; want 2 and 2", len(starts), len(dones))
+	}
+	if starts[0] != (LookupStartInfo{Host: "deeeet.com"}) || starts[1] != (LookupStartInfo{Host: "fail.deeeet.com", Refresh: true}) {
+		t.Fatalf("got %+v", starts)
+	}
+	if d := dones[0]; d.Host != "deeeet.com" || d.Refresh || d.IPs != 2 || d.Err != nil {
+		t.Fatalf("got %+v; want a foreground lookup of 2 IPs", d)
+	}
+	if d := dones[1]; d.Host != "fail.deeeet.com" || !d.Refresh || d.IPs != 0 || d.Err == nil {
+		t.Fatalf("got %+v; want a failed refresh", d)
+	}
+}
@@ -0,0 +1,67 @@
+package dnscache
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidHost is matched by errors.Is for an *InvalidHostError.
+var ErrInvalidHost = errors.New("dnscache: invalid host")
+
+// maxHostLength is the maximum length of a domain name in text form without the
+// trailing dot.
+const maxHostLength = 253
+
+// InvalidHostError is returned by LookupIP and Fetch when the host is not a valid
+// domain name, e.g. when it is empty or has a port, instead of looking it up.
+type InvalidHostError struct {
+	Host string
+
+	// Reason describes why the host is invalid.
+	Reason string
+}
+
+func (e *InvalidHostError) Error() string {
+	return "dnscache: invalid host " + strconv.Quote(e.Host) + ": " + e.Reason
+}
+
+// Is reports whether target is ErrInvalidHost.
+func (e *InvalidHostError) Is(target error) bool {
+	return target == ErrInvalidHost
+}
+
+// validateHost returns an *InvalidHostError if host is not a valid domain name.
+// Names may end with a dot, and labels may have underscores, which are used by
+// service names like SRV targets.
+func validateHost(host string) error {
+	invalid := func(reason string) error {
+		return &InvalidHostError{Host: host, Reason: reason}
+	}
+
+	name := strings.TrimSuffix(host, ".")
+	switch {
+	case name == "":
+		return invalid("empty host")
+	case len(name) > maxHostLength:
+		return invalid("longer than " + strconv.Itoa(maxHostLength) + " characters")
+	case strings.Contains(name, ":"):
+		return invalid("host must not have a port")
+	}
+
+	for _, label := range strings.Split(name, ".") {
+		if label == "" {
+			return invalid("empty label")
+		}
+		if len(label) > 63 {
+			return invalid("label longer than 63 characters")
+		}
+		for i := 0; i < len(label); i++ {
+			c := label[i]
+			if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_') {
+				return invalid("invalid character " + strconv.QuoteRune(rune(c)))
+			}
+		}
+	}
+	return nil
+}
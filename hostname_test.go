@@ -0,0 +1,59 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestValidateHost(t *testing.T) {
+	cases := []struct {
+		host  string
+		valid bool
+	}{
+		{"deeeet.com", true},
+		{"deeeet.com.", true},
+		{"payments", true},
+		{"_sip._tcp.deeeet.com", true},
+		{"my-host.deeeet.com", true},
+		{"", false},
+		{".", false},
+		{"deeeet.com:80", false},
+		{"deeeet..com", false},
+		{".deeeet.com", false},
+		{"dee eet.com", false},
+		{"deeeet.com/path", false},
+		{strings.Repeat("a", 64) + ".com", false},
+		{strings.Repeat("a.", 127) + "com", false},
+	}
+	for _, tc := range cases {
+		err := validateHost(tc.host)
+		if got := err == nil; got != tc.valid {
+			t.Errorf("%q: got %v; want valid %v", tc.host, err, tc.valid)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidHost) {
+			t.Errorf("%q: got %v; want ErrInvalidHost", tc.host, err)
+		}
+	}
+}
+
+func TestLookupIP_invalidHost(t *testing.T) {
+	r := &Resolver{
+		cache: map[string]*entry{},
+		lookupIPFn: func(ctx context.Context, network, host string) ([]net.IP, error) {
+			t.Fatalf("expect %q not to be looked up", host)
+			return nil, nil
+		},
+	}
+
+	_, err := r.Fetch(context.Background(), "deeeet.com:443")
+	var hostErr *InvalidHostError
+	if !errors.As(err, &hostErr) || hostErr.Host != "deeeet.com:443" {
+		t.Fatalf("got %v; want *InvalidHostError", err)
+	}
+	if r.Len() != 0 {
+		t.Fatalf("expect nothing to be cached")
+	}
+}
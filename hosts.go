@@ -0,0 +1,102 @@
+package dnscache
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultHostsFile is the path of the system hosts file.
+const defaultHostsFile = "/etc/hosts"
+
+// hostsFile holds the entries of a hosts-format file. It is re-read when
+// the modification time of the file changes.
+type hostsFile struct {
+	path string
+
+	mu      sync.RWMutex
+	modTime time.Time
+	entries map[string][]net.IP
+}
+
+// newHostsFile reads the hosts file at the given path.
+func newHostsFile(path string) (*hostsFile, error) {
+	h := &hostsFile{path: path}
+	if err := h.reload(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// lookup returns IPs for the given host from the hosts file.
+func (h *hostsFile) lookup(host string) ([]net.IP, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	ips, ok := h.entries[normalizeHost(host)]
+	return ips, ok
+}
+
+// reload re-reads the hosts file if it has been modified since the last read.
+func (h *hostsFile) reload() error {
+	fi, err := os.Stat(h.path)
+	if err != nil {
+		return err
+	}
+
+	h.mu.RLock()
+	unchanged := h.entries != nil && fi.ModTime().Equal(h.modTime)
+	h.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	data, err := os.ReadFile(h.path)
+	if err != nil {
+		return err
+	}
+	entries := parseHosts(data)
+
+	h.mu.Lock()
+	h.entries = entries
+	h.modTime = fi.ModTime()
+	h.mu.Unlock()
+	return nil
+}
+
+// parseHosts parses hosts-format data: an IP address followed by one or more
+// host names per line, with comments starting with '#'. Invalid lines are ignored.
+func parseHosts(data []byte) map[string][]net.IP {
+	entries := make(map[string][]net.IP)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		ip := net.ParseIP(fields[0])
+		if ip == nil {
+			continue
+		}
+
+		for _, name := range fields[1:] {
+			name = normalizeHost(name)
+			entries[name] = append(entries[name], ip)
+		}
+	}
+	return entries
+}
+
+// normalizeHost lowercases the host and removes the trailing dot of a FQDN.
+func normalizeHost(host string) string {
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
@@ -0,0 +1,98 @@
+package dnscache
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseHosts(t *testing.T) {
+	data := []byte(`# comment
+127.0.0.1	localhost
+::1		localhost ip6-localhost # trailing comment
+10.0.0.1	Gateway.IO. gw
+invalid		foo.io
+10.0.0.2
+`)
+
+	want := map[string][]net.IP{
+		"localhost":     {net.ParseIP("127.0.0.1"), net.ParseIP("::1")},
+		"ip6-localhost": {net.ParseIP("::1")},
+		"gateway.io":    {net.ParseIP("10.0.0.1")},
+		"gw":            {net.ParseIP("10.0.0.1")},
+	}
+
+	if got := parseHosts(data); !reflect.DeepEqual(want, got) {
+		t.Fatalf("want %#v, got %#v", want, got)
+	}
+}
+
+func TestHostsFile(t *testing.T) {
+	originalFunc := lookupIP
+	defer func() {
+		lookupIP = originalFunc
+	}()
+
+	fromDNS := []net.IP{
+		net.ParseIP("192.168.0.1"),
+	}
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		return fromDNS, nil
+	}
+
+	path := filepath.Join(t.TempDir(), "hosts")
+	if err := os.WriteFile(path, []byte("10.0.0.1 gateway.io\n"), 0o644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	resolver, err := New(testFreq, testDefaultLookupTimeout, WithHostsFile(path))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resolver.Stop()
+
+	ctx := context.Background()
+	got, err := resolver.LookupIP(ctx, "gateway.io")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if want := []net.IP{net.ParseIP("10.0.0.1")}; !reflect.DeepEqual(want, got) {
+		t.Fatalf("want %#v, got %#v", want, got)
+	}
+
+	got, err = resolver.LookupIP(ctx, "mercari.io")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(fromDNS, got) {
+		t.Fatalf("want %#v, got %#v", fromDNS, got)
+	}
+
+	// Modified hosts file is picked up by refresh.
+	if err := os.WriteFile(path, []byte("10.0.0.2 gateway.io\n"), 0o644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resolver.Refresh()
+
+	got, err = resolver.Fetch(ctx, "gateway.io")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if want := []net.IP{net.ParseIP("10.0.0.2")}; !reflect.DeepEqual(want, got) {
+		t.Fatalf("want %#v, got %#v", want, got)
+	}
+}
+
+func TestHostsFileNotExist(t *testing.T) {
+	if _, err := New(testFreq, testDefaultLookupTimeout, WithHostsFile(filepath.Join(t.TempDir(), "hosts"))); err == nil {
+		t.Fatalf("expect to be failed")
+	}
+}
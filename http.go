@@ -0,0 +1,28 @@
+package dnscache
+
+import (
+	"net/http"
+)
+
+// defaultMaxIdleConnsPerHost is the number of idle connections kept per host by
+// the transport returned by NewTransport. The default of `http.Transport`, 2,
+// makes busy clients close and reopen connections too often.
+const defaultMaxIdleConnsPerHost = 16
+
+// NewTransport returns a `http.Transport` whose DialContext dials the IPs cached by
+// the resolver with DialFunc. The other settings are same as `http.DefaultTransport`
+// except that more idle connections are kept per host. The given options are
+// passed to DialFunc, e.g. WithCircuitBreaker to skip dead IPs. Use BindTransport
+// to close its idle connections when cached IPs change.
+func NewTransport(resolver *Resolver, options ...DialOption) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = DialFunc(resolver, nil, options...)
+	t.MaxIdleConnsPerHost = defaultMaxIdleConnsPerHost
+	return t
+}
+
+// NewHTTPClient returns a `http.Client` which uses the transport returned by
+// NewTransport.
+func NewHTTPClient(resolver *Resolver, options ...DialOption) *http.Client {
+	return &http.Client{Transport: NewTransport(resolver, options...)}
+}
@@ -0,0 +1,26 @@
+module go.mercari.io/go-dnscache/http3dialer
+
+go 1.22
+
+require (
+	github.com/quic-go/quic-go v0.48.2
+	go.mercari.io/go-dnscache v0.2.0
+)
+
+require (
+	github.com/go-task/slim-sprig v0.0.0-20230315185526-52ccab3ef572 // indirect
+	github.com/google/pprof v0.0.0-20210407192527-94a9f03dee38 // indirect
+	github.com/onsi/ginkgo/v2 v2.9.5 // indirect
+	go.uber.org/mock v0.4.0 // indirect
+	golang.org/x/crypto v0.26.0 // indirect
+	golang.org/x/exp v0.0.0-20240506185415-9bf2ced13842 // indirect
+	golang.org/x/mod v0.17.0 // indirect
+	golang.org/x/net v0.28.0 // indirect
+	golang.org/x/sys v0.23.0 // indirect
+	golang.org/x/tools v0.21.1-0.20240508182429-e35e4ccd0d2d // indirect
+)
+
+// The parent module is used from this repository until go-dnscache v0.2.0,
+// which introduces the APIs used by this module, is released. Drop this replace
+// directive when releasing this module.
+replace go.mercari.io/go-dnscache => ../
@@ -0,0 +1,120 @@
+// Package http3dialer provides the dial function of quic-go's HTTP/3 transport
+// backed by go-dnscache, so HTTP/3 clients resolve hosts through the cache
+// instead of their own uncached resolution.
+package http3dialer // import "go.mercari.io/go-dnscache/http3dialer"
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	dnscache "go.mercari.io/go-dnscache"
+)
+
+// DialFunc returns a dial function for `http3.Transport.Dial`. It fetches the IPs
+// of the host from the resolver and establishes a QUIC connection to them by
+// `dnscache.Dialer`, so that the IPs are ordered and the deadline is shared among
+// them as for TCP connections, and the given options, e.g. WithHappyEyeballs or
+// WithCircuitBreaker, apply in the same way. The network is "udp". The TLS server
+// name is set to the host so that SNI and certificate verification work as if
+// the host were dialed.
+func DialFunc(resolver *dnscache.Resolver, options ...dnscache.DialOption) func(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlyConnection, error) {
+	dialer := dnscache.NewDialer(resolver, dialQUIC, options...)
+	return func(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlyConnection, error) {
+		h, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		if tlsCfg == nil {
+			tlsCfg = &tls.Config{}
+		} else {
+			tlsCfg = tlsCfg.Clone()
+		}
+		if tlsCfg.ServerName == "" {
+			tlsCfg.ServerName = h
+		}
+
+		d := &dial{tlsCfg: tlsCfg, cfg: cfg}
+		c, err := dialer.DialContext(context.WithValue(ctx, dialKey{}, d), "udp", addr)
+		if err != nil {
+			return nil, err
+		}
+		early := d.established(c.RemoteAddr())
+		if early == nil {
+			c.Close()
+			return nil, errors.New("http3dialer: dialed connection is not a QUIC connection")
+		}
+		// Close c too when the QUIC connection is closed, so that the dialer stops
+		// counting it, e.g. for WithLeastConnections.
+		go func() {
+			<-early.Context().Done()
+			c.Close()
+		}()
+		return early, nil
+	}
+}
+
+// dialKey is the context key of the dial in progress.
+type dialKey struct{}
+
+// dial holds the configurations of a dial by DialFunc and the QUIC connections
+// it established, which include the ones of Happy Eyeballs racers.
+type dial struct {
+	tlsCfg *tls.Config
+	cfg    *quic.Config
+
+	mu    sync.Mutex
+	conns []quic.EarlyConnection
+}
+
+// established returns the QUIC connection to the address.
+func (d *dial) established(addr net.Addr) quic.EarlyConnection {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, c := range d.conns {
+		if c.RemoteAddr().String() == addr.String() {
+			return c
+		}
+	}
+	return nil
+}
+
+// dialQUIC is the dial function of the dialer which establishes a QUIC
+// connection to addr, an IP and port, with the configurations of the dial of
+// ctx.
+func dialQUIC(ctx context.Context, network, addr string) (net.Conn, error) {
+	d, ok := ctx.Value(dialKey{}).(*dial)
+	if !ok {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: net.UnknownNetworkError(network)}
+	}
+
+	early, err := quic.DialAddrEarly(ctx, addr, d.tlsCfg, d.cfg)
+	if err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	d.conns = append(d.conns, early)
+	d.mu.Unlock()
+	return &conn{EarlyConnection: early}, nil
+}
+
+// errNotStream is returned by the reads and writes of conn, which is not a stream.
+var errNotStream = errors.New("http3dialer: QUIC connection is not a stream")
+
+// conn is the QUIC connection as `net.Conn` for the dialer, which uses only its
+// addresses and Close.
+type conn struct {
+	quic.EarlyConnection
+}
+
+func (c *conn) Read([]byte) (int, error)         { return 0, errNotStream }
+func (c *conn) Write([]byte) (int, error)        { return 0, errNotStream }
+func (c *conn) Close() error                     { return c.CloseWithError(0, "") }
+func (c *conn) SetDeadline(time.Time) error      { return nil }
+func (c *conn) SetReadDeadline(time.Time) error  { return nil }
+func (c *conn) SetWriteDeadline(time.Time) error { return nil }
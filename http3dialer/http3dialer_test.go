@@ -0,0 +1,112 @@
+package http3dialer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	dnscache "go.mercari.io/go-dnscache"
+)
+
+// testCertificate returns a self-signed certificate for the given host.
+func testCertificate(t *testing.T, host string) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+func TestDialFunc(t *testing.T) {
+	cert, pool := testCertificate(t, "h3.mercari.io")
+	ln, err := quic.ListenAddrEarly("127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"test"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept(context.Background())
+			if err != nil {
+				return
+			}
+			defer conn.CloseWithError(0, "")
+		}
+	}()
+
+	// The first IP does not listen, so the second one is dialed after it fails.
+	resolver, err := dnscache.New(time.Minute, time.Second, dnscache.WithStaticEntries(map[string][]net.IP{
+		"h3.mercari.io": {net.ParseIP("127.0.0.2"), net.ParseIP("127.0.0.1")},
+	}))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resolver.Stop()
+
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	ctx, cancelF := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelF()
+	dial := DialFunc(resolver)
+	conn, err := dial(ctx, net.JoinHostPort("h3.mercari.io", port),
+		&tls.Config{RootCAs: pool, NextProtos: []string{"test"}},
+		&quic.Config{HandshakeIdleTimeout: time.Second})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer conn.CloseWithError(0, "")
+
+	if got := conn.RemoteAddr().String(); got != ln.Addr().String() {
+		t.Fatalf("want %s, got %s", ln.Addr(), got)
+	}
+}
+
+func TestDialFuncOptions(t *testing.T) {
+	resolver, err := dnscache.New(time.Minute, time.Second, dnscache.WithStaticEntries(map[string][]net.IP{
+		"h3.mercari.io": {net.ParseIP("127.0.0.1")},
+	}))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resolver.Stop()
+
+	// The options are applied by the dialer, which refuses the network of QUIC.
+	dial := DialFunc(resolver, dnscache.WithAllowedNetworks("tcp"))
+	_, err = dial(context.Background(), "h3.mercari.io:443", nil, nil)
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) || opErr.Net != "udp" {
+		t.Fatalf("got %v; want the dial of udp to be refused", err)
+	}
+}
@@ -0,0 +1,50 @@
+package dnscache
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewHTTPClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	resolver := &Resolver{
+		cache: map[string]*entry{
+			"deeeet.com": {ips: []net.IP{net.ParseIP("127.0.0.1")}},
+		},
+		lookupTimeout: time.Second,
+	}
+
+	client := NewHTTPClient(resolver, WithRoundRobin())
+	resp, err := client.Get("http://" + net.JoinHostPort("deeeet.com", port))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resp.Body.Close()
+
+	b, _ := io.ReadAll(resp.Body)
+	if string(b) != "ok" {
+		t.Fatalf("want ok, got %q", b)
+	}
+}
+
+func TestNewTransport(t *testing.T) {
+	tr := NewTransport(&Resolver{})
+	if tr.DialContext == nil {
+		t.Fatalf("expect DialContext to be set")
+	}
+	if tr.MaxIdleConnsPerHost != defaultMaxIdleConnsPerHost {
+		t.Fatalf("want %d, got %d", defaultMaxIdleConnsPerHost, tr.MaxIdleConnsPerHost)
+	}
+	if tr.Proxy == nil {
+		t.Fatalf("expect settings of http.DefaultTransport to be kept")
+	}
+}
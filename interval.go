@@ -0,0 +1,79 @@
+package dnscache
+
+import (
+	"strings"
+	"time"
+)
+
+// hostInterval is the refresh interval of the hosts matching pattern.
+type hostInterval struct {
+	pattern  string
+	interval time.Duration
+}
+
+// WithHostRefreshInterval sets the background refresh interval of the hosts
+// matching pattern instead of the frequency given to New, e.g. to refresh hosts
+// behind a GSLB every second while refreshing the others every few minutes.
+// pattern is either a host name or a suffix starting with ".", like
+// ".example.com", which matches all of its subdomains. The most specific
+// pattern wins. Refresh and RefreshContext always refresh all hosts.
+func WithHostRefreshInterval(pattern string, interval time.Duration) Option {
+	return Option{apply: func(r *Resolver) {
+		if interval <= 0 {
+			r.invalid("WithHostRefreshInterval", "non-positive interval for "+pattern)
+			return
+		}
+		r.intervals = append(r.intervals, hostInterval{pattern: strings.ToLower(pattern), interval: interval})
+	}}
+}
+
+// intervalFor returns the refresh interval of host. It must be called with lock held.
+func (r *Resolver) intervalFor(host string) time.Duration {
+	host = strings.ToLower(host)
+	interval, matched := r.freq, -1
+	for _, hi := range r.intervals {
+		ok := host == hi.pattern ||
+			(strings.HasPrefix(hi.pattern, ".") && strings.HasSuffix(host, hi.pattern))
+		if ok && len(hi.pattern) > matched {
+			interval, matched = hi.interval, len(hi.pattern)
+		}
+	}
+	return interval
+}
+
+// tickInterval returns the interval of the ticker, which is the shortest refresh
+// interval. It must be called with lock held.
+func (r *Resolver) tickInterval() time.Duration {
+	tick := r.freq
+	for _, hi := range r.intervals {
+		if hi.interval < tick {
+			tick = hi.interval
+		}
+	}
+	if r.adaptive != nil && r.adaptive.min < tick {
+		tick = r.adaptive.min
+	}
+	return tick
+}
+
+// due reports whether the entry e of host should be refreshed at now by the
+// ticker. Hosts are refreshed at the first tick at which at least their interval
+// minus half a tick has passed since the entry was stored, so that the ticks do
+// not drift against the intervals. It must be called with lock held.
+func (r *Resolver) due(host string, e *entry, now time.Time) bool {
+	return r.dueSince(host, e.updated, e.interval, now)
+}
+
+// dueSince is due of an entry stored at updated with the adaptive interval, if
+// any.
+func (r *Resolver) dueSince(host string, updated time.Time, adaptive time.Duration, now time.Time) bool {
+	if len(r.intervals) == 0 && r.adaptive == nil {
+		return true
+	}
+
+	interval := r.intervalFor(host)
+	if r.adaptive != nil && adaptive > 0 {
+		interval = adaptive
+	}
+	return now.Sub(updated) >= interval-r.tickInterval()/2
+}
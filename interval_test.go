@@ -0,0 +1,70 @@
+package dnscache
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIntervalFor(t *testing.T) {
+	r := &Resolver{freq: time.Minute}
+	WithHostRefreshInterval(".gslb.deeeet.com", time.Second).apply(r)
+	WithHostRefreshInterval("slow.gslb.deeeet.com", 10*time.Second).apply(r)
+
+	cases := []struct {
+		host string
+		want time.Duration
+	}{
+		{"deeeet.com", time.Minute},
+		{"api.gslb.deeeet.com", time.Second},
+		{"API.GSLB.deeeet.com", time.Second},
+		{"slow.gslb.deeeet.com", 10 * time.Second},
+		{"gslb.deeeet.com", time.Minute},
+	}
+	for _, tc := range cases {
+		if got := r.intervalFor(tc.host); got != tc.want {
+			t.Errorf("%s: got %v; want %v", tc.host, got, tc.want)
+		}
+	}
+	if got := r.tickInterval(); got != time.Second {
+		t.Fatalf("got %v; want the shortest interval", got)
+	}
+}
+
+func TestHostRefreshInterval(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		refreshed []string
+	)
+	r := &Resolver{
+		cache:                map[string]*entry{},
+		freq:                 time.Minute,
+		defaultLookupTimeout: time.Second,
+		lookupIPFn: func(ctx context.Context, network, host string) ([]net.IP, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			refreshed = append(refreshed, host)
+			return []net.IP{net.ParseIP("127.0.0.1")}, nil
+		},
+	}
+	WithHostRefreshInterval(".gslb.deeeet.com", time.Second).apply(r)
+
+	updated := time.Now().Add(-2 * time.Second)
+	r.cache["deeeet.com"] = &entry{ips: []net.IP{net.ParseIP("127.0.0.1")}, updated: updated}
+	r.cache["api.gslb.deeeet.com"] = &entry{ips: []net.IP{net.ParseIP("127.0.0.1")}, updated: updated}
+
+	summary := r.refresh(context.Background(), true)
+	if summary.Hosts != 1 || len(refreshed) != 1 || refreshed[0] != "api.gslb.deeeet.com" {
+		t.Fatalf("got %v; want only the host with the short interval to be refreshed", refreshed)
+	}
+
+	refreshed = nil
+	r.Refresh()
+	sort.Strings(refreshed)
+	if len(refreshed) != 2 {
+		t.Fatalf("got %v; want Refresh to refresh all hosts", refreshed)
+	}
+}
@@ -0,0 +1,43 @@
+package dnscache
+
+import "net"
+
+// IPVersionPreference controls the ordering and filtering of IP addresses by
+// their address family.
+type IPVersionPreference int
+
+const (
+	// AnyIPVersion keeps addresses in the order returned by the upstream resolver.
+	AnyIPVersion IPVersionPreference = iota
+
+	// PreferIPv4 orders IPv4 addresses before IPv6 addresses.
+	PreferIPv4
+
+	// PreferIPv6 orders IPv6 addresses before IPv4 addresses.
+	PreferIPv6
+
+	// IPv4Only drops IPv6 addresses.
+	IPv4Only
+
+	// IPv6Only drops IPv4 addresses.
+	IPv6Only
+)
+
+// apply returns ips ordered or filtered by the preference. The relative order
+// of addresses of the same family is kept.
+func (p IPVersionPreference) apply(ips []net.IP) []net.IP {
+	if p == AnyIPVersion {
+		return ips
+	}
+
+	first := make([]net.IP, 0, len(ips))
+	var second []net.IP
+	for _, ip := range ips {
+		if (ip.To4() != nil) == (p == PreferIPv4 || p == IPv4Only) {
+			first = append(first, ip)
+		} else if p == PreferIPv4 || p == PreferIPv6 {
+			second = append(second, ip)
+		}
+	}
+	return append(first, second...)
+}
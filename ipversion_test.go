@@ -0,0 +1,58 @@
+package dnscache
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestIPVersionPreference(t *testing.T) {
+	var (
+		v4a = net.ParseIP("10.0.0.1")
+		v4b = net.ParseIP("10.0.0.2")
+		v6a = net.ParseIP("2001:db8::1")
+		v6b = net.ParseIP("2001:db8::2")
+	)
+	ips := []net.IP{v6a, v4a, v6b, v4b}
+
+	cases := []struct {
+		pref IPVersionPreference
+		want []net.IP
+	}{
+		{AnyIPVersion, []net.IP{v6a, v4a, v6b, v4b}},
+		{PreferIPv4, []net.IP{v4a, v4b, v6a, v6b}},
+		{PreferIPv6, []net.IP{v6a, v6b, v4a, v4b}},
+		{IPv4Only, []net.IP{v4a, v4b}},
+		{IPv6Only, []net.IP{v6a, v6b}},
+	}
+
+	for _, tc := range cases {
+		if got := tc.pref.apply(ips); !reflect.DeepEqual(tc.want, got) {
+			t.Fatalf("preference %d: want %v, got %v", tc.pref, tc.want, got)
+		}
+	}
+}
+
+func TestIPVersionPreferenceNoSuitableAddress(t *testing.T) {
+	originalFunc := lookupIP
+	defer func() {
+		lookupIP = originalFunc
+	}()
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("2001:db8::1")}, nil
+	}
+
+	resolver, err := New(testFreq, testDefaultLookupTimeout, WithIPVersionPreference(IPv4Only))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resolver.Stop()
+
+	if _, err := resolver.LookupIP(context.Background(), "v6.mercari.io"); err == nil {
+		t.Fatalf("expect to be failed")
+	}
+	if _, ok := resolver.cache["v6.mercari.io"]; ok {
+		t.Fatalf("expect failed lookup not to be cached")
+	}
+}
@@ -0,0 +1,161 @@
+package dnscache
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencyBuckets are the upper bounds of the buckets of lookup latency histograms.
+var latencyBuckets = []time.Duration{
+	time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// LatencyHistogram is a distribution of lookup latencies.
+type LatencyHistogram struct {
+	// Buckets are the upper bounds of the buckets. Counts has one more element
+	// than Buckets which counts the latencies above the last bound.
+	Buckets []time.Duration
+	Counts  []uint64
+
+	Count uint64
+	Sum   time.Duration
+}
+
+// observe adds a latency to the histogram.
+func (h *LatencyHistogram) observe(d time.Duration) {
+	i := sort.Search(len(h.Buckets), func(i int) bool { return d <= h.Buckets[i] })
+	h.Counts[i]++
+	h.Count++
+	h.Sum += d
+}
+
+// Mean returns the mean latency.
+func (h LatencyHistogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// Quantile returns the upper bound of the bucket containing the q-quantile
+// (0 to 1) of the latencies. It returns the last bound when the quantile is above
+// it and 0 when nothing is observed.
+func (h LatencyHistogram) Quantile(q float64) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+
+	rank := uint64(q*float64(h.Count) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	var seen uint64
+	for i, c := range h.Counts {
+		seen += c
+		if seen >= rank && i < len(h.Buckets) {
+			return h.Buckets[i]
+		}
+	}
+	return h.Buckets[len(h.Buckets)-1]
+}
+
+// HostLookupStats is the lookup latency distribution of a host. Host is empty
+// for the hosts aggregated beyond the limit of WithLookupLatency.
+type HostLookupStats struct {
+	Host    string
+	Latency LatencyHistogram
+}
+
+// WithLookupLatency maintains a lookup latency histogram per host, which is
+// queried by `LookupStats`, since an aggregate latency hides a single slow zone.
+// At most maxHosts hosts are tracked separately to bound the memory, and the
+// lookups of the other hosts are aggregated into one histogram.
+func WithLookupLatency(maxHosts int) Option {
+	return Option{apply: func(r *Resolver) {
+		if maxHosts < 0 {
+			r.invalid("WithLookupLatency", "negative number of hosts")
+		}
+		r.latency = &latencyStats{maxHosts: maxHosts, hosts: make(map[string]*LatencyHistogram)}
+	}}
+}
+
+// latencyStats keeps lookup latency histograms per host.
+type latencyStats struct {
+	maxHosts int
+
+	mu     sync.Mutex
+	hosts  map[string]*LatencyHistogram
+	others *LatencyHistogram
+}
+
+func newLatencyHistogram() *LatencyHistogram {
+	return &LatencyHistogram{
+		Buckets: latencyBuckets,
+		Counts:  make([]uint64, len(latencyBuckets)+1),
+	}
+}
+
+// observe records a lookup latency of host.
+func (s *latencyStats) observe(host string, d time.Duration) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, ok := s.hosts[host]
+	if !ok {
+		if len(s.hosts) < s.maxHosts {
+			h = newLatencyHistogram()
+			s.hosts[host] = h
+		} else {
+			if s.others == nil {
+				s.others = newLatencyHistogram()
+			}
+			h = s.others
+		}
+	}
+	h.observe(d)
+}
+
+// LookupStats returns a snapshot of the lookup latency histograms per host
+// sorted by host, with the aggregate of the untracked hosts first. It returns nil
+// unless WithLookupLatency is given.
+func (r *Resolver) LookupStats() []HostLookupStats {
+	s := r.latency
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := make([]HostLookupStats, 0, len(s.hosts)+1)
+	if s.others != nil {
+		stats = append(stats, HostLookupStats{Latency: s.others.clone()})
+	}
+	for host, h := range s.hosts {
+		stats = append(stats, HostLookupStats{Host: host, Latency: h.clone()})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Host < stats[j].Host })
+	return stats
+}
+
+// clone returns a copy of the histogram which does not share its counts.
+func (h *LatencyHistogram) clone() LatencyHistogram {
+	c := *h
+	c.Counts = append([]uint64(nil), h.Counts...)
+	return c
+}
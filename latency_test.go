@@ -0,0 +1,60 @@
+package dnscache
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestLatencyHistogram(t *testing.T) {
+	h := newLatencyHistogram()
+	for _, d := range []time.Duration{500 * time.Microsecond, 3 * time.Millisecond, 3 * time.Millisecond, 20 * time.Second} {
+		h.observe(d)
+	}
+
+	if h.Count != 4 || h.Counts[0] != 1 || h.Counts[2] != 2 || h.Counts[len(h.Counts)-1] != 1 {
+		t.Fatalf("got %v", h.Counts)
+	}
+	if got, want := h.Quantile(0.5), 5*time.Millisecond; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := h.Quantile(1), 10*time.Second; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := h.Mean(), (20*time.Second+6500*time.Microsecond)/4; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got := (LatencyHistogram{}).Quantile(0.5); got != 0 {
+		t.Fatalf("got %v; want 0", got)
+	}
+}
+
+func TestLookupStats(t *testing.T) {
+	r := &Resolver{
+		cache:         map[string]*entry{},
+		lookupTimeout: time.Second,
+		lookupIPFn: func(ctx context.Context, network, host string) ([]net.IP, error) {
+			return []net.IP{net.ParseIP("127.0.0.1")}, nil
+		},
+	}
+	if got := r.LookupStats(); got != nil {
+		t.Fatalf("got %v; want nil without WithLookupLatency", got)
+	}
+	WithLookupLatency(2).apply(r)
+
+	for _, host := range []string{"a.deeeet.com", "b.deeeet.com", "b.deeeet.com", "c.deeeet.com", "d.deeeet.com"} {
+		r.LookupIP(context.Background(), host)
+	}
+
+	stats := r.LookupStats()
+	if len(stats) != 3 {
+		t.Fatalf("got %d; want 2 hosts and the others", len(stats))
+	}
+	if stats[0].Host != "" || stats[0].Latency.Count != 2 {
+		t.Fatalf("got %+v; want 2 lookups of the other hosts", stats[0])
+	}
+	if stats[1].Host != "a.deeeet.com" || stats[1].Latency.Count != 1 || stats[2].Host != "b.deeeet.com" || stats[2].Latency.Count != 2 {
+		t.Fatalf("got %+v", stats[1:])
+	}
+}
@@ -0,0 +1,33 @@
+package dnscache
+
+import "log/slog"
+
+// Logger is the logger used by the resolver. Arguments are alternating keys and
+// values like those of slog. *slog.Logger implements it, and loggers of other
+// libraries like zap or zerolog can be adapted with a few lines.
+type Logger interface {
+	Error(msg string, args ...any)
+	Warn(msg string, args ...any)
+}
+
+// withArgs returns a logger which appends args to the arguments of every record.
+func withArgs(logger Logger, args []any) Logger {
+	if l, ok := logger.(*slog.Logger); ok {
+		return l.With(args...)
+	}
+	return &argsLogger{logger: logger, args: args}
+}
+
+// argsLogger appends fixed arguments to every record of the logger.
+type argsLogger struct {
+	logger Logger
+	args   []any
+}
+
+func (l *argsLogger) Error(msg string, args ...any) {
+	l.logger.Error(msg, append(args[:len(args):len(args)], l.args...)...)
+}
+
+func (l *argsLogger) Warn(msg string, args ...any) {
+	l.logger.Warn(msg, append(args[:len(args):len(args)], l.args...)...)
+}
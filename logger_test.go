@@ -0,0 +1,37 @@
+package dnscache
+
+import (
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+type testLogger struct {
+	records []string
+}
+
+func (l *testLogger) Error(msg string, args ...any) {
+	l.records = append(l.records, fmt.Sprint(append([]any{"ERROR", msg}, args...)...))
+}
+
+func (l *testLogger) Warn(msg string, args ...any) {
+	l.records = append(l.records, fmt.Sprint(append([]any{"WARN", msg}, args...)...))
+}
+
+func TestCustomLogger(t *testing.T) {
+	logger := &testLogger{}
+	r, err := New(time.Minute, time.Second, WithLogger(logger), WithLogAttrs(slog.String("resolver", "internal")))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer r.Stop()
+
+	r.logger.Warn("slow DNS lookup", "addr", "deeeet.com")
+	if len(logger.records) != 1 {
+		t.Fatalf("got %d records; want 1", len(logger.records))
+	}
+	if got, want := logger.records[0], fmt.Sprint("WARN", "slow DNS lookup", "addr", "deeeet.com", slog.String("resolver", "internal")); got != want {
+		t.Fatalf("got %q; want %q", got, want)
+	}
+}
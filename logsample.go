@@ -0,0 +1,80 @@
+package dnscache
+
+import (
+	"sync"
+	"time"
+)
+
+// WithRefreshErrorLogInterval limits the logs of refresh failures to the first
+// failure of a host and then at most one per interval while the host keeps
+// failing, so that a flapping upstream resolver does not flood the logs. The
+// number of failures which were not logged since the previous log is attached
+// as "suppressed". A successful refresh of the host resets the limit.
+func WithRefreshErrorLogInterval(interval time.Duration) Option {
+	return Option{apply: func(r *Resolver) {
+		r.invalidDuration("WithRefreshErrorLogInterval", "interval", interval)
+		r.errorLog = &errorLogLimiter{interval: interval, hosts: make(map[string]*errorLogState)}
+	}}
+}
+
+// errorLogLimiter limits error logs per host.
+type errorLogLimiter struct {
+	interval time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*errorLogState
+}
+
+type errorLogState struct {
+	logged     time.Time
+	suppressed int
+}
+
+// allow reports whether a failure of host should be logged at now and returns
+// the number of failures suppressed since the previous log.
+func (l *errorLogLimiter) allow(host string, now time.Time) (bool, int) {
+	if l == nil {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s, ok := l.hosts[host]
+	if !ok {
+		l.hosts[host] = &errorLogState{logged: now}
+		return true, 0
+	}
+	if now.Sub(s.logged) < l.interval {
+		s.suppressed++
+		return false, 0
+	}
+
+	suppressed := s.suppressed
+	s.logged, s.suppressed = now, 0
+	return true, suppressed
+}
+
+// reset forgets the failures of host.
+func (l *errorLogLimiter) reset(host string) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.hosts, host)
+}
+
+// logRefreshError logs the refresh failure of addr unless it is suppressed.
+func (r *Resolver) logRefreshError(addr string, err error) {
+	ok, suppressed := r.errorLog.allow(addr, r.now())
+	if !ok {
+		return
+	}
+
+	args := []any{"error", err, "addr", addr}
+	if suppressed > 0 {
+		args = append(args, "suppressed", suppressed)
+	}
+	r.logger.Error("failed to refresh DNS cache", args...)
+}
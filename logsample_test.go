@@ -0,0 +1,58 @@
+package dnscache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRefreshErrorLogInterval(t *testing.T) {
+	clock := newFakeClock()
+	fail := true
+	buf := new(bytes.Buffer)
+	r := &Resolver{
+		cache: map[string]*entry{"deeeet.com": {ips: []net.IP{net.ParseIP("127.0.0.1")}}},
+		lookupIPFn: func(ctx context.Context, network, host string) ([]net.IP, error) {
+			if fail {
+				return nil, errors.New("lookup failed")
+			}
+			return []net.IP{net.ParseIP("127.0.0.1")}, nil
+		},
+		defaultLookupTimeout: time.Second,
+		clock:                clock,
+		logger:               slog.New(slog.NewTextHandler(buf, nil)),
+	}
+	WithRefreshErrorLogInterval(time.Minute).apply(r)
+
+	logs := func() []string {
+		return strings.Split(strings.TrimSpace(buf.String()), "\n")
+	}
+
+	for i := 0; i < 3; i++ {
+		r.Refresh()
+		clock.Advance(10 * time.Second)
+	}
+	if got := logs(); len(got) != 1 {
+		t.Fatalf("got %d logs; want only the first failure to be logged", len(got))
+	}
+
+	clock.Advance(time.Minute)
+	r.Refresh()
+	if got := logs(); len(got) != 2 || !strings.Contains(got[1], "suppressed=2") {
+		t.Fatalf("got %q; want a log with 2 suppressed failures", got)
+	}
+
+	// A success resets the limit.
+	fail = false
+	r.Refresh()
+	fail = true
+	r.Refresh()
+	if got := logs(); len(got) != 3 || strings.Contains(got[2], "suppressed") {
+		t.Fatalf("got %q; want the failure after the success to be logged", got)
+	}
+}
@@ -0,0 +1,28 @@
+package dnscache
+
+import (
+	"context"
+	"net"
+)
+
+// LookupHostFunc returns a function of the simple string-based resolver signature
+// accepted by some libraries, e.g. older Kafka and Cassandra clients, which
+// returns the IPs of host from the cache as strings. IP literals are returned
+// as they are. The lookup timeout of the resolver is applied to cache misses.
+func (r *Resolver) LookupHostFunc() func(host string) ([]string, error) {
+	return func(host string) ([]string, error) {
+		if ip := net.ParseIP(host); ip != nil {
+			return []string{host}, nil
+		}
+
+		ips, err := r.Fetch(context.Background(), host)
+		if err != nil {
+			return nil, err
+		}
+		addrs := make([]string, len(ips))
+		for i, ip := range ips {
+			addrs[i] = ip.String()
+		}
+		return addrs, nil
+	}
+}
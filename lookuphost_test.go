@@ -0,0 +1,32 @@
+package dnscache
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestLookupHostFunc(t *testing.T) {
+	resolver := &Resolver{
+		cache: map[string]*entry{
+			"deeeet.com": {ips: []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("::1")}},
+		},
+	}
+
+	lookupHost := resolver.LookupHostFunc()
+	addrs, err := lookupHost("deeeet.com")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if want := []string{"127.0.0.1", "::1"}; !reflect.DeepEqual(want, addrs) {
+		t.Fatalf("want %v, got %v", want, addrs)
+	}
+
+	addrs, err = lookupHost("10.0.0.1")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if want := []string{"10.0.0.1"}; !reflect.DeepEqual(want, addrs) {
+		t.Fatalf("want %v, got %v", want, addrs)
+	}
+}
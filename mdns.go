@@ -0,0 +1,97 @@
+package dnscache
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	// mdnsTimeout bounds an mDNS query when the context has no deadline.
+	mdnsTimeout = 2 * time.Second
+
+	// mdnsDomain is the domain resolved by multicast DNS (RFC 6762).
+	mdnsDomain = ".local"
+)
+
+// mdnsAddr is the IPv4 multicast DNS group address.
+// This is used to replace the destination of queries when test.
+var mdnsAddr = "224.0.0.251:5353"
+
+// isMDNSName reports whether host should be resolved by multicast DNS.
+func isMDNSName(host string) bool {
+	return strings.HasSuffix(normalizeHost(host), mdnsDomain)
+}
+
+// lookupMDNS resolves host by sending a one-shot multicast DNS query
+// (RFC 6762, section 5.1) asking for the records of the given network and
+// returns the addresses of the first response which answers it.
+func lookupMDNS(ctx context.Context, network, host string) (*entry, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancelF context.CancelFunc
+		ctx, cancelF = context.WithTimeout(ctx, mdnsTimeout)
+		defer cancelF()
+	}
+
+	dst, err := net.ResolveUDPAddr("udp4", mdnsAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	qtypes := networkTypes(network)
+	q := &dnsMessage{id: uint16(rand.Uint32())}
+	for _, qtype := range qtypes {
+		q.questions = append(q.questions, dnsQuestion{name: fqdn(host), typ: qtype, class: classINET})
+	}
+	b, err := q.pack()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	defer setDeadline(ctx, conn)()
+
+	if _, err := conn.WriteTo(b, dst); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 65535)
+	name := strings.ToLower(fqdn(host))
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if isTimeout(err) {
+				return nil, &net.DNSError{Err: "no such host", Name: host, Server: mdnsAddr, IsNotFound: true, IsTimeout: true}
+			}
+			return nil, err
+		}
+
+		m, err := parseMessage(buf[:n])
+		if err != nil || m.flags&flagQR == 0 {
+			continue
+		}
+
+		// Responders may answer other names in the same message.
+		answers := m.answers[:0]
+		for _, rr := range m.answers {
+			if strings.ToLower(rr.name) == name {
+				answers = append(answers, rr)
+			}
+		}
+		m.answers = answers
+
+		var ips []net.IP
+		for _, qtype := range qtypes {
+			ips = append(ips, answerIPs(m, qtype)...)
+		}
+		if len(ips) > 0 {
+			return &entry{ips: ips}, nil
+		}
+	}
+}
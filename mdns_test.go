@@ -0,0 +1,68 @@
+package dnscache
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMDNS(t *testing.T) {
+	origAddr := mdnsAddr
+	defer func() {
+		mdnsAddr = origAddr
+	}()
+
+	originalFunc := lookupIP
+	defer func() {
+		lookupIP = originalFunc
+	}()
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		t.Fatalf("expect .local name not to be looked up by DNS: %s", host)
+		return nil, nil
+	}
+
+	want := net.ParseIP("192.168.1.10")
+	mdnsAddr = testNameserver(t, func(q *dnsMessage, tcp bool) *dnsMessage {
+		if len(q.questions) != 2 || q.questions[0].name != "printer.local." {
+			return nil
+		}
+		return &dnsMessage{
+			flags: flagAA,
+			answers: []dnsRR{
+				{name: "other.local.", typ: typeA, class: classINET | classCacheFlush, ttl: 120, data: []byte{192, 168, 1, 99}},
+				{name: "printer.local.", typ: typeA, class: classINET | classCacheFlush, ttl: 120, data: want.To4()},
+			},
+		}
+	})
+
+	resolver, err := New(testFreq, testDefaultLookupTimeout, WithMDNS())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resolver.Stop()
+
+	got, err := resolver.Fetch(context.Background(), "printer.local")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(got) != 1 || !got[0].Equal(want) {
+		t.Fatalf("want [%v], got %v", want, got)
+	}
+}
+
+func TestMDNSNoResponse(t *testing.T) {
+	origAddr := mdnsAddr
+	defer func() {
+		mdnsAddr = origAddr
+	}()
+	mdnsAddr = testNameserver(t, func(q *dnsMessage, tcp bool) *dnsMessage {
+		return nil
+	})
+
+	ctx, cancelF := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancelF()
+	if _, err := lookupMDNS(ctx, "ip", "printer.local"); err == nil {
+		t.Fatalf("expect to be failed")
+	}
+}
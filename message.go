@@ -0,0 +1,135 @@
+package dnscache
+
+import (
+	"encoding/binary"
+	"net"
+)
+
+// RRType is the type of a DNS resource record.
+type RRType uint16
+
+// Resource record types which have a typed view in Record.
+const (
+	TypeA     RRType = RRType(typeA)
+	TypeNS    RRType = RRType(typeNS)
+	TypeCNAME RRType = RRType(typeCNAME)
+	TypeSOA   RRType = RRType(typeSOA)
+	TypePTR   RRType = RRType(typePTR)
+	TypeMX    RRType = RRType(typeMX)
+	TypeTXT   RRType = RRType(typeTXT)
+	TypeAAAA  RRType = RRType(typeAAAA)
+	TypeSRV   RRType = RRType(typeSRV)
+	TypeOPT   RRType = RRType(typeOPT)
+	TypeRRSIG RRType = 46
+)
+
+// Message is a DNS response message kept in wire format (WithWireFormat).
+type Message struct {
+	raw []byte
+}
+
+// Raw returns the message in wire format. It must not be modified.
+func (m Message) Raw() []byte {
+	return m.raw
+}
+
+// Sections decodes the message and returns the records of the answer, authority
+// and additional sections.
+func (m Message) Sections() (answers, authorities, additionals []Record, err error) {
+	msg, err := parseMessage(m.raw)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return toRecords(msg.answers), toRecords(msg.authorities), toRecords(msg.additionals), nil
+}
+
+// Authenticated reports whether the nameserver set the AD bit of the message.
+func (m Message) Authenticated() bool {
+	return len(m.raw) >= 4 && binary.BigEndian.Uint16(m.raw[2:])&flagAD != 0
+}
+
+// Record is a resource record of a Message. Names embedded in Data are not compressed.
+type Record struct {
+	Name  string
+	Type  RRType
+	Class uint16
+	TTL   uint32
+	Data  []byte
+}
+
+func toRecords(rrs []dnsRR) []Record {
+	records := make([]Record, len(rrs))
+	for i, rr := range rrs {
+		records[i] = Record{
+			Name:  rr.name,
+			Type:  RRType(rr.typ),
+			Class: rr.class,
+			TTL:   rr.ttl,
+			Data:  rr.data,
+		}
+	}
+	return records
+}
+
+// IP returns the address of an A or AAAA record, or nil for other records.
+func (r Record) IP() net.IP {
+	switch {
+	case r.Type == TypeA && len(r.Data) == net.IPv4len:
+		return net.IPv4(r.Data[0], r.Data[1], r.Data[2], r.Data[3])
+	case r.Type == TypeAAAA && len(r.Data) == net.IPv6len:
+		return net.IP(r.Data)
+	default:
+		return nil
+	}
+}
+
+// Target returns the domain name of a CNAME, NS, PTR, MX or SRV record, or an
+// empty string for other records.
+func (r Record) Target() string {
+	off := 0
+	switch r.Type {
+	case TypeCNAME, TypeNS, TypePTR:
+	case TypeMX:
+		off = 2
+	case TypeSRV:
+		off = 6
+	default:
+		return ""
+	}
+	if len(r.Data) < off {
+		return ""
+	}
+	name, _, err := readName(r.Data, off)
+	if err != nil {
+		return ""
+	}
+	return name
+}
+
+// Text returns the strings of a TXT record, or nil for other records.
+func (r Record) Text() []string {
+	if r.Type != TypeTXT {
+		return nil
+	}
+	var txt []string
+	for b := r.Data; len(b) > 0; {
+		n := int(b[0])
+		if len(b) < 1+n {
+			break
+		}
+		txt = append(txt, string(b[1:1+n]))
+		b = b[1+n:]
+	}
+	return txt
+}
+
+// Messages returns the raw DNS response messages of the cached entry of addr, one
+// per query type. It returns false if addr is not in the cache or the resolver is
+// not in wire format mode.
+func (r *Resolver) Messages(addr string) ([]Message, bool) {
+	e, ok := r.cached(addr)
+	if !ok || e.messages == nil {
+		return nil, false
+	}
+	return e.messages, true
+}
@@ -0,0 +1,75 @@
+package dnscache
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestWireFormat(t *testing.T) {
+	addr := testNameserver(t, func(q *dnsMessage, tcp bool) *dnsMessage {
+		m := answerA(q, net.ParseIP("10.0.0.1"), net.ParseIP("2001:db8::1"))
+		m.flags |= flagAD
+		m.answers = append(m.answers, dnsRR{name: q.questions[0].name, typ: uint16(TypeRRSIG), class: classINET, ttl: 60, data: []byte{1, 2, 3}})
+		ns, _ := appendName(nil, "ns1.mercari.io.")
+		m.authorities = []dnsRR{{name: "mercari.io.", typ: typeNS, class: classINET, ttl: 3600, data: ns}}
+		return m
+	})
+
+	resolver, err := New(testFreq, testDefaultLookupTimeout, WithNameserver(addr), WithWireFormat())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resolver.Stop()
+
+	if _, err := resolver.LookupIP(context.Background(), "api.mercari.io"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	msgs, ok := resolver.Messages("api.mercari.io")
+	if !ok {
+		t.Fatalf("expect messages to be cached")
+	}
+	if got, want := len(msgs), 2; got != want {
+		t.Fatalf("got %d messages, want %d", got, want)
+	}
+
+	var ips []net.IP
+	for _, msg := range msgs {
+		if !msg.Authenticated() {
+			t.Fatalf("expect message to be authenticated")
+		}
+		answers, authorities, _, err := msg.Sections()
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if got, want := answers[len(answers)-1].Type, TypeRRSIG; got != want {
+			t.Fatalf("got type %d, want %d", got, want)
+		}
+		if got, want := authorities[0].Target(), "ns1.mercari.io."; got != want {
+			t.Fatalf("got target %q, want %q", got, want)
+		}
+		for _, rr := range answers {
+			if ip := rr.IP(); ip != nil {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	if len(ips) != 2 || !containsIP(ips, net.ParseIP("10.0.0.1")) || !containsIP(ips, net.ParseIP("2001:db8::1")) {
+		t.Fatalf("got %v", ips)
+	}
+}
+
+func TestWireFormatWithoutNameserver(t *testing.T) {
+	if _, err := New(testFreq, testDefaultLookupTimeout, WithWireFormat()); err == nil {
+		t.Fatalf("expect to be failed")
+	}
+}
+
+func TestRecordText(t *testing.T) {
+	r := Record{Type: TypeTXT, Data: []byte("\x05hello\x05world")}
+	if got, want := r.Text(), []string{"hello", "world"}; !reflect.DeepEqual(want, got) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+}
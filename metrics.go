@@ -0,0 +1,122 @@
+package dnscache
+
+import (
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// Metrics receives measurements of a resolver, e.g. to export them to a
+// monitoring system. Methods are called synchronously from lookups and
+// refreshes, so they must be fast and safe for concurrent use.
+type Metrics interface {
+	// CacheHit is called when Fetch serves host from the cache.
+	CacheHit(host string)
+
+	// CacheMiss is called when Fetch has to look host up.
+	CacheMiss(host string)
+
+	// LookupDone is called when a lookup of host, including its retries, finishes.
+	LookupDone(host string, d time.Duration, err error)
+
+	// RefreshDone is called when a refresh cycle finishes with the number of hosts
+	// which failed to be refreshed.
+	RefreshDone(d time.Duration, failures int)
+}
+
+// DialMetrics is implemented by Metrics which also receive the outcome of every
+// dial by a Dialer with WithDialStats. The connect latency d is measured whether
+// the dial failed or not.
+type DialMetrics interface {
+	DialDone(host string, ip net.IP, d time.Duration, err error)
+}
+
+// WithMetrics reports measurements of the resolver to m. It can be given more
+// than once to report to several Metrics.
+func WithMetrics(m Metrics) Option {
+	return Option{apply: func(r *Resolver) {
+		r.metrics = append(r.metrics, m)
+	}}
+}
+
+// metricsList fans measurements out to every configured Metrics.
+type metricsList []Metrics
+
+func (l metricsList) cacheHit(host string) {
+	for _, m := range l {
+		m.CacheHit(host)
+	}
+}
+
+func (l metricsList) cacheMiss(host string) {
+	for _, m := range l {
+		m.CacheMiss(host)
+	}
+}
+
+func (l metricsList) lookupDone(host string, d time.Duration, err error) {
+	for _, m := range l {
+		m.LookupDone(host, d, err)
+	}
+}
+
+func (l metricsList) dialDone(host string, ip net.IP, d time.Duration, err error) {
+	for _, m := range l {
+		if dm, ok := m.(DialMetrics); ok {
+			dm.DialDone(host, ip, d, err)
+		}
+	}
+}
+
+func (l metricsList) refreshDone(d time.Duration, failures int) {
+	for _, m := range l {
+		m.RefreshDone(d, failures)
+	}
+}
+
+// Len returns the number of hosts in the cache, excluding static entries.
+func (r *Resolver) Len() int {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return len(r.cache)
+}
+
+// resolverStats counts fetches and lookups of a resolver.
+type resolverStats struct {
+	hits         atomic.Int64
+	misses       atomic.Int64
+	lookups      atomic.Int64
+	lookupErrors atomic.Int64
+
+	// lastRefresh is the time of the last refresh cycle in Unix nanoseconds.
+	lastRefresh atomic.Int64
+
+	// failedRefreshes is the number of consecutive failed refresh cycles and
+	// lastRefreshFailures is the number of hosts which failed in the last one.
+	failedRefreshes     atomic.Int64
+	lastRefreshFailures atomic.Int64
+}
+
+// maxLookupErrors is the maximum number of hosts whose last lookup error is kept.
+const maxLookupErrors = 64
+
+// lookupError is the last lookup error of a host.
+type lookupError struct {
+	err  error
+	time time.Time
+}
+
+// recordError keeps err as the last lookup error of addr.
+func (r *Resolver) recordError(addr string, err error) {
+	r.stats.lookupErrors.Add(1)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.lookupErrors == nil {
+		r.lookupErrors = make(map[string]lookupError)
+	}
+	if _, ok := r.lookupErrors[addr]; !ok {
+		makeRoom(r.lookupErrors, maxLookupErrors, nil)
+	}
+	r.lookupErrors[addr] = lookupError{err: err, time: r.now()}
+}
@@ -0,0 +1,91 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testMetrics struct {
+	mu       sync.Mutex
+	hits     int
+	misses   int
+	lookups  int
+	errors   int
+	refresh  int
+	failures int
+}
+
+func (m *testMetrics) CacheHit(host string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hits++
+}
+
+func (m *testMetrics) CacheMiss(host string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.misses++
+}
+
+func (m *testMetrics) LookupDone(host string, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lookups++
+	if err != nil {
+		m.errors++
+	}
+}
+
+func (m *testMetrics) RefreshDone(d time.Duration, failures int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.refresh++
+	m.failures += failures
+}
+
+func TestMetrics(t *testing.T) {
+	m := &testMetrics{}
+	r := &Resolver{
+		cache:         map[string]*entry{},
+		lookupTimeout: time.Second,
+		metrics:       metricsList{m},
+		lookupIPFn: func(ctx context.Context, network, host string) ([]net.IP, error) {
+			if host == "fail.deeeet.com" {
+				return nil, errors.New("lookup failed")
+			}
+			return []net.IP{net.ParseIP("127.0.0.1")}, nil
+		},
+		defaultLookupTimeout: time.Second,
+		logger:               slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := r.Fetch(context.Background(), "deeeet.com"); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	if _, err := r.Fetch(context.Background(), "fail.deeeet.com"); err == nil {
+		t.Fatalf("expect to be failed")
+	}
+	if m.hits != 2 || m.misses != 2 {
+		t.Fatalf("got %d hits and %d misses; want 2 and 2", m.hits, m.misses)
+	}
+	if m.lookups != 2 || m.errors != 1 {
+		t.Fatalf("got %d lookups and %d errors; want 2 and 1", m.lookups, m.errors)
+	}
+	if got := r.Len(); got != 1 {
+		t.Fatalf("got %d; want 1", got)
+	}
+
+	r.cache["fail.deeeet.com"] = &entry{ips: []net.IP{net.ParseIP("127.0.0.2")}}
+	r.Refresh()
+	if m.refresh != 1 || m.failures != 1 {
+		t.Fatalf("got %d refreshes and %d failures; want 1 and 1", m.refresh, m.failures)
+	}
+}
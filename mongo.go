@@ -0,0 +1,102 @@
+package dnscache
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// lookupTXT is a wrapper of systemResolver.LookupTXT.
+// This is used to replace lookup function when test.
+var lookupTXT = func(ctx context.Context, name string) ([]string, error) {
+	return systemResolver.LookupTXT(ctx, name)
+}
+
+// mongoTXTOptions are the options allowed in the TXT record of a seedlist.
+var mongoTXTOptions = map[string]bool{
+	"authsource":   true,
+	"replicaset":   true,
+	"loadbalanced": true,
+}
+
+// SeedList is the seedlist of a `mongodb+srv://` URI resolved by MongoSeedList.
+type SeedList struct {
+	// Hosts is the sorted list of host:port of the SRV targets.
+	Hosts []string
+
+	// Options are the URI options from the TXT record, with tls=true added as the
+	// specification defines for SRV connection strings.
+	Options url.Values
+}
+
+// URI returns the `mongodb://` connection string of the seedlist, so that the
+// driver uses the resolved hosts as they are without its own SRV lookup.
+func (s *SeedList) URI() string {
+	u := url.URL{Scheme: "mongodb", Host: strings.Join(s.Hosts, ","), Path: "/", RawQuery: s.Options.Encode()}
+	return u.String()
+}
+
+// MongoSeedList resolves the seedlist of the `mongodb+srv://` connection string of
+// the given host following the MongoDB specification: the SRV records of
+// `_mongodb._tcp.host` give the hosts, which must be in the parent domain of host,
+// and its TXT record gives the options. The SRV and TXT records are fetched from
+// the cache like FetchMX does, and so are the IPs of the hosts, so that the
+// Dialer, which can be set to the driver by `options.ClientOptions.SetDialer`,
+// dials them without lookups.
+//
+// Call it periodically to get the host list kept up-to-date by refreshes.
+func (r *Resolver) MongoSeedList(ctx context.Context, host string) (*SeedList, error) {
+	host = normalizeHost(host)
+	labels := strings.Split(host, ".")
+	if len(labels) < 3 {
+		return nil, fmt.Errorf("dnscache: %q must have at least 3 labels for SRV lookup", host)
+	}
+	parent := "." + strings.Join(labels[1:], ".")
+
+	srvs, err := r.fetchSRV(ctx, "mongodb", "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+
+	seeds := &SeedList{Options: url.Values{}}
+	for _, srv := range srvs {
+		target := normalizeHost(srv.Target)
+		if !strings.HasSuffix(target, parent) {
+			return nil, fmt.Errorf("dnscache: SRV target %q is not in the domain of %q", target, host)
+		}
+		if _, err := r.Fetch(ctx, target); err != nil {
+			return nil, err
+		}
+		seeds.Hosts = append(seeds.Hosts, net.JoinHostPort(target, strconv.Itoa(int(srv.Port))))
+	}
+	if len(seeds.Hosts) == 0 {
+		return nil, &net.DNSError{Err: "no SRV records", Name: host, IsNotFound: true}
+	}
+	sort.Strings(seeds.Hosts)
+
+	txts, err := r.fetchTXT(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(txts) > 1 {
+		return nil, fmt.Errorf("dnscache: %q has multiple TXT records", host)
+	}
+	if len(txts) == 1 {
+		opts, err := url.ParseQuery(txts[0])
+		if err != nil {
+			return nil, fmt.Errorf("dnscache: invalid TXT record of %q: %w", host, err)
+		}
+		for k, v := range opts {
+			if !mongoTXTOptions[strings.ToLower(k)] {
+				return nil, fmt.Errorf("dnscache: option %q is not allowed in TXT record of %q", k, host)
+			}
+			seeds.Options[k] = v
+		}
+	}
+	seeds.Options.Set("tls", "true")
+	return seeds, nil
+}
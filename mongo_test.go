@@ -0,0 +1,182 @@
+package dnscache
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestMongoSeedList(t *testing.T) {
+	origSRV, origTXT := lookupSRV, lookupTXT
+	defer func() {
+		lookupSRV, lookupTXT = origSRV, origTXT
+	}()
+
+	var srvLookups, txtLookups int
+	lookupSRV = func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+		srvLookups++
+		return "", []*net.SRV{
+			{Target: "db2.mongo.mercari.io.", Port: 27017},
+			{Target: "db1.mongo.mercari.io.", Port: 27017},
+		}, nil
+	}
+	lookupTXT = func(ctx context.Context, name string) ([]string, error) {
+		txtLookups++
+		return []string{"replicaSet=rs0&authSource=admin"}, nil
+	}
+
+	resolver := &Resolver{
+		cache: map[string]*entry{
+			"db1.mongo.mercari.io": {ips: []net.IP{net.ParseIP("10.0.0.1")}},
+			"db2.mongo.mercari.io": {ips: []net.IP{net.ParseIP("10.0.0.2")}},
+		},
+	}
+
+	var seeds *SeedList
+	for i := 0; i < 2; i++ {
+		var err error
+		if seeds, err = resolver.MongoSeedList(context.Background(), "cluster.mongo.mercari.io"); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	if srvLookups != 1 || txtLookups != 1 {
+		t.Fatalf("expect SRV and TXT records to be cached, got %d and %d lookups", srvLookups, txtLookups)
+	}
+	if want := []string{"db1.mongo.mercari.io:27017", "db2.mongo.mercari.io:27017"}; !reflect.DeepEqual(want, seeds.Hosts) {
+		t.Fatalf("want %v, got %v", want, seeds.Hosts)
+	}
+
+	want := "mongodb://db1.mongo.mercari.io:27017,db2.mongo.mercari.io:27017/?authSource=admin&replicaSet=rs0&tls=true"
+	if got := seeds.URI(); got != want {
+		t.Fatalf("want %s, got %s", want, got)
+	}
+}
+
+func TestMongoSeedListError(t *testing.T) {
+	origSRV, origTXT := lookupSRV, lookupTXT
+	defer func() {
+		lookupSRV, lookupTXT = origSRV, origTXT
+	}()
+
+	cases := map[string]struct {
+		host string
+		srvs []*net.SRV
+		txts []string
+	}{
+		"too few labels": {
+			host: "mercari.io",
+		},
+		"target out of domain": {
+			host: "cluster.mongo.mercari.io",
+			srvs: []*net.SRV{{Target: "db.evil.io.", Port: 27017}},
+		},
+		"multiple TXT records": {
+			host: "cluster.mongo.mercari.io",
+			srvs: []*net.SRV{{Target: "db1.mongo.mercari.io.", Port: 27017}},
+			txts: []string{"replicaSet=rs0", "authSource=admin"},
+		},
+		"not allowed option": {
+			host: "cluster.mongo.mercari.io",
+			srvs: []*net.SRV{{Target: "db1.mongo.mercari.io.", Port: 27017}},
+			txts: []string{"ssl=false"},
+		},
+	}
+
+	for name, tc := range cases {
+		// The records of a case are cached, so every case has its own resolver.
+		resolver := &Resolver{
+			cache: map[string]*entry{
+				"db1.mongo.mercari.io": {ips: []net.IP{net.ParseIP("10.0.0.1")}},
+			},
+		}
+		lookupSRV = func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+			return "", tc.srvs, nil
+		}
+		lookupTXT = func(ctx context.Context, name string) ([]string, error) {
+			return tc.txts, nil
+		}
+
+		if _, err := resolver.MongoSeedList(context.Background(), tc.host); err == nil {
+			t.Errorf("%s: expect to be failed", name)
+		}
+	}
+}
+
+func TestMongoSeedListNoTXT(t *testing.T) {
+	origSRV, origTXT := lookupSRV, lookupTXT
+	defer func() {
+		lookupSRV, lookupTXT = origSRV, origTXT
+	}()
+
+	lookupSRV = func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+		return "", []*net.SRV{{Target: "db1.mongo.mercari.io.", Port: 27017}}, nil
+	}
+	var txtLookups int
+	lookupTXT = func(ctx context.Context, name string) ([]string, error) {
+		txtLookups++
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+
+	resolver := &Resolver{
+		cache: map[string]*entry{
+			"db1.mongo.mercari.io": {ips: []net.IP{net.ParseIP("10.0.0.1")}},
+		},
+	}
+	for i := 0; i < 2; i++ {
+		seeds, err := resolver.MongoSeedList(context.Background(), "cluster.mongo.mercari.io")
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if want := "tls=true"; seeds.Options.Encode() != want {
+			t.Fatalf("want %s, got %s", want, seeds.Options.Encode())
+		}
+	}
+	if txtLookups != 1 {
+		t.Fatalf("expect the absence of TXT records to be cached, got %d lookups", txtLookups)
+	}
+}
+
+func TestMongoSeedListNameserver(t *testing.T) {
+	origSRV, origTXT := lookupSRV, lookupTXT
+	defer func() {
+		lookupSRV, lookupTXT = origSRV, origTXT
+	}()
+	lookupSRV = func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+		t.Fatalf("expect the nameserver to be used rather than the system resolver")
+		return "", nil, nil
+	}
+	lookupTXT = func(ctx context.Context, name string) ([]string, error) {
+		t.Fatalf("expect the nameserver to be used rather than the system resolver")
+		return nil, nil
+	}
+
+	addr := testNameserver(t, func(q *dnsMessage, tcp bool) *dnsMessage {
+		rr := dnsRR{name: q.questions[0].name, typ: q.questions[0].typ, class: classINET, ttl: 60}
+		switch q.questions[0].typ {
+		case typeSRV:
+			rr.data, _ = appendName([]byte{0, 0, 0, 0, 0x69, 0x89}, "db1.mongo.mercari.io.") // port 27017
+		case typeTXT:
+			rr.data = append([]byte{10}, "replicaSet"...)
+			rr.data = append(append(rr.data, 4), "=rs0"...)
+		default:
+			return answerA(q, net.ParseIP("10.0.0.1"))
+		}
+		return &dnsMessage{answers: []dnsRR{rr}}
+	})
+
+	resolver, err := New(testFreq, testDefaultLookupTimeout, WithNameserver(addr))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resolver.Stop()
+
+	seeds, err := resolver.MongoSeedList(context.Background(), "cluster.mongo.mercari.io")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	want := "mongodb://db1.mongo.mercari.io:27017/?replicaSet=rs0&tls=true"
+	if got := seeds.URI(); got != want {
+		t.Fatalf("want %s, got %s", want, got)
+	}
+}
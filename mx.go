@@ -0,0 +1,97 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strings"
+)
+
+// lookupMX is a wrapper of systemResolver.LookupMX.
+// This is used to replace lookup function when test.
+var lookupMX = func(ctx context.Context, name string) ([]*net.MX, error) {
+	return systemResolver.LookupMX(ctx, name)
+}
+
+// FetchMX fetches the MX records of the domain from the cache. If they are not in
+// the cache, then it looks them up by the nameserver of the resolver, if any, and
+// caches them. Cached MX records are refreshed with the IP lists, and removed
+// with the domain by Remove.
+func (r *Resolver) FetchMX(ctx context.Context, domain string) ([]*net.MX, error) {
+	rs, err := r.fetchRecords(ctx, recordKey{typ: typeMX, name: normalizeHost(domain)})
+	if err != nil {
+		return nil, err
+	}
+	return rs.mx, nil
+}
+
+// errNullMX is returned when the domain declares that it does not accept mail.
+var errNullMX = errors.New("dnscache: domain does not accept mail (null MX)")
+
+// DialMX dials the mail exchangers of the domain in MX preference order, randomized
+// among the same preference, and returns the first connected `net.Conn` with the
+// host of the MX it is connected to, which is needed for the SMTP client, e.g. as
+// the TLS server name. Both MX records and the IPs of the exchangers are fetched
+// from the cache. If the domain has no MX records, the domain itself is dialed as
+// the implicit MX (RFC 5321, section 5.1). If no baseDialFunc is given, it sets
+// default dial function.
+func DialMX(ctx context.Context, resolver *Resolver, domain, port string, baseDialFunc dialFunc) (net.Conn, string, error) {
+	if baseDialFunc == nil {
+		baseDialFunc = defaultDialFunc()
+	}
+
+	ctxLookup, cancelF := context.WithTimeout(ctx, resolver.lookupTimeout)
+	defer cancelF()
+
+	mxs, err := resolver.FetchMX(ctxLookup, domain)
+	var dnsErr *net.DNSError
+	switch {
+	case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+		mxs = []*net.MX{{Host: domain}}
+	case err != nil:
+		return nil, "", err
+	}
+
+	var firstErr error
+	for _, mx := range orderMX(mxs) {
+		host := strings.TrimSuffix(mx.Host, ".")
+		if host == "" {
+			// A single MX of "." is a null MX (RFC 7505).
+			if len(mxs) == 1 {
+				return nil, "", errNullMX
+			}
+			continue
+		}
+
+		ips, err := resolver.Fetch(ctxLookup, host)
+		if err == nil {
+			var conn net.Conn
+			conn, err = dialIPs(ctx, resolver, baseDialFunc, "tcp", ips, port)
+			if err == nil {
+				return conn, host, nil
+			}
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	if firstErr == nil {
+		firstErr = &net.DNSError{Err: "no such host", Name: domain, IsNotFound: true}
+	}
+	return nil, "", firstErr
+}
+
+// orderMX returns a copy of the MX records sorted by preference and shuffled among
+// the same preference, so that cached records are spread like fresh ones.
+func orderMX(mxs []*net.MX) []*net.MX {
+	ordered := make([]*net.MX, len(mxs))
+	for i, randomIndex := range randPerm(len(mxs)) {
+		ordered[i] = mxs[randomIndex]
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Pref < ordered[j].Pref
+	})
+	return ordered
+}
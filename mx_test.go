@@ -0,0 +1,224 @@
+package dnscache
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDialMX(t *testing.T) {
+	origMX := lookupMX
+	defer func() {
+		lookupMX = origMX
+	}()
+
+	var lookups int
+	lookupMX = func(ctx context.Context, name string) ([]*net.MX, error) {
+		lookups++
+		return []*net.MX{
+			{Host: "mx2.mercari.io.", Pref: 20},
+			{Host: "mx1.mercari.io.", Pref: 10},
+		}, nil
+	}
+
+	resolver := &Resolver{
+		cache: map[string]*entry{
+			"mx1.mercari.io": {ips: []net.IP{net.ParseIP("10.0.0.1")}},
+			"mx2.mercari.io": {ips: []net.IP{net.ParseIP("10.0.0.2")}},
+		},
+	}
+
+	var dialed []string
+	dialF := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		if addr == "10.0.0.1:25" {
+			return nil, errors.New("connection refused")
+		}
+		return nil, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		dialed = nil
+		_, host, err := DialMX(context.Background(), resolver, "mercari.io", "25", dialF)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if host != "mx2.mercari.io" {
+			t.Fatalf("want mx2.mercari.io, got %s", host)
+		}
+		if want := []string{"10.0.0.1:25", "10.0.0.2:25"}; !reflect.DeepEqual(want, dialed) {
+			t.Fatalf("want %v, got %v", want, dialed)
+		}
+	}
+
+	if lookups != 1 {
+		t.Fatalf("expect MX records to be cached, got %d lookups", lookups)
+	}
+}
+
+func TestDialMXImplicit(t *testing.T) {
+	origMX := lookupMX
+	defer func() {
+		lookupMX = origMX
+	}()
+	lookupMX = func(ctx context.Context, name string) ([]*net.MX, error) {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+
+	resolver := &Resolver{
+		cache: map[string]*entry{
+			"mercari.io": {ips: []net.IP{net.ParseIP("10.0.0.1")}},
+		},
+	}
+
+	_, host, err := DialMX(context.Background(), resolver, "mercari.io", "25", func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, nil
+	})
+	if err != nil || host != "mercari.io" {
+		t.Fatalf("expect the domain to be dialed as implicit MX, got %s, err: %v", host, err)
+	}
+}
+
+func TestDialMXNull(t *testing.T) {
+	origMX := lookupMX
+	defer func() {
+		lookupMX = origMX
+	}()
+	lookupMX = func(ctx context.Context, name string) ([]*net.MX, error) {
+		return []*net.MX{{Host: ".", Pref: 0}}, nil
+	}
+
+	if _, _, err := DialMX(context.Background(), &Resolver{}, "mercari.io", "25", nil); err != errNullMX {
+		t.Fatalf("want %v, got %v", errNullMX, err)
+	}
+}
+
+func TestFetchMXRefresh(t *testing.T) {
+	origMX := lookupMX
+	defer func() {
+		lookupMX = origMX
+	}()
+
+	failing := true
+	lookups := 0
+	lookupMX = func(ctx context.Context, name string) ([]*net.MX, error) {
+		lookups++
+		if lookups > 1 && failing {
+			return nil, errors.New("lookup failed")
+		}
+		return []*net.MX{{Host: "mx1.mercari.io.", Pref: 10}}, nil
+	}
+
+	clock := newFakeClock()
+	r := &Resolver{
+		cache:                map[string]*entry{},
+		defaultLookupTimeout: time.Second,
+		clock:                clock,
+		logger:               slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	WithRefreshBackoff(time.Second, 4*time.Second).apply(r)
+
+	if _, err := r.FetchMX(context.Background(), "mercari.io"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cases := []struct {
+		after time.Duration
+		want  int
+	}{
+		{0, 2},                      // fails and backs off 1s
+		{500 * time.Millisecond, 2}, // skipped
+		{500 * time.Millisecond, 3}, // fails and backs off 2s
+		{time.Second, 3},            // skipped
+	}
+	for i, tc := range cases {
+		before := lookups
+		clock.Advance(tc.after)
+		summary := r.refresh(context.Background(), true)
+		if lookups != tc.want {
+			t.Fatalf("#%d: got %d lookups; want %d", i, lookups, tc.want)
+		}
+		if summary.Failures != lookups-before {
+			t.Fatalf("#%d: got %d failures; want %d", i, summary.Failures, lookups-before)
+		}
+	}
+
+	// The cached records are kept while the refresh fails.
+	mxs, err := r.FetchMX(context.Background(), "mercari.io")
+	if err != nil || len(mxs) != 1 {
+		t.Fatalf("expect the cached MX records, got %v, err: %v", mxs, err)
+	}
+
+	// Remove drops them with the domain.
+	r.Remove("mercari.io")
+	failing = false
+	if _, err := r.FetchMX(context.Background(), "mercari.io"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if lookups != 4 {
+		t.Fatalf("expect the MX records to be removed, got %d lookups", lookups)
+	}
+}
+
+func TestFetchMXBounded(t *testing.T) {
+	origMX := lookupMX
+	defer func() {
+		lookupMX = origMX
+	}()
+	lookupMX = func(ctx context.Context, name string) ([]*net.MX, error) {
+		return []*net.MX{{Host: "mx1." + name, Pref: 10}}, nil
+	}
+
+	r := &Resolver{}
+	for i := 0; i < maxRecordSets+10; i++ {
+		if _, err := r.FetchMX(context.Background(), fmt.Sprintf("%d.mercari.io", i)); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	if got := len(r.records); got != maxRecordSets {
+		t.Fatalf("got %d record sets; want %d", got, maxRecordSets)
+	}
+}
+
+func TestFetchMXNameserver(t *testing.T) {
+	origMX := lookupMX
+	defer func() {
+		lookupMX = origMX
+	}()
+	lookupMX = func(ctx context.Context, name string) ([]*net.MX, error) {
+		t.Fatalf("expect the nameserver to be used rather than the system resolver")
+		return nil, nil
+	}
+
+	addr := testNameserver(t, func(q *dnsMessage, tcp bool) *dnsMessage {
+		if q.questions[0].typ != typeMX {
+			return &dnsMessage{}
+		}
+		data := binary.BigEndian.AppendUint16(nil, 10)
+		data, _ = appendName(data, "mx1.mercari.io.")
+		return &dnsMessage{answers: []dnsRR{
+			{name: q.questions[0].name, typ: typeMX, class: classINET, ttl: 60, data: data},
+		}}
+	})
+
+	resolver, err := New(testFreq, testDefaultLookupTimeout, WithNameserver(addr))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resolver.Stop()
+
+	mxs, err := resolver.FetchMX(context.Background(), "mercari.io")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if want := []*net.MX{{Host: "mx1.mercari.io.", Pref: 10}}; !reflect.DeepEqual(want, mxs) {
+		t.Fatalf("want %v, got %v", want, mxs)
+	}
+}
@@ -0,0 +1,13 @@
+module go.mercari.io/go-dnscache/mysqldialer
+
+go 1.21
+
+require (
+	github.com/go-sql-driver/mysql v1.7.1
+	go.mercari.io/go-dnscache v0.2.0
+)
+
+// The parent module is used from this repository until go-dnscache v0.2.0,
+// which introduces the APIs used by this module, is released. Drop this replace
+// directive when releasing this module.
+replace go.mercari.io/go-dnscache => ../
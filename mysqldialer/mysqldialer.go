@@ -0,0 +1,30 @@
+// Package mysqldialer registers a dialer backed by go-dnscache with
+// go-sql-driver/mysql, so that database connections use the cached IPs, which are
+// refreshed in background, of endpoints like RDS and Cloud SQL whose IPs rotate.
+package mysqldialer // import "go.mercari.io/go-dnscache/mysqldialer"
+
+import (
+	"context"
+	"net"
+
+	"github.com/go-sql-driver/mysql"
+	dnscache "go.mercari.io/go-dnscache"
+)
+
+// Network is the network name registered by Register when none is given.
+const Network = "dnscache"
+
+// Register registers a dial function which dials the cached IPs of the resolver
+// by `dnscache.DialFunc` as the given network of the MySQL driver. Use the network
+// in the DSN to make connections use it, e.g. `user:password@dnscache(db.example.com:3306)/dbname`.
+// If network is empty, Network is used. The given options are passed to DialFunc.
+func Register(network string, resolver *dnscache.Resolver, options ...dnscache.DialOption) {
+	if network == "" {
+		network = Network
+	}
+
+	dial := dnscache.DialFunc(resolver, nil, options...)
+	mysql.RegisterDialContext(network, func(ctx context.Context, addr string) (net.Conn, error) {
+		return dial(ctx, "tcp", addr)
+	})
+}
@@ -0,0 +1,57 @@
+package mysqldialer
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"testing"
+	"time"
+
+	dnscache "go.mercari.io/go-dnscache"
+)
+
+func TestRegister(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer ln.Close()
+
+	accepted := make(chan struct{})
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		conn.Close()
+		close(accepted)
+	}()
+
+	resolver, err := dnscache.New(time.Minute, time.Second, dnscache.WithStaticEntries(map[string][]net.IP{
+		"db.mercari.io": {net.ParseIP("127.0.0.1")},
+	}))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resolver.Stop()
+
+	Register("", resolver)
+
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	db, err := sql.Open("mysql", "user:password@"+Network+"(db.mercari.io:"+port+")/test?timeout=1s")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer db.Close()
+
+	// The handshake fails since the server is not MySQL, but it must be dialed.
+	ctx, cancelF := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancelF()
+	db.PingContext(ctx)
+
+	select {
+	case <-accepted:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("expect the cached IP to be dialed")
+	}
+}
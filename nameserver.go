@@ -0,0 +1,358 @@
+package dnscache
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// ednsOptionClientSubnet is the EDNS0 option code of Client Subnet (RFC 7871).
+const ednsOptionClientSubnet uint16 = 8
+
+// ednsFlagDO is the DNSSEC OK bit in the TTL field of an OPT record.
+const ednsFlagDO uint32 = 1 << 15
+
+var errResponseMismatch = errors.New("dnscache: DNS response does not match the query")
+
+// nameserver is a DNS client which talks the DNS wire protocol to a single server
+// directly instead of going through the system resolver.
+type nameserver struct {
+	addr string
+
+	// subnet is attached to queries as EDNS Client Subnet when valid.
+	subnet netip.Prefix
+
+	// dnssec requests DNSSEC records and checks the AD bit of answers.
+	dnssec dnssecMode
+
+	// wireFormat keeps the raw response messages in cache entries.
+	wireFormat bool
+}
+
+// newNameserver returns a client for the given server address. The port
+// defaults to 53 when it is omitted.
+func newNameserver(addr string) *nameserver {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "53")
+	}
+	return &nameserver{addr: addr}
+}
+
+// lookup looks up A and AAAA records of the given host concurrently. Only A or
+// AAAA records are looked up when network is "ip4" or "ip6" respectively.
+func (ns *nameserver) lookup(ctx context.Context, network, host string) (*entry, error) {
+	type result struct {
+		ips           []net.IP
+		authenticated bool
+		cname         string
+		raw           []byte
+		err           error
+	}
+
+	qtypes := networkTypes(network)
+	results := make(chan result, len(qtypes))
+	for _, qtype := range qtypes {
+		go func(qtype uint16) {
+			m, err := ns.query(ctx, host, qtype)
+			if err != nil {
+				results <- result{err: err}
+				return
+			}
+			results <- result{
+				ips:           answerIPs(m, qtype),
+				authenticated: m.flags&flagAD != 0,
+				cname:         canonicalName(m, fqdn(host)),
+				raw:           m.raw,
+			}
+		}(qtype)
+	}
+
+	var (
+		ips           []net.IP
+		authenticated = true
+		cname         string
+		messages      []Message
+		firstErr      error
+	)
+	for range qtypes {
+		res := <-results
+		if res.err != nil {
+			if firstErr == nil {
+				firstErr = res.err
+			}
+			continue
+		}
+		ips = append(ips, res.ips...)
+		authenticated = authenticated && res.authenticated
+		cname = res.cname
+		if ns.wireFormat {
+			messages = append(messages, Message{raw: bytes.Clone(res.raw)})
+		}
+	}
+
+	if len(ips) == 0 && firstErr != nil {
+		return nil, firstErr
+	}
+	// Otherwise the host has no records of the queried types (NODATA), which is
+	// an empty result handled by WithEmptyResults rather than an error.
+
+	e := &entry{ips: ips, cname: cname, messages: messages}
+	switch {
+	case ns.dnssec == dnssecOff:
+	case authenticated:
+		e.validation = ValidationSecure
+	case ns.dnssec == dnssecRequire:
+		return nil, ErrInsecure
+	default:
+		e.validation = ValidationInsecure
+	}
+	return e, nil
+}
+
+// query sends a query of the given type for host and returns a successful response.
+func (ns *nameserver) query(ctx context.Context, host string, qtype uint16) (*dnsMessage, error) {
+	q := ns.newQuery(host, qtype)
+	m, err := exchange(ctx, ns.addr, q)
+	if err != nil {
+		return nil, &net.DNSError{Err: err.Error(), Name: host, Server: ns.addr, IsTimeout: isTimeout(err), IsTemporary: true}
+	}
+
+	switch m.rcode() {
+	case rcodeSuccess:
+		return m, nil
+	case rcodeNXDomain:
+		return nil, &net.DNSError{Err: "no such host", Name: host, Server: ns.addr, IsNotFound: true}
+	case rcodeServFail:
+		return nil, &net.DNSError{Err: "server misbehaving", Name: host, Server: ns.addr, IsTemporary: true}
+	default:
+		return nil, &net.DNSError{Err: "server misbehaving", Name: host, Server: ns.addr}
+	}
+}
+
+// newQuery builds a recursive query message with an EDNS0 OPT record.
+func (ns *nameserver) newQuery(host string, qtype uint16) *dnsMessage {
+	opt := dnsRR{
+		name:  ".",
+		typ:   typeOPT,
+		class: maxUDPSize,
+	}
+	if ns.subnet.IsValid() {
+		opt.data = appendClientSubnet(opt.data, ns.subnet)
+	}
+
+	flags := flagRD
+	if ns.dnssec != dnssecOff {
+		// Set the DO bit and ask for the AD bit in the answer (RFC 6840, section 5.7).
+		opt.ttl = ednsFlagDO
+		flags |= flagAD
+	}
+
+	return &dnsMessage{
+		id:    newID(),
+		flags: flags,
+		questions: []dnsQuestion{
+			{name: fqdn(host), typ: qtype, class: classINET},
+		},
+		additionals: []dnsRR{opt},
+	}
+}
+
+// newID returns a random query ID. It is taken from crypto/rand, so that it can
+// not be guessed to spoof responses.
+func newID() uint16 {
+	var b [2]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("dnscache: failed to generate a query ID: " + err.Error())
+	}
+	return binary.BigEndian.Uint16(b[:])
+}
+
+// appendClientSubnet appends an EDNS Client Subnet option for the given prefix.
+func appendClientSubnet(b []byte, prefix netip.Prefix) []byte {
+	prefix = prefix.Masked()
+	addr := prefix.Addr()
+
+	family := uint16(1)
+	if addr.Is6() {
+		family = 2
+	}
+	bits := prefix.Bits()
+	raw := addr.AsSlice()[:(bits+7)/8]
+
+	b = binary.BigEndian.AppendUint16(b, ednsOptionClientSubnet)
+	b = binary.BigEndian.AppendUint16(b, uint16(4+len(raw)))
+	b = binary.BigEndian.AppendUint16(b, family)
+	b = append(b, byte(bits), 0)
+	return append(b, raw...)
+}
+
+// networkTypes returns the record types to look up for the given network.
+func networkTypes(network string) []uint16 {
+	switch network {
+	case "ip4":
+		return []uint16{typeA}
+	case "ip6":
+		return []uint16{typeAAAA}
+	default:
+		return []uint16{typeA, typeAAAA}
+	}
+}
+
+// canonicalName follows the CNAME chain of name in the answer section and
+// returns its final target.
+func canonicalName(m *dnsMessage, name string) string {
+	for i := 0; i < len(m.answers); i++ {
+		found := false
+		for _, rr := range m.answers {
+			if rr.typ == typeCNAME && strings.EqualFold(rr.name, name) {
+				if target, _, err := readName(rr.data, 0); err == nil {
+					name, found = target, true
+					break
+				}
+			}
+		}
+		if !found {
+			break
+		}
+	}
+	return name
+}
+
+// answerIPs returns the addresses of the given type in the answer section.
+func answerIPs(m *dnsMessage, qtype uint16) []net.IP {
+	var ips []net.IP
+	for _, rr := range m.answers {
+		// The top bit of the class is the cache-flush bit in multicast DNS.
+		if rr.typ != qtype || rr.class&^classCacheFlush != classINET {
+			continue
+		}
+		switch {
+		case rr.typ == typeA && len(rr.data) == net.IPv4len:
+			ips = append(ips, net.IPv4(rr.data[0], rr.data[1], rr.data[2], rr.data[3]))
+		case rr.typ == typeAAAA && len(rr.data) == net.IPv6len:
+			ips = append(ips, net.IP(append([]byte(nil), rr.data...)))
+		}
+	}
+	return ips
+}
+
+// exchange sends the query to the server over UDP and returns the response.
+// Truncated responses are retried over TCP.
+func exchange(ctx context.Context, server string, q *dnsMessage) (*dnsMessage, error) {
+	b, err := q.pack()
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := exchangeUDP(ctx, server, q, b)
+	if err != nil {
+		return nil, err
+	}
+	if m.flags&flagTC == 0 {
+		return m, nil
+	}
+	return exchangeTCP(ctx, server, q, b)
+}
+
+// isResponse reports whether m is the response to q, i.e. it has the ID and the
+// question of q.
+func isResponse(q, m *dnsMessage) bool {
+	if m.id != q.id || m.flags&flagQR == 0 || len(m.questions) != len(q.questions) {
+		return false
+	}
+	for i, question := range q.questions {
+		got := m.questions[i]
+		if !strings.EqualFold(got.name, question.name) || got.typ != question.typ || got.class != question.class {
+			return false
+		}
+	}
+	return true
+}
+
+func exchangeUDP(ctx context.Context, server string, q *dnsMessage, b []byte) (*dnsMessage, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	defer setDeadline(ctx, conn)()
+
+	if _, err := conn.Write(b); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 65535)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		m, err := parseMessage(buf[:n])
+		if err != nil || !isResponse(q, m) {
+			// Ignore unrelated or broken datagrams and wait for the response.
+			continue
+		}
+		return m, nil
+	}
+}
+
+func exchangeTCP(ctx context.Context, server string, q *dnsMessage, b []byte) (*dnsMessage, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	defer setDeadline(ctx, conn)()
+
+	if _, err := conn.Write(binary.BigEndian.AppendUint16(nil, uint16(len(b)))); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(b); err != nil {
+		return nil, err
+	}
+
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return nil, err
+	}
+
+	m, err := parseMessage(buf)
+	if err != nil {
+		return nil, err
+	}
+	if !isResponse(q, m) {
+		return nil, errResponseMismatch
+	}
+	return m, nil
+}
+
+// setDeadline applies the context deadline to the connection and interrupts
+// pending I/O when the context is cancelled. The returned function must be
+// called once the connection is no longer used.
+func setDeadline(ctx context.Context, conn net.Conn) (stop func() bool) {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	return context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Unix(1, 0))
+	})
+}
+
+// isTimeout reports whether err is a timeout error.
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,318 @@
+package dnscache
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+// testNameserver starts a DNS server on the loopback interface which answers UDP
+// and TCP queries by the given handler and returns its address.
+func testNameserver(t *testing.T, handler func(q *dnsMessage, tcp bool) *dnsMessage) string {
+	t.Helper()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	t.Cleanup(func() { pc.Close() })
+
+	l, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	respond := func(b []byte, tcp bool) []byte {
+		q, err := parseMessage(b)
+		if err != nil {
+			return nil
+		}
+		m := handler(q, tcp)
+		if m == nil {
+			return nil
+		}
+		m.id = q.id
+		m.flags |= flagQR
+		m.questions = q.questions
+		resp, err := m.pack()
+		if err != nil {
+			return nil
+		}
+		return resp
+	}
+
+	go func() {
+		buf := make([]byte, 65535)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if resp := respond(buf[:n], false); resp != nil {
+				pc.WriteTo(resp, addr)
+			}
+		}
+	}()
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				var length [2]byte
+				if _, err := io.ReadFull(conn, length[:]); err != nil {
+					return
+				}
+				b := make([]byte, binary.BigEndian.Uint16(length[:]))
+				if _, err := io.ReadFull(conn, b); err != nil {
+					return
+				}
+				if resp := respond(b, true); resp != nil {
+					conn.Write(binary.BigEndian.AppendUint16(nil, uint16(len(resp))))
+					conn.Write(resp)
+				}
+			}()
+		}
+	}()
+
+	return pc.LocalAddr().String()
+}
+
+// answerA returns an answer to q with an A or AAAA record for each of the given IPs
+// which is of the queried type.
+func answerA(q *dnsMessage, ips ...net.IP) *dnsMessage {
+	m := &dnsMessage{}
+	for _, ip := range ips {
+		rr := dnsRR{name: q.questions[0].name, class: classINET, ttl: 60}
+		if ip4 := ip.To4(); ip4 != nil {
+			rr.typ, rr.data = typeA, ip4
+		} else {
+			rr.typ, rr.data = typeAAAA, ip.To16()
+		}
+		if rr.typ == q.questions[0].typ {
+			m.answers = append(m.answers, rr)
+		}
+	}
+	return m
+}
+
+func TestNameserver(t *testing.T) {
+	want := []net.IP{
+		net.ParseIP("10.0.0.1"),
+		net.ParseIP("2001:db8::1"),
+	}
+	addr := testNameserver(t, func(q *dnsMessage, tcp bool) *dnsMessage {
+		return answerA(q, want...)
+	})
+
+	resolver, err := New(testFreq, testDefaultLookupTimeout, WithNameserver(addr))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resolver.Stop()
+
+	got, err := resolver.LookupIP(context.Background(), "api.mercari.io")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+	for _, ip := range want {
+		if !containsIP(got, ip) {
+			t.Fatalf("want %v in %v", ip, got)
+		}
+	}
+}
+
+func TestNameserverTruncated(t *testing.T) {
+	want := []net.IP{
+		net.ParseIP("10.0.0.1").To4(),
+	}
+	addr := testNameserver(t, func(q *dnsMessage, tcp bool) *dnsMessage {
+		if !tcp {
+			return &dnsMessage{flags: flagTC}
+		}
+		return answerA(q, want...)
+	})
+
+	ns := newNameserver(addr)
+	m, err := ns.query(context.Background(), "api.mercari.io", typeA)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if got := answerIPs(m, typeA); !reflect.DeepEqual(want, []net.IP{got[0].To4()}) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+}
+
+func TestNameserverNotFound(t *testing.T) {
+	addr := testNameserver(t, func(q *dnsMessage, tcp bool) *dnsMessage {
+		return &dnsMessage{flags: rcodeNXDomain}
+	})
+
+	_, err := newNameserver(addr).lookup(context.Background(), "ip", "nx.mercari.io")
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Fatalf("got error %v, want not found", err)
+	}
+}
+
+func TestClientSubnet(t *testing.T) {
+	subnets := make(chan []byte, 2)
+	addr := testNameserver(t, func(q *dnsMessage, tcp bool) *dnsMessage {
+		for _, rr := range q.additionals {
+			if rr.typ == typeOPT {
+				subnets <- rr.data
+			}
+		}
+		return answerA(q, net.ParseIP("10.0.0.1"))
+	})
+
+	prefix := netip.MustParsePrefix("203.0.113.77/24")
+	resolver, err := New(testFreq, testDefaultLookupTimeout, WithNameserver(addr), WithClientSubnet(prefix))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resolver.Stop()
+
+	if _, err := resolver.LookupIP(context.Background(), "cdn.mercari.io"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// option code 8, length 7, family 1, source prefix 24, scope 0, 203.0.113
+	want := []byte{0, 8, 0, 7, 0, 1, 24, 0, 203, 0, 113}
+	if got := <-subnets; !reflect.DeepEqual(want, got) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+}
+
+func TestClientSubnetWithoutNameserver(t *testing.T) {
+	if _, err := New(testFreq, testDefaultLookupTimeout, WithClientSubnet(netip.MustParsePrefix("203.0.113.0/24"))); err == nil {
+		t.Fatalf("expect to be failed")
+	}
+}
+
+func TestNameserverNetwork(t *testing.T) {
+	qtypes := make(chan uint16, 2)
+	addr := testNameserver(t, func(q *dnsMessage, tcp bool) *dnsMessage {
+		qtypes <- q.questions[0].typ
+		return answerA(q, net.ParseIP("10.0.0.1"), net.ParseIP("2001:db8::1"))
+	})
+
+	got, err := newNameserver(addr).lookup(context.Background(), "ip6", "api.mercari.io")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if want := []net.IP{net.ParseIP("2001:db8::1")}; !reflect.DeepEqual(want, got.ips) {
+		t.Fatalf("want %v, got %v", want, got.ips)
+	}
+	if got, want := <-qtypes, typeAAAA; got != want || len(qtypes) != 0 {
+		t.Fatalf("got query type %d, want only %d", got, want)
+	}
+}
+
+func TestNameserverNoData(t *testing.T) {
+	addr := testNameserver(t, func(q *dnsMessage, tcp bool) *dnsMessage {
+		return &dnsMessage{}
+	})
+
+	e, err := newNameserver(addr).lookup(context.Background(), "ip", "nodata.mercari.io")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(e.ips) != 0 {
+		t.Fatalf("want no IPs, got %v", e.ips)
+	}
+
+	// The empty result is then handled by WithEmptyResults.
+	resolver, err := New(testFreq, testDefaultLookupTimeout, WithNameserver(addr), WithEmptyResults(EmptyResultError))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resolver.Stop()
+	_, err = resolver.LookupIP(context.Background(), "nodata.mercari.io")
+	if dnsErr := (*net.DNSError)(nil); !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Fatalf("got error %v, want not found", err)
+	}
+}
+
+func TestNameserverQuestionMismatch(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer pc.Close()
+
+	go func() {
+		buf := make([]byte, 65535)
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		q, err := parseMessage(buf[:n])
+		if err != nil {
+			return
+		}
+
+		// A spoofed response with the ID of the query but another question comes
+		// first, followed by the real one.
+		responses := []struct {
+			name string
+			ip   string
+		}{
+			{"evil.mercari.io.", "10.6.6.6"},
+			{q.questions[0].name, "10.0.0.1"},
+		}
+		for _, res := range responses {
+			m := answerA(q, net.ParseIP(res.ip))
+			m.id = q.id
+			m.flags |= flagQR
+			m.questions = []dnsQuestion{{name: res.name, typ: q.questions[0].typ, class: classINET}}
+			resp, err := m.pack()
+			if err != nil {
+				return
+			}
+			pc.WriteTo(resp, addr)
+		}
+	}()
+
+	ns := newNameserver(pc.LocalAddr().String())
+	m, err := ns.query(context.Background(), "api.mercari.io", typeA)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if got, want := answerIPs(m, typeA), []net.IP{net.ParseIP("10.0.0.1")}; !reflect.DeepEqual(want, got) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+}
+
+func TestIsResponse(t *testing.T) {
+	q := &dnsMessage{id: 1, questions: []dnsQuestion{{name: "api.mercari.io.", typ: typeA, class: classINET}}}
+	cases := []struct {
+		m    *dnsMessage
+		want bool
+	}{
+		{&dnsMessage{id: 1, flags: flagQR, questions: []dnsQuestion{{name: "API.mercari.io.", typ: typeA, class: classINET}}}, true},
+		{&dnsMessage{id: 2, flags: flagQR, questions: q.questions}, false},
+		{&dnsMessage{id: 1, questions: q.questions}, false},
+		{&dnsMessage{id: 1, flags: flagQR}, false},
+		{&dnsMessage{id: 1, flags: flagQR, questions: []dnsQuestion{{name: "evil.mercari.io.", typ: typeA, class: classINET}}}, false},
+		{&dnsMessage{id: 1, flags: flagQR, questions: []dnsQuestion{{name: "api.mercari.io.", typ: typeAAAA, class: classINET}}}, false},
+		{&dnsMessage{id: 1, flags: flagQR, questions: []dnsQuestion{{name: "api.mercari.io.", typ: typeA, class: 3}}}, false},
+	}
+	for i, tc := range cases {
+		if got := isResponse(q, tc.m); got != tc.want {
+			t.Fatalf("#%d: got %v, want %v", i, got, tc.want)
+		}
+	}
+}
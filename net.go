@@ -4,6 +4,8 @@ import (
 	"context"
 	"math/rand"
 	"net"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -11,54 +13,252 @@ var randPerm = func(n int) []int {
 	return rand.Perm(n)
 }
 
+// lookupSRV is a wrapper of systemResolver.LookupSRV, which sorts records by
+// priority and randomizes them by weight within a priority.
+// This is used to replace lookup function when test.
+var lookupSRV = func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	return systemResolver.LookupSRV(ctx, service, proto, name)
+}
+
 type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
 
 // DialFunc is a helper function which returns `net.DialContext` function.
 // It randomly fetches an IP from the DNS cache and dials it by the given dial
 // function. It dials one by one and returns first connected `net.Conn`.
 // If it fails to dial all IPs from cache it returns first error. If no baseDialFunc
-// is given, it sets default dial function.
+// is given, it sets default dial function. The IP to dial first can be picked by
+// a custom Selector given with WithSelector.
 //
-// You can use returned dial function for `http.Transport.DialContext`.
+// Both TCP and UDP networks are supported, so the dial function can also be used
+// by e.g. statsd or syslog clients.
+//
+// You can use returned dial function for `http.Transport.DialContext`. When the
+// context carries an `httptrace.ClientTrace`, its DNSStart and DNSDone are called
+// around fetching the IPs. Whether the IPs were served from the cache is reported
+// to the Tracer given by WithTracer, whose Fetch span is started with the same
+// context, so that it can be told apart per request.
 //
 // In this function, it uses functions from `rand` package. To make it really random,
 // you MUST call `rand.Seed` and change the value from the default in your application
-func DialFunc(resolver *Resolver, baseDialFunc dialFunc) dialFunc {
+func DialFunc(resolver *Resolver, baseDialFunc dialFunc, options ...DialOption) dialFunc {
+	return NewDialer(resolver, baseDialFunc, options...).DialContext
+}
+
+// DialService resolves the SRV records of `_service._proto.name` and dials their
+// targets in the order of SRV priority, randomized by weight within the same priority
+// (RFC 2782), which lets operators drain or shift load across targets via DNS.
+// The order is randomized again for every call. The IPs of the targets are fetched
+// from the DNS cache and dialed one by one like DialFunc does. It returns the first
+// connected `net.Conn` or the first error. If no baseDialFunc is given, it sets
+// default dial function.
+//
+// The SRV records are cached and refreshed like the IPs, and looked up by the
+// nameserver of the resolver, if any. They are removed from the cache by Remove
+// with the SRV name, e.g. `_grpc._tcp.mercari.io`. When service and proto are
+// empty, name is looked up directly.
+func DialService(ctx context.Context, resolver *Resolver, service, proto, name string, baseDialFunc dialFunc) (net.Conn, error) {
 	if baseDialFunc == nil {
-		// This is same as which `http.DefaultTransport` uses.
-		baseDialFunc = (&net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 30 * time.Second,
-			DualStack: true,
-		}).DialContext
+		baseDialFunc = defaultDialFunc()
+	}
+
+	network := proto
+	if network == "" {
+		network = "tcp"
+	}
+
+	ctxLookup, cancelF := context.WithTimeout(ctx, resolver.lookupTimeout)
+	defer cancelF()
+	srvs, err := resolver.fetchSRV(ctxLookup, service, proto, name)
+	if err != nil {
+		return nil, err
 	}
-	return func(ctx context.Context, network, addr string) (net.Conn, error) {
-		h, p, err := net.SplitHostPort(addr)
-		if err != nil {
-			return nil, err
-		}
 
-		// Fetch DNS result from cache.
-		//
-		// ctxLookup is only used for cancelling DNS Lookup.
-		ctxLookup, cancelF := context.WithTimeout(ctx, resolver.lookupTimeout)
-		defer cancelF()
-		ips, err := resolver.Fetch(ctxLookup, h)
-		if err != nil {
-			return nil, err
+	var firstErr error
+	for _, srv := range orderSRV(srvs) {
+		// A target of "." means that the service is decidedly not available.
+		target := strings.TrimSuffix(srv.Target, ".")
+		if target == "" {
+			continue
 		}
 
-		var firstErr error
-		for _, randomIndex := range randPerm(len(ips)) {
-			conn, err := baseDialFunc(ctx, "tcp", net.JoinHostPort(ips[randomIndex].String(), p))
+		ips, err := resolver.Fetch(ctxLookup, target)
+		if err == nil {
+			var conn net.Conn
+			conn, err = dialIPs(ctx, resolver, baseDialFunc, network, ips, strconv.Itoa(int(srv.Port)))
 			if err == nil {
 				return conn, nil
 			}
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	if firstErr == nil {
+		firstErr = &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return nil, firstErr
+}
+
+// defaultDialFunc returns the dial function used when no baseDialFunc is given.
+func defaultDialFunc() dialFunc {
+	// This is same as which `http.DefaultTransport` uses.
+	return (&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		DualStack: true,
+	}).DialContext
+}
+
+// dialIPs dials the given IPs in random order, keeping the preferred address
+// family first, and returns the first connected `net.Conn` or the first error.
+func dialIPs(ctx context.Context, resolver *Resolver, baseDialFunc dialFunc, network string, ips []net.IP, port string) (net.Conn, error) {
+	return dialIPsInOrder(ctx, baseDialFunc, network, shuffleIPs(resolver, ips), port)
+}
+
+// shuffleIPs returns a copy of ips in random order, keeping the preferred
+// address family first.
+func shuffleIPs(resolver *Resolver, ips []net.IP) []net.IP {
+	shuffled := make([]net.IP, len(ips))
+	for i, randomIndex := range randPerm(len(ips)) {
+		shuffled[i] = ips[randomIndex]
+	}
+	return resolver.ipVersion.apply(shuffled)
+}
+
+// dialIPsInOrder dials the given IPs one by one and returns the first connected
+// `net.Conn` or the first error. When ctx has a deadline, each attempt gets a
+// share of the remaining time so that an unresponsive IP does not use up the
+// whole deadline before the remaining IPs are tried.
+func dialIPsInOrder(ctx context.Context, baseDialFunc dialFunc, network string, ips []net.IP, port string) (net.Conn, error) {
+	var firstErr error
+	for i, ip := range ips {
+		if err := ctx.Err(); err != nil {
 			if firstErr == nil {
 				firstErr = err
 			}
+			break
 		}
 
-		return nil, firstErr
+		dialCtx, cancelF := ctx, context.CancelFunc(func() {})
+		if deadline, ok := ctx.Deadline(); ok {
+			dialCtx, cancelF = context.WithDeadline(ctx, partialDeadline(time.Now(), deadline, len(ips)-i))
+		}
+		conn, err := baseDialFunc(dialCtx, network, net.JoinHostPort(ip.String(), port))
+		cancelF()
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return nil, firstErr
+}
+
+// minDialTimeout is the minimum time given to a single dial attempt when the
+// deadline is shared among several IPs.
+const minDialTimeout = 2 * time.Second
+
+// partialDeadline returns the deadline of a dial attempt when the time until
+// deadline is shared among addrsRemaining IPs, which is same as net.Dialer does.
+func partialDeadline(now, deadline time.Time, addrsRemaining int) time.Time {
+	timeRemaining := deadline.Sub(now)
+	if addrsRemaining <= 1 || timeRemaining <= minDialTimeout {
+		return deadline
+	}
+
+	timeout := timeRemaining / time.Duration(addrsRemaining)
+	if timeout < minDialTimeout {
+		timeout = minDialTimeout
+	}
+	return now.Add(timeout)
+}
+
+// partitionFamily divides ips into the IPs of the primary family and the others,
+// keeping their order. IPv6 is the primary family as RFC 8305 recommends unless
+// preferIPv4 is true. The other family is primary when ips have no IP of it.
+func partitionFamily(ips []net.IP, preferIPv4 bool) (primaries, fallbacks []net.IP) {
+	for _, ip := range ips {
+		if (ip.To4() != nil) == preferIPv4 {
+			primaries = append(primaries, ip)
+		} else {
+			fallbacks = append(fallbacks, ip)
+		}
+	}
+	if len(primaries) == 0 {
+		return fallbacks, nil
+	}
+	return primaries, fallbacks
+}
+
+// dialParallel races dialing primaries and fallbacks one by one, starting the
+// fallbacks after delay, waited by after, or when the primaries fail. It returns
+// the first connected `net.Conn`, closing the other one, or the error of the
+// primaries.
+func dialParallel(ctx context.Context, baseDialFunc dialFunc, network string, primaries, fallbacks []net.IP, port string, delay time.Duration, after func(time.Duration) <-chan time.Time) (net.Conn, error) {
+	if len(fallbacks) == 0 {
+		return dialIPsInOrder(ctx, baseDialFunc, network, primaries, port)
+	}
+
+	type dialResult struct {
+		conn    net.Conn
+		err     error
+		primary bool
+		done    bool
+	}
+
+	ctx, cancelF := context.WithCancel(ctx)
+	defer cancelF()
+
+	returned := make(chan struct{})
+	defer close(returned)
+
+	results := make(chan dialResult)
+	startRacer := func(primary bool) {
+		ips := primaries
+		if !primary {
+			ips = fallbacks
+		}
+		conn, err := dialIPsInOrder(ctx, baseDialFunc, network, ips, port)
+		select {
+		case results <- dialResult{conn: conn, err: err, primary: primary, done: true}:
+		case <-returned:
+			if conn != nil {
+				conn.Close()
+			}
+		}
+	}
+
+	go startRacer(true)
+
+	// fallbackC is set to nil once the fallbacks are started.
+	fallbackC := after(delay)
+
+	var primary, fallback dialResult
+	for {
+		select {
+		case <-fallbackC:
+			fallbackC = nil
+			go startRacer(false)
+		case res := <-results:
+			if res.err == nil {
+				return res.conn, nil
+			}
+			if res.primary {
+				primary = res
+			} else {
+				fallback = res
+			}
+			if primary.done && fallback.done {
+				return nil, primary.err
+			}
+			if res.primary && fallbackC != nil {
+				// Start the fallbacks right away since the primaries failed.
+				fallbackC = nil
+				go startRacer(false)
+			}
+		}
 	}
 }
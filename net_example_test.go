@@ -23,3 +23,30 @@ func ExampleDialFunc() {
 	// Do what you want.
 	_ = client
 }
+
+func ExampleNewHTTPClient() {
+	resolver, _ := New(3*time.Second, 5*time.Second)
+
+	// The client dials the cached IPs and skips the ones which keep failing.
+	client := NewHTTPClient(resolver, WithRoundRobin(), WithCircuitBreaker(3, 30*time.Second))
+
+	// Do what you want.
+	_ = client
+}
+
+func ExampleNew() {
+	// All configuration is given to New as functional options.
+	resolver, err := New(3*time.Second, 5*time.Second,
+		WithRefreshTimeout(time.Second),
+		WithRetry(3, 100*time.Millisecond, 0.2),
+		WithHostsFile(""),
+	)
+	if err != nil {
+		// The options are invalid.
+		return
+	}
+	defer resolver.Stop()
+
+	// Do what you want.
+	_ = resolver
+}
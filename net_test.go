@@ -6,18 +6,20 @@ import (
 	"fmt"
 	"math/rand"
 	"net"
+	"reflect"
+	"sync"
 	"testing"
 	"time"
 )
 
 func TestDialFunc(t *testing.T) {
 	resolver := &Resolver{
-		cache: map[string][]net.IP{
-			"deeeet.com": {
+		cache: map[string]*entry{
+			"deeeet.com": {ips: []net.IP{
 				net.IP("127.0.0.1"),
 				net.IP("127.0.0.2"),
 				net.IP("127.0.0.3"),
-			},
+			}},
 		},
 	}
 
@@ -83,12 +85,12 @@ func TestDialFuncRand(t *testing.T) {
 	}()
 
 	resolver := &Resolver{
-		cache: map[string][]net.IP{
-			"deeeet.com": {
+		cache: map[string]*entry{
+			"deeeet.com": {ips: []net.IP{
 				net.IP("127.0.0.1"),
 				net.IP("127.0.0.2"),
 				net.IP("127.0.0.3"),
-			},
+			}},
 		},
 	}
 
@@ -125,7 +127,7 @@ func TestDialFuncError2(t *testing.T) {
 		lookupIP = originalFunc
 	}()
 
-	lookupIP = func(ctx context.Context, host string) ([]net.IP, error) {
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
 		return nil, fmt.Errorf("err")
 	}
 
@@ -136,12 +138,12 @@ func TestDialFuncError2(t *testing.T) {
 
 func TestDialFuncError3(t *testing.T) {
 	resolver := &Resolver{
-		cache: map[string][]net.IP{
-			"tcnksm.io": {
+		cache: map[string]*entry{
+			"tcnksm.io": {ips: []net.IP{
 				net.IP("1.1.1.1"),
 				net.IP("2.2.2.2"),
 				net.IP("3.3.3.3"),
-			},
+			}},
 		},
 	}
 
@@ -172,3 +174,498 @@ func TestDialFuncError3(t *testing.T) {
 		t.Fatalf("got error %v, want %v", got, want)
 	}
 }
+
+func TestDialService(t *testing.T) {
+	origSRV := lookupSRV
+	defer func() {
+		lookupSRV = origSRV
+	}()
+	var lookups int
+	lookupSRV = func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+		lookups++
+		if got, want := service+proto+name, "_grpc._tcp.mercari.io"; got != want {
+			t.Fatalf("got SRV name %q, want %q", got, want)
+		}
+		return "", []*net.SRV{
+			{Target: "down.mercari.io.", Port: 8080, Priority: 10, Weight: 1},
+			{Target: "up.mercari.io.", Port: 9090, Priority: 20, Weight: 1},
+		}, nil
+	}
+
+	resolver := &Resolver{
+		cache: map[string]*entry{
+			"down.mercari.io": {ips: []net.IP{net.ParseIP("10.0.0.1")}},
+			"up.mercari.io":   {ips: []net.IP{net.ParseIP("10.0.0.2")}},
+		},
+	}
+
+	var dialed []string
+	dialF := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = append(dialed, network+"/"+addr)
+		if addr == "10.0.0.1:8080" {
+			return nil, errors.New("connection refused")
+		}
+		return nil, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		dialed = nil
+		if _, err := DialService(context.Background(), resolver, "grpc", "tcp", "mercari.io", dialF); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if want := []string{"tcp/10.0.0.1:8080", "tcp/10.0.0.2:9090"}; !reflect.DeepEqual(want, dialed) {
+			t.Fatalf("want %v, got %v", want, dialed)
+		}
+	}
+	if lookups != 1 {
+		t.Fatalf("expect SRV records to be cached, got %d lookups", lookups)
+	}
+}
+
+func TestDialServiceNameserver(t *testing.T) {
+	origSRV := lookupSRV
+	defer func() {
+		lookupSRV = origSRV
+	}()
+	lookupSRV = func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+		t.Fatalf("expect the nameserver to be used rather than the system resolver")
+		return "", nil, nil
+	}
+
+	addr := testNameserver(t, func(q *dnsMessage, tcp bool) *dnsMessage {
+		if q.questions[0].typ != typeSRV {
+			return &dnsMessage{}
+		}
+		data := []byte{0, 10, 0, 1, 0x23, 0x82} // priority 10, weight 1, port 9090
+		data, _ = appendName(data, "up.mercari.io.")
+		return &dnsMessage{answers: []dnsRR{
+			{name: q.questions[0].name, typ: typeSRV, class: classINET, ttl: 60, data: data},
+		}}
+	})
+
+	resolver, err := New(testFreq, testDefaultLookupTimeout, WithNameserver(addr), WithStaticEntries(map[string][]net.IP{
+		"up.mercari.io": {net.ParseIP("10.0.0.2")},
+	}))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resolver.Stop()
+
+	var dialed string
+	dialF := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = addr
+		return nil, nil
+	}
+	if _, err := DialService(context.Background(), resolver, "grpc", "tcp", "mercari.io", dialF); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if want := "10.0.0.2:9090"; dialed != want {
+		t.Fatalf("want %s, got %s", want, dialed)
+	}
+}
+
+func TestDialServiceError(t *testing.T) {
+	origSRV := lookupSRV
+	defer func() {
+		lookupSRV = origSRV
+	}()
+	lookupSRV = func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+		return "", []*net.SRV{{Target: ".", Port: 0}}, nil
+	}
+
+	if _, err := DialService(context.Background(), &Resolver{}, "grpc", "tcp", "mercari.io", nil); err == nil {
+		t.Fatalf("expect to be failed")
+	}
+}
+
+func TestDialFuncRoundRobin(t *testing.T) {
+	resolver := &Resolver{
+		cache: map[string]*entry{
+			"deeeet.com": {ips: []net.IP{
+				net.ParseIP("127.0.0.1"),
+				net.ParseIP("127.0.0.2"),
+				net.ParseIP("127.0.0.3"),
+			}},
+		},
+	}
+
+	var dialed []string
+	dialF := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		return nil, nil
+	}
+
+	dial := DialFunc(resolver, dialF, WithRoundRobin())
+	for i := 0; i < 4; i++ {
+		if _, err := dial(context.Background(), "tcp", "deeeet.com:443"); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	want := []string{"127.0.0.1:443", "127.0.0.2:443", "127.0.0.3:443", "127.0.0.1:443"}
+	if !reflect.DeepEqual(want, dialed) {
+		t.Fatalf("want %v, got %v", want, dialed)
+	}
+}
+
+func TestRoundRobinSelectorBounded(t *testing.T) {
+	s := &roundRobinSelector{}
+	s.setCached(func(host string) bool {
+		return host != "removed.mercari.io"
+	})
+
+	ips := []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("127.0.0.2")}
+	s.Pick("removed.mercari.io", ips)
+	for i := 0; i < maxDialHosts; i++ {
+		s.Pick(fmt.Sprintf("%d.mercari.io", i), ips)
+	}
+
+	if got := len(s.next); got != maxDialHosts {
+		t.Fatalf("got %d hosts; want %d", got, maxDialHosts)
+	}
+	if _, ok := s.next["removed.mercari.io"]; ok {
+		t.Fatalf("expect the host which is no longer cached to be dropped first")
+	}
+}
+
+func TestStickySelectorBounded(t *testing.T) {
+	s := &stickySelector{}
+	s.setCached(func(host string) bool {
+		return host != "removed.mercari.io"
+	})
+
+	ip := net.ParseIP("127.0.0.1")
+	s.Observe("removed.mercari.io", ip, nil)
+	for i := 0; i < maxDialHosts; i++ {
+		s.Observe(fmt.Sprintf("%d.mercari.io", i), ip, nil)
+	}
+
+	if got := len(s.last); got != maxDialHosts {
+		t.Fatalf("got %d hosts; want %d", got, maxDialHosts)
+	}
+	if _, ok := s.last["removed.mercari.io"]; ok {
+		t.Fatalf("expect the host which is no longer cached to be dropped first")
+	}
+}
+
+func TestDialFuncFailover(t *testing.T) {
+	resolver := &Resolver{
+		cache: map[string]*entry{
+			"deeeet.com": {ips: []net.IP{
+				net.ParseIP("127.0.0.1"),
+				net.ParseIP("127.0.0.2"),
+				net.ParseIP("127.0.0.3"),
+			}},
+		},
+		lookupTimeout: time.Second,
+	}
+
+	var timeouts []time.Duration
+	dialF := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		deadline, _ := ctx.Deadline()
+		timeouts = append(timeouts, time.Until(deadline).Round(time.Second))
+		if addr != "127.0.0.3:443" {
+			return nil, errors.New("i/o timeout")
+		}
+		return nil, nil
+	}
+
+	ctx, cancelF := context.WithTimeout(context.Background(), 12*time.Second)
+	defer cancelF()
+	if _, err := DialFunc(resolver, dialF, WithRoundRobin())(ctx, "tcp", "deeeet.com:443"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Each attempt gets a share of the remaining deadline.
+	if want := []time.Duration{4 * time.Second, 6 * time.Second, 12 * time.Second}; !reflect.DeepEqual(want, timeouts) {
+		t.Fatalf("want %v, got %v", want, timeouts)
+	}
+}
+
+func TestDialFuncFailoverCanceled(t *testing.T) {
+	resolver := &Resolver{
+		cache: map[string]*entry{
+			"deeeet.com": {ips: []net.IP{
+				net.ParseIP("127.0.0.1"),
+				net.ParseIP("127.0.0.2"),
+			}},
+		},
+		lookupTimeout: time.Second,
+	}
+
+	ctx, cancelF := context.WithCancel(context.Background())
+	defer cancelF()
+
+	var calls int
+	dialF := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		calls++
+		cancelF()
+		return nil, errors.New("connection refused")
+	}
+
+	if _, err := DialFunc(resolver, dialF)(ctx, "tcp", "deeeet.com:443"); err == nil {
+		t.Fatalf("expect to be failed")
+	}
+	if calls != 1 {
+		t.Fatalf("expect remaining IPs not to be dialed after cancel, got %d dials", calls)
+	}
+}
+
+type lastSelector struct{}
+
+func (lastSelector) Pick(host string, ips []net.IP) net.IP {
+	return ips[len(ips)-1]
+}
+
+func TestDialFuncSelector(t *testing.T) {
+	resolver := &Resolver{
+		cache: map[string]*entry{
+			"deeeet.com": {ips: []net.IP{
+				net.ParseIP("127.0.0.1"),
+				net.ParseIP("127.0.0.2"),
+				net.ParseIP("127.0.0.3"),
+			}},
+		},
+	}
+
+	var dialed []string
+	dialF := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		return nil, errors.New("connection refused")
+	}
+
+	if _, err := DialFunc(resolver, dialF, WithSelector(lastSelector{}))(context.Background(), "tcp", "deeeet.com:443"); err == nil {
+		t.Fatalf("expect to be failed")
+	}
+
+	// The picked IP is dialed first and the rest follow it.
+	want := []string{"127.0.0.3:443", "127.0.0.1:443", "127.0.0.2:443"}
+	if !reflect.DeepEqual(want, dialed) {
+		t.Fatalf("want %v, got %v", want, dialed)
+	}
+}
+
+func TestDialFuncHappyEyeballs(t *testing.T) {
+	resolver := &Resolver{
+		cache: map[string]*entry{
+			"deeeet.com": {ips: []net.IP{
+				net.ParseIP("::1"),
+				net.ParseIP("127.0.0.1"),
+			}},
+		},
+		lookupTimeout: time.Second,
+	}
+
+	// IPv6 never connects and IPv4 connects right away.
+	dialF := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if addr == "[::1]:443" {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		c1, c2 := net.Pipe()
+		c2.Close()
+		return c1, nil
+	}
+
+	dial := DialFunc(resolver, dialF, WithSelector(lastSelector{}), WithHappyEyeballs(10*time.Millisecond))
+	conn, err := dial(context.Background(), "tcp", "deeeet.com:443")
+	if err != nil {
+		t.Fatalf("expect IPv4 to be dialed after the delay, err: %s", err)
+	}
+	conn.Close()
+
+	// IPv4 is dialed without waiting when it is preferred.
+	WithIPVersionPreference(PreferIPv4).apply(resolver)
+	dial = DialFunc(resolver, dialF, WithSelector(firstSelector{}), WithHappyEyeballs(time.Hour))
+	conn, err = dial(context.Background(), "tcp", "deeeet.com:443")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	conn.Close()
+}
+
+func TestDialFuncHappyEyeballsClock(t *testing.T) {
+	clock := newFakeClock()
+	resolver := &Resolver{
+		cache: map[string]*entry{
+			"deeeet.com": {ips: []net.IP{
+				net.ParseIP("::1"),
+				net.ParseIP("127.0.0.1"),
+			}},
+		},
+		lookupTimeout: time.Second,
+		clock:         clock,
+	}
+
+	// IPv6 never connects and IPv4 connects right away.
+	dialF := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if addr == "[::1]:443" {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		c1, c2 := net.Pipe()
+		c2.Close()
+		return c1, nil
+	}
+
+	done := make(chan error, 1)
+	dial := DialFunc(resolver, dialF, WithSelector(firstSelector{}), WithHappyEyeballs(time.Hour))
+	go func() {
+		conn, err := dial(context.Background(), "tcp", "deeeet.com:443")
+		if err == nil {
+			conn.Close()
+		}
+		done <- err
+	}()
+
+	// The fallback delay is waited by the clock of the resolver.
+	for {
+		clock.mu.Lock()
+		waiting := len(clock.waiters) > 0
+		clock.mu.Unlock()
+		if waiting {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("expect the fallbacks to wait for the delay, err: %v", err)
+	default:
+	}
+	clock.Advance(time.Hour)
+	if err := <-done; err != nil {
+		t.Fatalf("expect IPv4 to be dialed after the delay, err: %s", err)
+	}
+}
+
+func TestDialFuncHappyEyeballsPrimaryFamily(t *testing.T) {
+	resolver := &Resolver{
+		cache: map[string]*entry{
+			"deeeet.com": {ips: []net.IP{
+				net.ParseIP("127.0.0.1"),
+				net.ParseIP("::1"),
+			}},
+		},
+		lookupTimeout: time.Second,
+	}
+
+	var dialed []string
+	var mu sync.Mutex
+	dialF := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		mu.Lock()
+		dialed = append(dialed, addr)
+		mu.Unlock()
+		c1, c2 := net.Pipe()
+		c2.Close()
+		return c1, nil
+	}
+
+	// IPv6 is dialed first even though the selector picks IPv4, and the
+	// connection is established before the fallback delay.
+	dial := DialFunc(resolver, dialF, WithSelector(firstSelector{}), WithHappyEyeballs(time.Hour))
+	conn, err := dial(context.Background(), "tcp", "deeeet.com:443")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	conn.Close()
+	if want := []string{"[::1]:443"}; !reflect.DeepEqual(want, dialed) {
+		t.Fatalf("want %v, got %v", want, dialed)
+	}
+}
+
+func TestDialFuncHappyEyeballsError(t *testing.T) {
+	resolver := &Resolver{
+		cache: map[string]*entry{
+			"deeeet.com": {ips: []net.IP{
+				net.ParseIP("::1"),
+				net.ParseIP("127.0.0.1"),
+			}},
+		},
+		lookupTimeout: time.Second,
+	}
+
+	var dialed []string
+	var mu sync.Mutex
+	dialF := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		mu.Lock()
+		dialed = append(dialed, addr)
+		mu.Unlock()
+		return nil, fmt.Errorf("failed to dial %s", addr)
+	}
+
+	// The fallbacks are started right away when the primaries fail.
+	dial := DialFunc(resolver, dialF, WithSelector(firstSelector{}), WithHappyEyeballs(time.Hour))
+	_, err := dial(context.Background(), "tcp", "deeeet.com:443")
+	if err == nil || err.Error() != "failed to dial [::1]:443" {
+		t.Fatalf("expect error of the primaries, got %v", err)
+	}
+	if len(dialed) != 2 {
+		t.Fatalf("expect both families to be dialed, got %v", dialed)
+	}
+}
+
+type firstSelector struct{}
+
+func (firstSelector) Pick(host string, ips []net.IP) net.IP {
+	return ips[0]
+}
+
+func TestDialFuncSticky(t *testing.T) {
+	resolver := &Resolver{
+		cache: map[string]*entry{
+			"deeeet.com": {ips: []net.IP{
+				net.ParseIP("127.0.0.1"),
+				net.ParseIP("127.0.0.2"),
+				net.ParseIP("127.0.0.3"),
+			}},
+		},
+	}
+
+	var dialed []string
+	failing := "127.0.0.1:443"
+	dialF := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		if addr == failing {
+			return nil, errors.New("connection refused")
+		}
+		return nil, nil
+	}
+
+	origFunc := randPerm
+	defer func() {
+		randPerm = origFunc
+	}()
+	randPerm = func(n int) []int {
+		perm := make([]int, n)
+		for i := range perm {
+			perm[i] = i
+		}
+		return perm
+	}
+
+	dial := DialFunc(resolver, dialF, WithSticky())
+	for i := 0; i < 3; i++ {
+		if _, err := dial(context.Background(), "tcp", "deeeet.com:443"); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	// The first IP fails, then the connected one is kept.
+	want := []string{"127.0.0.1:443", "127.0.0.2:443", "127.0.0.2:443", "127.0.0.2:443"}
+	if !reflect.DeepEqual(want, dialed) {
+		t.Fatalf("want %v, got %v", want, dialed)
+	}
+
+	// It moves on to the next IP when the sticky one fails.
+	dialed, failing = nil, "127.0.0.2:443"
+	for i := 0; i < 2; i++ {
+		if _, err := dial(context.Background(), "tcp", "deeeet.com:443"); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	want = []string{"127.0.0.2:443", "127.0.0.3:443", "127.0.0.3:443"}
+	if !reflect.DeepEqual(want, dialed) {
+		t.Fatalf("want %v, got %v", want, dialed)
+	}
+}
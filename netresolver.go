@@ -0,0 +1,167 @@
+package dnscache
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// NetResolver returns a `net.Resolver` which answers A and AAAA queries from the
+// cache of the resolver. Queries of the other types are forwarded to the nameserver
+// the Go resolver would use. It can be given to libraries which accept a
+// `net.Resolver`, or it can replace the default one so that libraries which use
+// net.DefaultResolver internally benefit from the cache:
+//
+//	net.DefaultResolver = resolver.NetResolver()
+//
+// The cache itself keeps looking up DNS by the original net.DefaultResolver. It
+// relies on the pure Go resolver, which is used on all platforms except Windows
+// and Plan 9 when `PreferGo` is set.
+func (r *Resolver) NetResolver() *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return &cacheConn{resolver: r, ctx: ctx, server: address}, nil
+		},
+	}
+}
+
+// cacheConn is an in-memory DNS stream connection which answers the queries
+// written to it from the cache. Since it is not a `net.PacketConn`, the Go resolver
+// frames messages with a length prefix as it does over TCP.
+type cacheConn struct {
+	resolver *Resolver
+	ctx      context.Context
+	server   string
+
+	mu     sync.Mutex
+	in     bytes.Buffer
+	out    bytes.Buffer
+	closed bool
+}
+
+func (c *cacheConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+
+	c.in.Write(b)
+	for {
+		buf := c.in.Bytes()
+		if len(buf) < 2 {
+			break
+		}
+		n := int(binary.BigEndian.Uint16(buf))
+		if len(buf) < 2+n {
+			break
+		}
+		query := bytes.Clone(buf[2 : 2+n])
+		c.in.Next(2 + n)
+
+		resp, err := c.answer(query)
+		if err != nil {
+			return 0, err
+		}
+		c.out.Write(binary.BigEndian.AppendUint16(nil, uint16(len(resp))))
+		c.out.Write(resp)
+	}
+	return len(b), nil
+}
+
+func (c *cacheConn) Read(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+	if c.out.Len() == 0 {
+		return 0, io.EOF
+	}
+	return c.out.Read(b)
+}
+
+func (c *cacheConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+func (c *cacheConn) LocalAddr() net.Addr  { return cacheAddr{} }
+func (c *cacheConn) RemoteAddr() net.Addr { return cacheAddr{} }
+
+func (c *cacheConn) SetDeadline(t time.Time) error      { return nil }
+func (c *cacheConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *cacheConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// answer returns the response to the given query in wire format.
+func (c *cacheConn) answer(b []byte) ([]byte, error) {
+	q, err := parseMessage(b)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &dnsMessage{
+		id:        q.id,
+		flags:     flagQR | q.flags&flagRD | flagRA,
+		questions: q.questions,
+	}
+	if len(q.questions) != 1 {
+		m.flags |= rcodeNotImp
+		return m.pack()
+	}
+
+	question := q.questions[0]
+	if question.class != classINET || (question.typ != typeA && question.typ != typeAAAA) {
+		return c.forward(q, m)
+	}
+
+	// The name is fully qualified, so it is kept so to not be expanded again by
+	// the search domains of the resolver.
+	ips, err := c.resolver.Fetch(c.ctx, question.name)
+	var dnsErr *net.DNSError
+	switch {
+	case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+		m.flags |= rcodeNXDomain
+	case err != nil:
+		m.flags |= rcodeServFail
+	default:
+		for _, ip := range ips {
+			rr := dnsRR{name: question.name, typ: question.typ, class: classINET}
+			switch ip4 := ip.To4(); {
+			case question.typ == typeA && ip4 != nil:
+				rr.data = ip4
+			case question.typ == typeAAAA && ip4 == nil && len(ip) == net.IPv6len:
+				rr.data = ip
+			default:
+				continue
+			}
+			m.answers = append(m.answers, rr)
+		}
+	}
+	return m.pack()
+}
+
+// forward sends the query to the nameserver and returns its response, or m
+// with SERVFAIL if it fails.
+func (c *cacheConn) forward(q, m *dnsMessage) ([]byte, error) {
+	resp, err := exchange(c.ctx, c.server, q)
+	if err != nil {
+		m.flags |= rcodeServFail
+		return m.pack()
+	}
+	return resp.pack()
+}
+
+// cacheAddr is the address of a cacheConn.
+type cacheAddr struct{}
+
+func (cacheAddr) Network() string { return "dnscache" }
+func (cacheAddr) String() string  { return "dnscache" }
@@ -0,0 +1,94 @@
+package dnscache
+
+import (
+	"context"
+	"net"
+	"sort"
+	"testing"
+)
+
+func TestNetResolver(t *testing.T) {
+	originalFunc := lookupIP
+	defer func() {
+		lookupIP = originalFunc
+	}()
+
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		if host == "deeeet.com." {
+			return []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("::1")}, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	resolver := testResolver(t)
+	defer resolver.Stop()
+
+	nr := resolver.NetResolver()
+	addrs, err := nr.LookupHost(context.Background(), "deeeet.com.")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	sort.Strings(addrs)
+	if len(addrs) != 2 || addrs[0] != "127.0.0.1" || addrs[1] != "::1" {
+		t.Fatalf("want [127.0.0.1 ::1], got %v", addrs)
+	}
+
+	if _, ok := resolver.cached("deeeet.com."); !ok {
+		t.Fatalf("expect the lookup to go through the cache")
+	}
+
+	_, err = nr.LookupHost(context.Background(), "notfound.test.")
+	dnsErr, ok := err.(*net.DNSError)
+	if !ok || !dnsErr.IsNotFound {
+		t.Fatalf("expect not found error, got %v", err)
+	}
+}
+
+func TestNetResolver_search(t *testing.T) {
+	originalFunc := lookupIP
+	defer func() {
+		lookupIP = originalFunc
+	}()
+
+	var lookups []string
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		lookups = append(lookups, host)
+		return []net.IP{net.ParseIP("127.0.0.1")}, nil
+	}
+
+	resolver := testResolver(t)
+	defer resolver.Stop()
+	WithSearchDomains([]string{"svc.cluster.local"}, 5).apply(resolver)
+
+	// The names queried by the net.Resolver are already expanded.
+	if _, err := resolver.NetResolver().LookupHost(context.Background(), "db."); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(lookups) != 1 || lookups[0] != "db." {
+		t.Fatalf("got %v; want only the fully qualified name to be looked up", lookups)
+	}
+}
+
+func TestCacheConnForward(t *testing.T) {
+	server := testNameserver(t, func(q *dnsMessage, tcp bool) *dnsMessage {
+		m := &dnsMessage{id: q.id, flags: flagQR | flagRA, questions: q.questions}
+		m.answers = append(m.answers, dnsRR{name: q.questions[0].name, typ: typeTXT, class: classINET, data: []byte("\x02ok")})
+		return m
+	})
+
+	c := &cacheConn{resolver: &Resolver{}, ctx: context.Background(), server: server}
+	q := &dnsMessage{id: 1, flags: flagRD, questions: []dnsQuestion{{name: "deeeet.com.", typ: typeTXT, class: classINET}}}
+	b, _ := q.pack()
+	resp, err := c.answer(b)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	m, err := parseMessage(resp)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if m.id != 1 || len(m.answers) != 1 || m.answers[0].typ != typeTXT {
+		t.Fatalf("unexpected response: %+v", m)
+	}
+}
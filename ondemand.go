@@ -0,0 +1,34 @@
+package dnscache
+
+import "time"
+
+// WithOnDemand disables background refreshing entirely, so that no goroutine
+// nor ticker is started, e.g. for CLI tools and serverless functions. Instead,
+// Fetch re-resolves a cached entry synchronously once it is older than maxAge.
+// maxAge must be positive. The frequency given to New is ignored.
+func WithOnDemand(maxAge time.Duration) Option {
+	return Option{apply: func(r *Resolver) {
+		if maxAge <= 0 {
+			r.invalid("WithOnDemand", "non-positive max age")
+		}
+		r.onDemand = true
+		r.maxAge = maxAge
+	}}
+}
+
+// WithMaxEntryAge makes Fetch re-resolve a cached entry synchronously instead of
+// serving it once it is older than maxAge, e.g. when background refreshes fall
+// behind, keep failing or are paused, so that arbitrarily old IPs are never
+// served. It is the same as maxAge of WithOnDemand while keeping background
+// refreshing, and the last one given wins.
+func WithMaxEntryAge(maxAge time.Duration) Option {
+	return Option{apply: func(r *Resolver) {
+		r.invalidDuration("WithMaxEntryAge", "max age", maxAge)
+		r.maxAge = maxAge
+	}}
+}
+
+// fresh reports whether the cached entry is young enough to be served by Fetch.
+func (r *Resolver) fresh(e *entry) bool {
+	return r.maxAge <= 0 || r.now().Sub(e.updated) < r.maxAge
+}
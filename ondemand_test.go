@@ -0,0 +1,87 @@
+package dnscache
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestOnDemand(t *testing.T) {
+	originalFunc := lookupIP
+	defer func() {
+		lookupIP = originalFunc
+	}()
+
+	lookups := 0
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		lookups++
+		return []net.IP{net.ParseIP("127.0.0.1")}, nil
+	}
+
+	r, err := New(time.Millisecond, time.Second, WithOnDemand(time.Hour))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer r.Stop()
+
+	select {
+	case <-r.done:
+	default:
+		t.Fatalf("expect no background goroutine")
+	}
+
+	r.Fetch(context.Background(), "deeeet.com")
+	r.Fetch(context.Background(), "deeeet.com")
+	if lookups != 1 {
+		t.Fatalf("got %d lookups; want 1", lookups)
+	}
+
+	// Expire the entry.
+	e, _ := r.cached("deeeet.com")
+	e.updated = time.Now().Add(-2 * time.Hour)
+	r.Fetch(context.Background(), "deeeet.com")
+	if lookups != 2 {
+		t.Fatalf("got %d lookups; want the expired entry to be re-resolved", lookups)
+	}
+
+	if err := r.Healthy(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestMaxEntryAge(t *testing.T) {
+	clock := newFakeClock()
+	lookups := 0
+	r := &Resolver{
+		cache:         map[string]*entry{},
+		lookupTimeout: time.Second,
+		lookupIPFn: func(ctx context.Context, network, host string) ([]net.IP, error) {
+			lookups++
+			return []net.IP{net.ParseIP("127.0.0.1")}, nil
+		},
+		defaultLookupTimeout: time.Second,
+		clock:                clock,
+	}
+	WithMaxEntryAge(time.Minute).apply(r)
+
+	r.Fetch(context.Background(), "deeeet.com")
+	clock.Advance(59 * time.Second)
+	r.Fetch(context.Background(), "deeeet.com")
+	if lookups != 1 {
+		t.Fatalf("got %d lookups; want the entry to be served", lookups)
+	}
+	if _, ok := r.FetchCached("deeeet.com"); !ok {
+		t.Fatalf("expect the entry to be cached")
+	}
+
+	// The refresher fell behind.
+	clock.Advance(time.Second)
+	if _, ok := r.FetchCached("deeeet.com"); ok {
+		t.Fatalf("expect the old entry not to be served")
+	}
+	r.Fetch(context.Background(), "deeeet.com")
+	if lookups != 2 {
+		t.Fatalf("got %d lookups; want the old entry to be re-resolved", lookups)
+	}
+}
@@ -1,13 +1,263 @@
 package dnscache
 
-import "log/slog"
+import (
+	"log/slog"
+	"net"
+	"net/netip"
+	"strconv"
+	"time"
+)
 
 type Option struct {
 	apply func(r *Resolver)
 }
 
-func WithLogger(logger *slog.Logger) Option {
+// WithLogger sets the logger of the resolver, which is slog.Default() by default.
+// It accepts a *slog.Logger or any other implementation of Logger.
+func WithLogger(logger Logger) Option {
 	return Option{apply: func(r *Resolver) {
 		r.logger = logger
 	}}
 }
+
+// WithLogAttrs attaches the given attributes, e.g. the service or resolver name,
+// to every log record emitted by the resolver so that the records of multiple
+// resolvers in a process can be told apart. It applies to the logger given by
+// WithLogger regardless of the order of the options.
+func WithLogAttrs(attrs ...slog.Attr) Option {
+	return Option{apply: func(r *Resolver) {
+		r.logAttrs = append(r.logAttrs, attrs...)
+	}}
+}
+
+// WithRefreshErrorHandler sets a function called with the host and the error
+// whenever a background refresh of a cached host fails, in addition to logging
+// it, e.g. to count failures per host or to trigger a fallback. The error is a
+// *RefreshError. It is called synchronously from the refresh goroutine.
+func WithRefreshErrorHandler(handler func(host string, err error)) Option {
+	return Option{apply: func(r *Resolver) {
+		r.onRefreshError = handler
+	}}
+}
+
+// WithRetry retries failed lookups of both LookupIP and background refreshes.
+// attempts is the maximum number of lookups including the first one. The delay
+// before a retry starts at baseDelay and doubles for each further retry up to a
+// minute, and it is randomly varied by up to the given jitter fraction (0 to 1) of
+// itself.
+func WithRetry(attempts int, baseDelay time.Duration, jitter float64) Option {
+	return Option{apply: func(r *Resolver) {
+		r.retry = retryPolicy{
+			attempts:  attempts,
+			baseDelay: baseDelay,
+			jitter:    jitter,
+		}
+	}}
+}
+
+// WithHostsFile makes the resolver consult the given hosts-format file before
+// DNS, as getaddrinfo does. If path is empty, the system hosts file is used.
+// The file is re-read on refresh when it has been modified.
+func WithHostsFile(path string) Option {
+	return Option{apply: func(r *Resolver) {
+		if path == "" {
+			path = defaultHostsFile
+		}
+		r.hostsPath = path
+	}}
+}
+
+// WithCacheCapacity sets the initial capacity of the cache, which is 64 by
+// default, to avoid repeated growth of the cache when many hosts are cached.
+func WithCacheCapacity(capacity int) Option {
+	return Option{apply: func(r *Resolver) {
+		if capacity < 0 {
+			r.invalid("WithCacheCapacity", "negative capacity")
+		}
+		if capacity > 0 && len(r.cache) == 0 {
+			r.cache = make(map[string]*entry, capacity)
+		}
+	}}
+}
+
+// WithStaticEntries pre-populates the resolver with fixed host to IP list mappings.
+// Static entries are always served as they are and are never looked up nor refreshed.
+func WithStaticEntries(entries map[string][]net.IP) Option {
+	return Option{apply: func(r *Resolver) {
+		if r.static == nil {
+			r.static = make(map[string]*entry, len(entries))
+		}
+		for host, ips := range entries {
+			r.static[host] = &entry{ips: ips}
+		}
+	}}
+}
+
+// WithNameserver makes the resolver send queries directly to the given DNS server
+// ("host" or "host:port") using its own wire-level client instead of the system resolver.
+func WithNameserver(addr string) Option {
+	return Option{apply: func(r *Resolver) {
+		if host, _, err := net.SplitHostPort(addr); addr == "" || err == nil && host == "" {
+			r.invalid("WithNameserver", "empty address")
+		}
+		r.nameserver = newNameserver(addr)
+	}}
+}
+
+// WithClientSubnet attaches an EDNS Client Subnet option (RFC 7871) with the given
+// prefix to queries so that CDNs can return answers appropriate for the client network.
+// It requires WithNameserver.
+func WithClientSubnet(prefix netip.Prefix) Option {
+	return Option{apply: func(r *Resolver) {
+		r.clientSubnet = prefix
+	}}
+}
+
+// WithDNSSEC requests DNSSEC records from the nameserver and records whether it
+// authenticated each answer (the AD bit), which is exposed by `Validation`.
+// The nameserver must be a trusted validating resolver reached over a secure path.
+// If require is true, answers which are not authenticated fail with ErrInsecure
+// and are never cached. It requires WithNameserver.
+func WithDNSSEC(require bool) Option {
+	return Option{apply: func(r *Resolver) {
+		r.dnssec = dnssecCheck
+		if require {
+			r.dnssec = dnssecRequire
+		}
+	}}
+}
+
+// WithMDNS resolves names under .local by multicast DNS (RFC 6762) on the local
+// IPv4 network instead of the configured backend. Results are cached and refreshed
+// like any other entry.
+func WithMDNS() Option {
+	return Option{apply: func(r *Resolver) {
+		r.mdns = true
+	}}
+}
+
+// WithIPVersionPreference orders or filters looked up IPs by address family before
+// they are cached, e.g. to avoid IPv6 addresses on networks with broken IPv6.
+// DialFunc dials addresses of the preferred family first.
+func WithIPVersionPreference(p IPVersionPreference) Option {
+	return Option{apply: func(r *Resolver) {
+		if p < AnyIPVersion || p > IPv6Only {
+			r.invalid("WithIPVersionPreference", "unknown preference "+strconv.Itoa(int(p)))
+		}
+		r.ipVersion = p
+	}}
+}
+
+// WithNetwork sets the address family to look up: "ip" (default) for both IPv4 and
+// IPv6, "ip4" for IPv4 only or "ip6" for IPv6 only. Unlike IPv4Only or IPv6Only of
+// WithIPVersionPreference, records of the other family are not queried at all.
+func WithNetwork(network string) Option {
+	return Option{apply: func(r *Resolver) {
+		r.network = network
+	}}
+}
+
+// WithRotation makes successive Fetch calls for the same host return the cached IP
+// list rotated or shuffled, which gives cheap client-side load balancing.
+func WithRotation(rotation Rotation) Option {
+	return Option{apply: func(r *Resolver) {
+		if rotation < NoRotation || rotation > RotateShuffle {
+			r.invalid("WithRotation", "unknown rotation "+strconv.Itoa(int(rotation)))
+		}
+		r.rotation = rotation
+	}}
+}
+
+// WithAddressSorting sorts looked up IPs by the destination address selection
+// rules of RFC 6724 (scope, label, precedence and source address matching) before
+// they are cached, instead of keeping the order returned by the upstream resolver.
+func WithAddressSorting() Option {
+	return Option{apply: func(r *Resolver) {
+		r.sortAddrs = true
+	}}
+}
+
+// WithIPFilter sets a hook which validates or transforms the IPs of every lookup
+// result before it is cached, e.g. to strip link-local or bogon addresses or to
+// enforce allowed ranges. If the hook returns an error, the lookup fails with it
+// and nothing is cached. The hook must not modify the given slice.
+func WithIPFilter(filter func(host string, ips []net.IP) ([]net.IP, error)) Option {
+	return Option{apply: func(r *Resolver) {
+		r.ipFilter = filter
+	}}
+}
+
+// WithLookupTimeout sets the timeout of foreground lookups by LookupIP, Fetch and
+// DialFunc. It is applied only when the caller's context has no deadline.
+func WithLookupTimeout(timeout time.Duration) Option {
+	return Option{apply: func(r *Resolver) {
+		r.invalidDuration("WithLookupTimeout", "timeout", timeout)
+		if timeout > 0 {
+			r.lookupTimeout = timeout
+		}
+	}}
+}
+
+// WithRefreshTimeout sets the timeout of each lookup of background refreshes.
+func WithRefreshTimeout(timeout time.Duration) Option {
+	return Option{apply: func(r *Resolver) {
+		r.invalidDuration("WithRefreshTimeout", "timeout", timeout)
+		if timeout > 0 {
+			r.defaultLookupTimeout = timeout
+		}
+	}}
+}
+
+// WithSlowLookupThreshold logs lookups, both foreground and by background
+// refreshes, which take threshold or longer with the host and the duration, to
+// spot degradation of the upstream resolver before lookups start timing out.
+func WithSlowLookupThreshold(threshold time.Duration) Option {
+	return Option{apply: func(r *Resolver) {
+		r.invalidDuration("WithSlowLookupThreshold", "threshold", threshold)
+		r.slowLookup = threshold
+	}}
+}
+
+// WithSearchDomains sets resolv.conf-style search domains and ndots for unqualified
+// names. Names with fewer dots than ndots are tried with each search domain appended
+// first, then as they are; other names are tried as they are first. Only the entry
+// of the name which finally resolved is cached and refreshed, and Fetch of the
+// unqualified name is served from it.
+func WithSearchDomains(domains []string, ndots int) Option {
+	return Option{apply: func(r *Resolver) {
+		r.search = domains
+		r.ndots = ndots
+	}}
+}
+
+// WithResolvConf reads search domains and ndots from the given resolv.conf file like
+// WithSearchDomains. If path is empty, the system resolv.conf is used. The file is
+// re-read on refresh when it has been modified, so that long-running processes
+// pick up changes pushed by the platform. Unless WithNameserver is given, the
+// first nameserver of the file, if any, is queried directly like WithNameserver,
+// and the resolver switches to the new one when it changes.
+func WithResolvConf(path string) Option {
+	return Option{apply: func(r *Resolver) {
+		if path == "" {
+			path = defaultResolvConf
+		}
+		r.resolvConfPath = path
+	}}
+}
+
+// WithWireFormat keeps the raw DNS response messages of every lookup, with all
+// sections, TTLs and DNSSEC records, in the cache in addition to the IP list.
+// They are available by `Messages`. It requires WithNameserver.
+func WithWireFormat() Option {
+	return Option{apply: func(r *Resolver) {
+		r.wireFormat = true
+	}}
+}
+
+// WithReverseIndex maintains an index from IPs to the cached hosts resolving to
+// them, which is queried by `HostsForIP`.
+func WithReverseIndex() Option {
+	return Option{apply: func(r *Resolver) {
+		r.reverse = make(reverseIndex)
+	}}
+}
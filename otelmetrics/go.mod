@@ -0,0 +1,23 @@
+module go.mercari.io/go-dnscache/otelmetrics
+
+go 1.21
+
+require (
+	go.mercari.io/go-dnscache v0.2.0
+	go.opentelemetry.io/otel v1.24.0
+	go.opentelemetry.io/otel/metric v1.24.0
+	go.opentelemetry.io/otel/sdk/metric v1.24.0
+)
+
+require (
+	github.com/go-logr/logr v1.4.1 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	go.opentelemetry.io/otel/sdk v1.24.0 // indirect
+	go.opentelemetry.io/otel/trace v1.24.0 // indirect
+	golang.org/x/sys v0.17.0 // indirect
+)
+
+// The parent module is used from this repository until go-dnscache v0.2.0,
+// which introduces the APIs used by this module, is released. Drop this replace
+// directive when releasing this module.
+replace go.mercari.io/go-dnscache => ../
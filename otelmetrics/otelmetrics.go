@@ -0,0 +1,146 @@
+// Package otelmetrics instruments a go-dnscache resolver with the OpenTelemetry
+// metrics API. The instruments are created by a meter of the given
+// MeterProvider, so they are exported by whatever exporter it is set up with,
+// e.g. OTLP.
+package otelmetrics // import "go.mercari.io/go-dnscache/otelmetrics"
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"time"
+
+	dnscache "go.mercari.io/go-dnscache"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// ScopeName is the instrumentation scope name of the meter.
+const ScopeName = "go.mercari.io/go-dnscache/otelmetrics"
+
+var (
+	resultSuccess = metric.WithAttributes(attribute.String("result", "success"))
+	resultError   = metric.WithAttributes(attribute.String("result", "error"))
+)
+
+// Metrics records the measurements of a resolver with OpenTelemetry instruments.
+// It implements dnscache.Metrics, so give it to the resolver by dnscache.WithMetrics:
+//
+//	m, _ := otelmetrics.New(provider)
+//	resolver, _ := dnscache.New(freq, timeout, dnscache.WithMetrics(m))
+//	m.Track(resolver)
+type Metrics struct {
+	resolver atomic.Pointer[dnscache.Resolver]
+
+	cacheHits       metric.Int64Counter
+	cacheMisses     metric.Int64Counter
+	lookupDuration  metric.Float64Histogram
+	refreshDuration metric.Float64Histogram
+	refreshErrors   metric.Int64Counter
+	dialDuration    metric.Float64Histogram
+}
+
+var (
+	_ dnscache.Metrics     = (*Metrics)(nil)
+	_ dnscache.DialMetrics = (*Metrics)(nil)
+)
+
+// New creates the instruments with a meter of the given provider. If provider is
+// nil, the global MeterProvider is used.
+func New(provider metric.MeterProvider) (*Metrics, error) {
+	if provider == nil {
+		provider = otel.GetMeterProvider()
+	}
+	meter := provider.Meter(ScopeName)
+
+	m := &Metrics{}
+	var err error
+	if m.cacheHits, err = meter.Int64Counter("dnscache.cache.hits",
+		metric.WithDescription("Number of fetches served from the DNS cache."),
+	); err != nil {
+		return nil, err
+	}
+	if m.cacheMisses, err = meter.Int64Counter("dnscache.cache.misses",
+		metric.WithDescription("Number of fetches which had to look the host up."),
+	); err != nil {
+		return nil, err
+	}
+	if m.lookupDuration, err = meter.Float64Histogram("dnscache.lookup.duration",
+		metric.WithDescription("Latency of DNS lookups including retries."),
+		metric.WithUnit("s"),
+	); err != nil {
+		return nil, err
+	}
+	if m.refreshDuration, err = meter.Float64Histogram("dnscache.refresh.duration",
+		metric.WithDescription("Duration of refresh cycles of the DNS cache."),
+		metric.WithUnit("s"),
+	); err != nil {
+		return nil, err
+	}
+	if m.refreshErrors, err = meter.Int64Counter("dnscache.refresh.errors",
+		metric.WithDescription("Number of hosts which failed to be refreshed."),
+	); err != nil {
+		return nil, err
+	}
+	if m.dialDuration, err = meter.Float64Histogram("dnscache.dial.duration",
+		metric.WithDescription("Connect latency of dials to the cached IPs."),
+		metric.WithUnit("s"),
+	); err != nil {
+		return nil, err
+	}
+	if _, err = meter.Int64ObservableGauge("dnscache.cache.size",
+		metric.WithDescription("Number of hosts in the DNS cache."),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			if r := m.resolver.Load(); r != nil {
+				o.Observe(int64(r.Len()))
+			}
+			return nil
+		}),
+	); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Track makes the metrics report the cache size of the given resolver.
+func (m *Metrics) Track(resolver *dnscache.Resolver) {
+	m.resolver.Store(resolver)
+}
+
+// CacheHit implements dnscache.Metrics.
+func (m *Metrics) CacheHit(host string) {
+	m.cacheHits.Add(context.Background(), 1)
+}
+
+// CacheMiss implements dnscache.Metrics.
+func (m *Metrics) CacheMiss(host string) {
+	m.cacheMisses.Add(context.Background(), 1)
+}
+
+// LookupDone implements dnscache.Metrics. The duration is recorded with the
+// result attribute only, since the host would make the SDK aggregate a separate
+// histogram for every host.
+func (m *Metrics) LookupDone(host string, d time.Duration, err error) {
+	result := resultSuccess
+	if err != nil {
+		result = resultError
+	}
+	m.lookupDuration.Record(context.Background(), d.Seconds(), result)
+}
+
+// RefreshDone implements dnscache.Metrics.
+func (m *Metrics) RefreshDone(d time.Duration, failures int) {
+	m.refreshDuration.Record(context.Background(), d.Seconds())
+	m.refreshErrors.Add(context.Background(), int64(failures))
+}
+
+// DialDone implements dnscache.DialMetrics. Dials are recorded with the result
+// attribute only, like lookups.
+func (m *Metrics) DialDone(host string, ip net.IP, d time.Duration, err error) {
+	result := resultSuccess
+	if err != nil {
+		result = resultError
+	}
+	m.dialDuration.Record(context.Background(), d.Seconds(), result)
+}
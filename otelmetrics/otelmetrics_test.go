@@ -0,0 +1,87 @@
+package otelmetrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	dnscache "go.mercari.io/go-dnscache"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestMetrics(t *testing.T) {
+	hosts := filepath.Join(t.TempDir(), "hosts")
+	if err := os.WriteFile(hosts, []byte("127.0.0.1 deeeet.com\n"), 0o644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	reader := sdkmetric.NewManualReader()
+	m, err := New(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	resolver, err := dnscache.New(time.Minute, time.Second, dnscache.WithHostsFile(hosts), dnscache.WithMetrics(m))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resolver.Stop()
+	m.Track(resolver)
+
+	for i := 0; i < 3; i++ {
+		if _, err := resolver.Fetch(context.Background(), "deeeet.com"); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	resolver.Refresh()
+
+	dialer := dnscache.NewDialer(resolver, func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, errors.New("connection refused")
+	}, dnscache.WithDialStats())
+	dialer.DialContext(context.Background(), "tcp", "deeeet.com:443")
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	got := make(map[string]int64)
+	for _, sm := range rm.ScopeMetrics {
+		for _, md := range sm.Metrics {
+			switch data := md.Data.(type) {
+			case metricdata.Sum[int64]:
+				for _, dp := range data.DataPoints {
+					got[md.Name] += dp.Value
+				}
+			case metricdata.Gauge[int64]:
+				for _, dp := range data.DataPoints {
+					got[md.Name] = dp.Value
+				}
+			case metricdata.Histogram[float64]:
+				for _, dp := range data.DataPoints {
+					got[md.Name] += int64(dp.Count)
+				}
+			}
+		}
+	}
+
+	want := map[string]int64{
+		"dnscache.cache.hits":       3,
+		"dnscache.cache.misses":     1,
+		"dnscache.cache.size":       1,
+		"dnscache.lookup.duration":  2,
+		"dnscache.refresh.duration": 1,
+		"dnscache.refresh.errors":   0,
+		"dnscache.dial.duration":    1,
+	}
+	for name, value := range want {
+		if got[name] != value {
+			t.Errorf("%s: got %d; want %d", name, got[name], value)
+		}
+	}
+}
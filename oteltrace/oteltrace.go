@@ -0,0 +1,52 @@
+// Package oteltrace traces lookups and refreshes of a go-dnscache resolver with
+// OpenTelemetry, so that time spent on DNS shows up in distributed traces
+// instead of being hidden in the dial.
+package oteltrace // import "go.mercari.io/go-dnscache/oteltrace"
+
+import (
+	"context"
+
+	dnscache "go.mercari.io/go-dnscache"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope name of the tracer.
+const ScopeName = "go.mercari.io/go-dnscache/oteltrace"
+
+// Attribute keys set on the spans.
+const (
+	HostKey     = attribute.Key("dnscache.host")
+	CacheHitKey = attribute.Key("dnscache.cache_hit")
+)
+
+// WithTracerProvider returns an option which makes the resolver emit spans of
+// Fetch, LookupIP and refresh cycles with a tracer of the given provider.
+func WithTracerProvider(provider trace.TracerProvider) dnscache.Option {
+	return dnscache.WithTracer(&tracer{tracer: provider.Tracer(ScopeName)})
+}
+
+// tracer adapts an OpenTelemetry tracer to dnscache.Tracer.
+type tracer struct {
+	tracer trace.Tracer
+}
+
+func (t *tracer) Start(ctx context.Context, name, host string, cacheHit bool) (context.Context, func(err error)) {
+	attrs := []attribute.KeyValue{CacheHitKey.Bool(cacheHit)}
+	if host != "" {
+		attrs = append(attrs, HostKey.String(host))
+	}
+
+	ctx, span := t.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
@@ -0,0 +1,69 @@
+package oteltrace
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	dnscache "go.mercari.io/go-dnscache"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestWithTracerProvider(t *testing.T) {
+	hosts := filepath.Join(t.TempDir(), "hosts")
+	if err := os.WriteFile(hosts, []byte("127.0.0.1 deeeet.com\n"), 0o644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	resolver, err := dnscache.New(time.Minute, time.Second, dnscache.WithHostsFile(hosts), WithTracerProvider(provider))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resolver.Stop()
+
+	if _, err := resolver.Fetch(context.Background(), "deeeet.com"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := resolver.Fetch(context.Background(), "deeeet.com"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := resolver.LookupIP(context.Background(), "invalid.deeeet.test"); err == nil {
+		t.Fatalf("expect to be failed")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 4 {
+		t.Fatalf("got %d spans; want 4", len(spans))
+	}
+
+	lookup, miss, hit, failed := spans[0], spans[1], spans[2], spans[3]
+	if lookup.Name() != dnscache.SpanLookupIP || lookup.Parent().SpanID() != miss.SpanContext().SpanID() {
+		t.Fatalf("expect LookupIP span to be a child of Fetch span")
+	}
+	if !hasAttribute(miss.Attributes(), CacheHitKey.Bool(false)) || !hasAttribute(miss.Attributes(), HostKey.String("deeeet.com")) {
+		t.Fatalf("got %v; want a cache miss of deeeet.com", miss.Attributes())
+	}
+	if hit.Name() != dnscache.SpanFetch || !hasAttribute(hit.Attributes(), CacheHitKey.Bool(true)) {
+		t.Fatalf("got %v; want a cache hit", hit.Attributes())
+	}
+	if failed.Status().Code != codes.Error {
+		t.Fatalf("got %v; want error status", failed.Status())
+	}
+}
+
+func hasAttribute(attrs []attribute.KeyValue, want attribute.KeyValue) bool {
+	for _, attr := range attrs {
+		if attr == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,22 @@
+package dnscache
+
+import "runtime/pprof"
+
+// WithName names the resolver. The name is attached as the "dnscache.resolver"
+// pprof label to the background refresher, so that the resolvers of a process
+// can be told apart in CPU and heap profiles.
+func WithName(name string) Option {
+	return Option{apply: func(r *Resolver) {
+		r.name = name
+	}}
+}
+
+// refreshLabels returns the pprof labels of the refresher of r with the given
+// additional key and value pairs.
+func (r *Resolver) refreshLabels(args ...string) pprof.LabelSet {
+	labels := []string{"dnscache", "refresh"}
+	if r.name != "" {
+		labels = append(labels, "dnscache.resolver", r.name)
+	}
+	return pprof.Labels(append(labels, args...)...)
+}
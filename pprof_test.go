@@ -0,0 +1,41 @@
+package dnscache
+
+import (
+	"context"
+	"net"
+	"runtime/pprof"
+	"testing"
+	"time"
+)
+
+func TestRefreshLabels(t *testing.T) {
+	var labels map[string]string
+	r := &Resolver{
+		cache: map[string]*entry{"deeeet.com": {ips: []net.IP{net.ParseIP("127.0.0.1")}}},
+		lookupIPFn: func(ctx context.Context, network, host string) ([]net.IP, error) {
+			labels = make(map[string]string)
+			pprof.ForLabels(ctx, func(key, value string) bool {
+				labels[key] = value
+				return true
+			})
+			return []net.IP{net.ParseIP("127.0.0.1")}, nil
+		},
+		defaultLookupTimeout: time.Second,
+	}
+	WithName("internal").apply(r)
+
+	r.Refresh()
+	want := map[string]string{
+		"dnscache":          "refresh",
+		"dnscache.resolver": "internal",
+		"dnscache.host":     "deeeet.com",
+	}
+	if len(labels) != len(want) {
+		t.Fatalf("got %v; want %v", labels, want)
+	}
+	for k, v := range want {
+		if labels[k] != v {
+			t.Fatalf("got %v; want %v", labels, want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Package promcollector is a prometheus.Collector of a go-dnscache resolver, so
+// that its cache hit ratio, lookup and refresh latency histograms and refresh
+// error counter can be scraped with the other metrics of the process and alerted
+// on.
+package promcollector // import "go.mercari.io/go-dnscache/promcollector"
+
+import (
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dnscache "go.mercari.io/go-dnscache"
+)
+
+// Namespace is the namespace of the metrics when none is given to New.
+const Namespace = "dnscache"
+
+// Collector is a prometheus.Collector which implements dnscache.Metrics.
+// Give it to the resolver by dnscache.WithMetrics, then register it to a
+// prometheus.Registerer:
+//
+//	c := promcollector.New("")
+//	resolver, _ := dnscache.New(freq, timeout, dnscache.WithMetrics(c))
+//	c.Track(resolver)
+//	prometheus.MustRegister(c)
+type Collector struct {
+	resolver atomic.Pointer[dnscache.Resolver]
+
+	cacheSize       *prometheus.Desc
+	cacheHits       prometheus.Counter
+	cacheMisses     prometheus.Counter
+	lookupDuration  *prometheus.HistogramVec
+	refreshDuration prometheus.Histogram
+	refreshErrors   prometheus.Counter
+	dialDuration    *prometheus.HistogramVec
+}
+
+var (
+	_ dnscache.Metrics     = (*Collector)(nil)
+	_ dnscache.DialMetrics = (*Collector)(nil)
+)
+
+// New returns a collector whose metrics are in the given namespace. If namespace
+// is empty, Namespace is used.
+func New(namespace string) *Collector {
+	if namespace == "" {
+		namespace = Namespace
+	}
+
+	return &Collector{
+		cacheSize: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "cache", "size"),
+			"Number of hosts in the DNS cache.",
+			nil, nil,
+		),
+		cacheHits: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "cache",
+			Name:      "hits_total",
+			Help:      "Number of fetches served from the DNS cache.",
+		}),
+		cacheMisses: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "cache",
+			Name:      "misses_total",
+			Help:      "Number of fetches which had to look the host up.",
+		}),
+		lookupDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "lookup",
+			Name:      "duration_seconds",
+			Help:      "Latency of DNS lookups including retries.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"result"}),
+		refreshDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "refresh",
+			Name:      "duration_seconds",
+			Help:      "Duration of refresh cycles of the DNS cache.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+		refreshErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "refresh",
+			Name:      "errors_total",
+			Help:      "Number of hosts which failed to be refreshed.",
+		}),
+		dialDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "dial",
+			Name:      "duration_seconds",
+			Help:      "Connect latency of dials to the cached IPs.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"result"}),
+	}
+}
+
+// Track makes the collector report the cache size of the given resolver.
+func (c *Collector) Track(resolver *dnscache.Resolver) {
+	c.resolver.Store(resolver)
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.cacheSize
+	c.cacheHits.Describe(ch)
+	c.cacheMisses.Describe(ch)
+	c.lookupDuration.Describe(ch)
+	c.refreshDuration.Describe(ch)
+	c.refreshErrors.Describe(ch)
+	c.dialDuration.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	if r := c.resolver.Load(); r != nil {
+		ch <- prometheus.MustNewConstMetric(c.cacheSize, prometheus.GaugeValue, float64(r.Len()))
+	}
+	c.cacheHits.Collect(ch)
+	c.cacheMisses.Collect(ch)
+	c.lookupDuration.Collect(ch)
+	c.refreshDuration.Collect(ch)
+	c.refreshErrors.Collect(ch)
+	c.dialDuration.Collect(ch)
+}
+
+// CacheHit implements dnscache.Metrics.
+func (c *Collector) CacheHit(host string) {
+	c.cacheHits.Inc()
+}
+
+// CacheMiss implements dnscache.Metrics.
+func (c *Collector) CacheMiss(host string) {
+	c.cacheMisses.Inc()
+}
+
+// LookupDone implements dnscache.Metrics. The histogram has only the result
+// label, since a label per host would add a time series for every host looked
+// up.
+func (c *Collector) LookupDone(host string, d time.Duration, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	c.lookupDuration.WithLabelValues(result).Observe(d.Seconds())
+}
+
+// RefreshDone implements dnscache.Metrics.
+func (c *Collector) RefreshDone(d time.Duration, failures int) {
+	c.refreshDuration.Observe(d.Seconds())
+	c.refreshErrors.Add(float64(failures))
+}
+
+// DialDone implements dnscache.DialMetrics. Like lookups, dials are labeled only
+// by their result.
+func (c *Collector) DialDone(host string, ip net.IP, d time.Duration, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	c.dialDuration.WithLabelValues(result).Observe(d.Seconds())
+}
@@ -0,0 +1,75 @@
+package promcollector
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dnscache "go.mercari.io/go-dnscache"
+)
+
+func TestCollector(t *testing.T) {
+	hosts := filepath.Join(t.TempDir(), "hosts")
+	if err := os.WriteFile(hosts, []byte("127.0.0.1 deeeet.com\n"), 0o644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	c := New("")
+	resolver, err := dnscache.New(time.Minute, time.Second, dnscache.WithHostsFile(hosts), dnscache.WithMetrics(c))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resolver.Stop()
+	c.Track(resolver)
+
+	for i := 0; i < 3; i++ {
+		if _, err := resolver.Fetch(context.Background(), "deeeet.com"); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	resolver.Refresh()
+
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(c)
+
+	want := `
+# HELP dnscache_cache_hits_total Number of fetches served from the DNS cache.
+# TYPE dnscache_cache_hits_total counter
+dnscache_cache_hits_total 2
+# HELP dnscache_cache_misses_total Number of fetches which had to look the host up.
+# TYPE dnscache_cache_misses_total counter
+dnscache_cache_misses_total 1
+# HELP dnscache_cache_size Number of hosts in the DNS cache.
+# TYPE dnscache_cache_size gauge
+dnscache_cache_size 1
+# HELP dnscache_refresh_errors_total Number of hosts which failed to be refreshed.
+# TYPE dnscache_refresh_errors_total counter
+dnscache_refresh_errors_total 0
+`
+	names := []string{"dnscache_cache_hits_total", "dnscache_cache_misses_total", "dnscache_cache_size", "dnscache_refresh_errors_total"}
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(want), names...); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if got := testutil.CollectAndCount(c, "dnscache_lookup_duration_seconds"); got != 1 {
+		t.Fatalf("got %d lookup histograms; want 1", got)
+	}
+	if got := testutil.CollectAndCount(c, "dnscache_refresh_duration_seconds"); got != 1 {
+		t.Fatalf("got %d refresh histograms; want 1", got)
+	}
+
+	dialer := dnscache.NewDialer(resolver, func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, errors.New("connection refused")
+	}, dnscache.WithDialStats())
+	dialer.DialContext(context.Background(), "tcp", "deeeet.com:443")
+	if got := testutil.CollectAndCount(c, "dnscache_dial_duration_seconds"); got != 1 {
+		t.Fatalf("got %d dial histograms; want 1", got)
+	}
+}
@@ -0,0 +1,74 @@
+package dnscache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// WithRefreshRateLimit limits the lookups of background refreshes to qps per
+// second with bursts of up to burst lookups by a token bucket, so that a resolver
+// caching thousands of hosts does not overwhelm a small upstream resolver.
+// Foreground lookups are not limited.
+func WithRefreshRateLimit(qps float64, burst int) Option {
+	return Option{apply: func(r *Resolver) {
+		if qps < 0 || burst < 0 {
+			r.invalid("WithRefreshRateLimit", "negative rate or burst")
+			return
+		}
+		if qps > 0 {
+			if burst < 1 {
+				burst = 1
+			}
+			r.refreshLimit = &tokenBucket{rate: qps, burst: float64(burst), tokens: float64(burst)}
+		}
+	}}
+}
+
+// tokenBucket is a token bucket rate limiter.
+type tokenBucket struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// reserve takes a token and returns how long to wait until it is available.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// wait blocks until a token is available at now, waiting on after, or ctx is
+// done. The token is not given back when ctx is done.
+func (b *tokenBucket) wait(ctx context.Context, now time.Time, after func(time.Duration) <-chan time.Time) error {
+	if b == nil {
+		return nil
+	}
+
+	d := b.reserve(now)
+	if d <= 0 {
+		return nil
+	}
+	select {
+	case <-after(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
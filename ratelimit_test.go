@@ -0,0 +1,58 @@
+package dnscache
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	b := &tokenBucket{rate: 10, burst: 2, tokens: 2}
+	now := time.Unix(0, 0)
+
+	if d := b.reserve(now); d != 0 {
+		t.Fatalf("got %v; want 0 within the burst", d)
+	}
+	if d := b.reserve(now); d != 0 {
+		t.Fatalf("got %v; want 0 within the burst", d)
+	}
+	if d := b.reserve(now); d != 100*time.Millisecond {
+		t.Fatalf("got %v; want 100ms", d)
+	}
+
+	// The tokens are refilled at the rate up to the burst.
+	now = now.Add(time.Hour)
+	for i := 0; i < 2; i++ {
+		if d := b.reserve(now); d != 0 {
+			t.Fatalf("got %v; want 0 after refilled", d)
+		}
+	}
+}
+
+func TestRefreshRateLimit(t *testing.T) {
+	r := &Resolver{
+		cache: map[string]*entry{
+			"a.deeeet.com": {ips: []net.IP{net.ParseIP("127.0.0.1")}},
+			"b.deeeet.com": {ips: []net.IP{net.ParseIP("127.0.0.1")}},
+			"c.deeeet.com": {ips: []net.IP{net.ParseIP("127.0.0.1")}},
+		},
+		lookupIPFn: func(ctx context.Context, network, host string) ([]net.IP, error) {
+			return []net.IP{net.ParseIP("127.0.0.1")}, nil
+		},
+		defaultLookupTimeout: time.Second,
+	}
+	WithRefreshRateLimit(20, 1).apply(r)
+
+	start := time.Now()
+	r.Refresh()
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Fatalf("got %v; want the refresh to be limited to 20 qps", elapsed)
+	}
+
+	ctx, cancelF := context.WithCancel(context.Background())
+	cancelF()
+	if err := r.refreshLimit.wait(ctx, time.Now(), time.After); err == nil {
+		t.Fatalf("expect to be cancelled")
+	}
+}
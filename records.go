@@ -0,0 +1,257 @@
+package dnscache
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+	"time"
+)
+
+// maxRecordSets is the maximum number of cached record sets other than IPs.
+const maxRecordSets = 1024
+
+// recordKey identifies a cached record set by the record type and the name
+// looked up.
+type recordKey struct {
+	typ  uint16
+	name string
+}
+
+// String returns the key as it is used for the refresh backoff, which is shared
+// with hosts, e.g. "MX example.com".
+func (k recordKey) String() string {
+	return recordTypeNames[k.typ] + " " + k.name
+}
+
+// recordTypeNames are the names of the record types cached as record sets.
+var recordTypeNames = map[uint16]string{
+	typeMX:  "MX",
+	typeTXT: "TXT",
+	typeSRV: "SRV",
+}
+
+// recordSet is a cached record set other than IPs. It is replaced, never
+// modified, once it is cached.
+type recordSet struct {
+	mx      []*net.MX
+	srv     []*net.SRV
+	txt     []string
+	updated time.Time
+}
+
+// cachedRecords returns the cached record set of key.
+func (r *Resolver) cachedRecords(key recordKey) (*recordSet, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	rs, ok := r.records[key]
+	return rs, ok
+}
+
+// fetchRecords returns the cached record set of key, or looks it up and caches
+// it if it is not cached.
+func (r *Resolver) fetchRecords(ctx context.Context, key recordKey) (*recordSet, error) {
+	if rs, ok := r.cachedRecords(key); ok {
+		return rs, nil
+	}
+	return r.lookupRecords(ctx, key)
+}
+
+// lookupRecords looks up the record set of key and saves it in the cache, which
+// holds up to maxRecordSets sets.
+func (r *Resolver) lookupRecords(ctx context.Context, key recordKey) (*recordSet, error) {
+	rs, err := r.lookupRecordsUpstream(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	rs.updated = r.now()
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.records == nil {
+		r.records = make(map[recordKey]*recordSet)
+	}
+	if _, ok := r.records[key]; !ok {
+		makeRoom(r.records, maxRecordSets, nil)
+	}
+	r.records[key] = rs
+	return rs, nil
+}
+
+// lookupRecordsUpstream looks up the record set of key by the nameserver if
+// configured or read from resolv.conf, or by the system resolver otherwise.
+func (r *Resolver) lookupRecordsUpstream(ctx context.Context, key recordKey) (*recordSet, error) {
+	ns := r.nameserver
+	if ns == nil {
+		ns = r.resolvConfNameserver.Load()
+	}
+
+	switch key.typ {
+	case typeMX:
+		if ns == nil {
+			mxs, err := lookupMX(ctx, key.name)
+			if err != nil {
+				return nil, err
+			}
+			return &recordSet{mx: mxs}, nil
+		}
+		answers, err := ns.records(ctx, key.name, typeMX)
+		if err != nil {
+			return nil, err
+		}
+		rs := &recordSet{}
+		for _, rr := range answers {
+			if len(rr.Data) >= 2 {
+				rs.mx = append(rs.mx, &net.MX{Host: rr.Target(), Pref: binary.BigEndian.Uint16(rr.Data)})
+			}
+		}
+		return rs, nil
+	case typeSRV:
+		if ns == nil {
+			// Empty service and proto make name looked up as it is.
+			_, srvs, err := lookupSRV(ctx, "", "", key.name)
+			if err != nil {
+				return nil, err
+			}
+			return &recordSet{srv: srvs}, nil
+		}
+		answers, err := ns.records(ctx, key.name, typeSRV)
+		if err != nil {
+			return nil, err
+		}
+		rs := &recordSet{}
+		for _, rr := range answers {
+			if len(rr.Data) >= 6 {
+				rs.srv = append(rs.srv, &net.SRV{
+					Target:   rr.Target(),
+					Port:     binary.BigEndian.Uint16(rr.Data[4:]),
+					Priority: binary.BigEndian.Uint16(rr.Data),
+					Weight:   binary.BigEndian.Uint16(rr.Data[2:]),
+				})
+			}
+		}
+		return rs, nil
+	case typeTXT:
+		rs := &recordSet{}
+		var err error
+		if ns == nil {
+			rs.txt, err = lookupTXT(ctx, key.name)
+		} else {
+			var answers []Record
+			answers, err = ns.records(ctx, key.name, typeTXT)
+			for _, rr := range answers {
+				rs.txt = append(rs.txt, strings.Join(rr.Text(), ""))
+			}
+		}
+		var dnsErr *net.DNSError
+		if err != nil && !(errors.As(err, &dnsErr) && dnsErr.IsNotFound) {
+			return nil, err
+		}
+		// A name without TXT records is cached as an empty set, since TXT records
+		// are often optional, e.g. for MongoSeedList.
+		return rs, nil
+	default:
+		panic("dnscache: unsupported record type " + key.String())
+	}
+}
+
+// records looks up the records of the given type for name and returns those in
+// the answer section. It fails with a not found error when there are none, like
+// the system resolver does.
+func (ns *nameserver) records(ctx context.Context, name string, qtype uint16) ([]Record, error) {
+	m, err := ns.query(ctx, name, qtype)
+	if err != nil {
+		return nil, err
+	}
+
+	var answers []Record
+	for _, rr := range toRecords(m.answers) {
+		if rr.Type == RRType(qtype) && rr.Class == classINET {
+			answers = append(answers, rr)
+		}
+	}
+	if len(answers) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: name, Server: ns.addr, IsNotFound: true}
+	}
+	return answers, nil
+}
+
+// refreshRecords refreshes the cached record sets like the IPs of hosts: when
+// scheduled, sets which are not due or are backing off are skipped, and every
+// lookup waits for the refresh rate limit. Failures are counted in summary.
+func (r *Resolver) refreshRecords(ctx context.Context, scheduled bool, summary *RefreshSummary) {
+	now := r.now()
+	r.lock.RLock()
+	keys := make([]recordKey, 0, len(r.records))
+	for key, rs := range r.records {
+		if scheduled && (!r.dueSince(key.name, rs.updated, 0, now) || !r.backoff.allow(key.String(), now)) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	r.lock.RUnlock()
+
+	for _, key := range keys {
+		if ctx.Err() != nil || r.isStopped() || r.refreshLimit.wait(ctx, r.now(), r.after) != nil {
+			return
+		}
+
+		lookupCtx, cancelF := context.WithTimeout(ctx, r.defaultLookupTimeout)
+		_, err := r.lookupRecords(lookupCtx, key)
+		cancelF()
+		if err == nil {
+			r.backoff.reset(key.String())
+			r.errorLog.reset(key.String())
+			continue
+		}
+		if ctx.Err() != nil || r.isStopped() {
+			return
+		}
+
+		summary.Failures++
+		r.backoff.fail(key.String(), r.now())
+		r.logRefreshError(key.String(), err)
+		if r.onRefreshError != nil {
+			r.onRefreshError(key.name, &RefreshError{Host: key.name, Err: err})
+		}
+	}
+}
+
+// removeRecords removes the cached record sets of name and their refresh state.
+func (r *Resolver) removeRecords(name string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for key := range r.records {
+		if key.name == name {
+			delete(r.records, key)
+			r.backoff.reset(key.String())
+			r.errorLog.reset(key.String())
+		}
+	}
+}
+
+// fetchSRV fetches the SRV records of `_service._proto.name` from the cache, or
+// looks them up and caches them like FetchMX does. When service and proto are
+// empty, name is looked up directly.
+func (r *Resolver) fetchSRV(ctx context.Context, service, proto, name string) ([]*net.SRV, error) {
+	if service != "" || proto != "" {
+		name = "_" + service + "._" + proto + "." + name
+	}
+	rs, err := r.fetchRecords(ctx, recordKey{typ: typeSRV, name: normalizeHost(name)})
+	if err != nil {
+		return nil, err
+	}
+	return rs.srv, nil
+}
+
+// fetchTXT fetches the TXT records of name from the cache, or looks them up and
+// caches them like FetchMX does. The strings of each record are joined. A name
+// without TXT records has none rather than an error.
+func (r *Resolver) fetchTXT(ctx context.Context, name string) ([]string, error) {
+	rs, err := r.fetchRecords(ctx, recordKey{typ: typeTXT, name: normalizeHost(name)})
+	if err != nil {
+		return nil, err
+	}
+	return rs.txt, nil
+}
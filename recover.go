@@ -0,0 +1,47 @@
+package dnscache
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+)
+
+// WithRefreshPanicHandler sets a function called with the host and the
+// recovered value when a background refresh panics, e.g. in a custom lookup
+// function, in addition to logging it with the stack. host is empty when the
+// panic is not of a lookup, e.g. in a refresh listener. Such panics are always
+// recovered so that the refresher keeps running, and a panicking lookup fails
+// as the refresh failure of the host.
+func WithRefreshPanicHandler(handler func(host string, recovered any)) Option {
+	return Option{apply: func(r *Resolver) {
+		r.onRefreshPanic = handler
+	}}
+}
+
+// recoverRefresh logs the value recovered from a panic of the refresh of host
+// with the stack of the panic and passes it to the handler.
+func (r *Resolver) recoverRefresh(host string, recovered any, stack []byte) {
+	r.logger.Error("recovered panic in DNS cache refresh",
+		"panic", recovered,
+		"addr", host,
+		"stack", string(stack),
+	)
+	if r.onRefreshPanic != nil {
+		r.onRefreshPanic(host, recovered)
+	}
+}
+
+// panicError is the error of a lookup which panicked.
+func panicError(host string, recovered any) error {
+	return fmt.Errorf("dnscache: lookup of %s panicked: %v", host, recovered)
+}
+
+// scheduledRefresh runs a refresh cycle of the ticker, recovering from panics.
+func (r *Resolver) scheduledRefresh(onRefreshedFn func(RefreshSummary)) {
+	defer func() {
+		if v := recover(); v != nil {
+			r.recoverRefresh("", v, debug.Stack())
+		}
+	}()
+	onRefreshedFn(r.refresh(context.Background(), true))
+}
@@ -0,0 +1,68 @@
+package dnscache
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRefreshPanic(t *testing.T) {
+	originalFunc := lookupIP
+	defer func() {
+		lookupIP = originalFunc
+	}()
+
+	var panicking atomic.Bool
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		if panicking.Load() {
+			panic("lookup panic")
+		}
+		return []net.IP{net.ParseIP("127.0.0.1")}, nil
+	}
+
+	clock := newFakeClock()
+	recovered := make(chan string, 2)
+	r, err := New(time.Minute, time.Second,
+		WithClock(clock),
+		WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		WithRefreshPanicHandler(func(host string, v any) {
+			recovered <- host
+		}),
+		WithRefreshListener(func(s RefreshSummary) {
+			if s.Failures > 0 {
+				panic("listener panic")
+			}
+		}),
+	)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer r.Stop()
+
+	if _, err := r.Fetch(context.Background(), "deeeet.com"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Both the panic of the lookup and the one of the listener are recovered.
+	panicking.Store(true)
+	clock.Advance(time.Minute)
+	if host := <-recovered; host != "deeeet.com" {
+		t.Fatalf("got %q; want the panic of the lookup", host)
+	}
+	if host := <-recovered; host != "" {
+		t.Fatalf("got %q; want the panic of the listener", host)
+	}
+	if e, ok := r.cached("deeeet.com"); !ok || len(e.ips) != 1 {
+		t.Fatalf("expect the cached entry to be kept")
+	}
+
+	// The refresher keeps running.
+	clock.Advance(time.Minute)
+	if host := <-recovered; host != "deeeet.com" {
+		t.Fatalf("got %q; want the panic of the lookup", host)
+	}
+}
@@ -0,0 +1,50 @@
+package dnscache
+
+import "sync"
+
+var (
+	defaultOnce     sync.Once
+	defaultResolver *Resolver
+
+	registryMu sync.RWMutex
+	registry   = make(map[string]*Resolver)
+)
+
+// Default returns the resolver shared by the process, which is created with the
+// default refresh frequency and lookup timeout on the first call, so that
+// libraries can share one cache instead of each constructing their own. It is
+// never stopped and must not be stopped by its users.
+func Default() *Resolver {
+	defaultOnce.Do(func() {
+		r, err := New(defaultFreq, defaultLookupTimeout, WithName("default"))
+		if err != nil {
+			panic("dnscache: failed to create the default resolver: " + err.Error())
+		}
+		defaultResolver = r
+	})
+	return defaultResolver
+}
+
+// Register makes the resolver available by the name to Get, e.g. to share a
+// resolver configured by the main package with libraries. It panics if the name
+// is already registered or r is nil, like `expvar.Publish`.
+func Register(name string, r *Resolver) {
+	if r == nil {
+		panic("dnscache: Register of nil resolver for " + name)
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := registry[name]; dup {
+		panic("dnscache: Register called twice for " + name)
+	}
+	registry[name] = r
+}
+
+// Get returns the resolver registered by Register with the name, or nil if none
+// is registered.
+func Get(name string) *Resolver {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return registry[name]
+}
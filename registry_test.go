@@ -0,0 +1,44 @@
+package dnscache
+
+import "testing"
+
+func TestDefault(t *testing.T) {
+	r := Default()
+	if r == nil {
+		t.Fatalf("expect the default resolver")
+	}
+	if got := Default(); got != r {
+		t.Fatalf("expect the same resolver to be shared")
+	}
+	if err := r.Healthy(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	r := testResolver(t)
+	defer r.Stop()
+
+	Register("test-register", r)
+	defer func() {
+		registryMu.Lock()
+		delete(registry, "test-register")
+		registryMu.Unlock()
+	}()
+
+	if got := Get("test-register"); got != r {
+		t.Fatalf("got %p; want %p", got, r)
+	}
+	if got := Get("unknown"); got != nil {
+		t.Fatalf("got %p; want nil", got)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("expect to panic on duplicate registration")
+			}
+		}()
+		Register("test-register", r)
+	}()
+}
@@ -0,0 +1,52 @@
+package dnscache
+
+import (
+	"net"
+	"sort"
+)
+
+// reverseIndex maps IPs to the set of hosts resolving to them.
+type reverseIndex map[string]map[string]struct{}
+
+func (idx reverseIndex) add(host string, ips []net.IP) {
+	for _, ip := range ips {
+		key := ip.String()
+		hosts, ok := idx[key]
+		if !ok {
+			hosts = make(map[string]struct{})
+			idx[key] = hosts
+		}
+		hosts[host] = struct{}{}
+	}
+}
+
+func (idx reverseIndex) remove(host string, ips []net.IP) {
+	for _, ip := range ips {
+		key := ip.String()
+		hosts := idx[key]
+		delete(hosts, host)
+		if len(hosts) == 0 {
+			delete(idx, key)
+		}
+	}
+}
+
+// HostsForIP returns the sorted list of cached hosts which currently resolve to
+// the given IP, without querying DNS. It requires WithReverseIndex and returns
+// nil otherwise.
+func (r *Resolver) HostsForIP(ip net.IP) []string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	hosts := r.reverse[ip.String()]
+	if len(hosts) == 0 {
+		return nil
+	}
+
+	list := make([]string, 0, len(hosts))
+	for host := range hosts {
+		list = append(list, host)
+	}
+	sort.Strings(list)
+	return list
+}
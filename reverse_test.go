@@ -0,0 +1,69 @@
+package dnscache
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestHostsForIP(t *testing.T) {
+	originalFunc := lookupIP
+	defer func() {
+		lookupIP = originalFunc
+	}()
+
+	mu := new(sync.Mutex)
+	records := map[string][]net.IP{
+		"a.mercari.io": {net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2")},
+		"b.mercari.io": {net.ParseIP("10.0.0.2")},
+	}
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return records[host], nil
+	}
+
+	resolver, err := New(testFreq, testDefaultLookupTimeout,
+		WithReverseIndex(),
+		WithStaticEntries(map[string][]net.IP{"pinned.mercari.io": {net.ParseIP("10.0.0.9")}}),
+	)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resolver.Stop()
+
+	hosts := []string{"a.mercari.io", "b.mercari.io"}
+	if _, err := resolver.LookupIPs(context.Background(), hosts); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cases := []struct {
+		ip   string
+		want []string
+	}{
+		{"10.0.0.1", []string{"a.mercari.io"}},
+		{"10.0.0.2", []string{"a.mercari.io", "b.mercari.io"}},
+		{"10.0.0.9", []string{"pinned.mercari.io"}},
+		{"10.0.0.3", nil},
+	}
+	for _, tc := range cases {
+		if got := resolver.HostsForIP(net.ParseIP(tc.ip)); !reflect.DeepEqual(tc.want, got) {
+			t.Fatalf("%s: want %v, got %v", tc.ip, tc.want, got)
+		}
+	}
+
+	// Refreshed entries replace the old IPs in the index.
+	mu.Lock()
+	records["a.mercari.io"] = []net.IP{net.ParseIP("10.0.0.3")}
+	mu.Unlock()
+	resolver.Refresh()
+
+	if got := resolver.HostsForIP(net.ParseIP("10.0.0.1")); got != nil {
+		t.Fatalf("expect old IP to be removed, got %v", got)
+	}
+	if got, want := resolver.HostsForIP(net.ParseIP("10.0.0.3")), []string{"a.mercari.io"}; !reflect.DeepEqual(want, got) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+}
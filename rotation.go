@@ -0,0 +1,42 @@
+package dnscache
+
+import "net"
+
+// Rotation controls the order of IPs returned by Fetch.
+type Rotation int
+
+const (
+	// NoRotation returns IPs in the cached order.
+	NoRotation Rotation = iota
+
+	// RotateRoundRobin rotates the IP list by one for each Fetch call of the host.
+	RotateRoundRobin
+
+	// RotateShuffle returns the IP list in random order for each Fetch call.
+	RotateShuffle
+)
+
+// rotate returns the IPs of the entry ordered according to the rotation. The
+// cached slice is never modified.
+func (r *Resolver) rotate(e *entry) []net.IP {
+	n := len(e.ips)
+	if n < 2 {
+		return e.ips
+	}
+
+	switch r.rotation {
+	case RotateRoundRobin:
+		start := int((e.rotation.Add(1) - 1) % uint32(n))
+		ips := make([]net.IP, 0, n)
+		ips = append(ips, e.ips[start:]...)
+		return append(ips, e.ips[:start]...)
+	case RotateShuffle:
+		ips := make([]net.IP, n)
+		for i, randomIndex := range randPerm(n) {
+			ips[i] = e.ips[randomIndex]
+		}
+		return ips
+	default:
+		return e.ips
+	}
+}
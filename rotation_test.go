@@ -0,0 +1,65 @@
+package dnscache
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestRotateRoundRobin(t *testing.T) {
+	var (
+		ip1 = net.ParseIP("10.0.0.1")
+		ip2 = net.ParseIP("10.0.0.2")
+		ip3 = net.ParseIP("10.0.0.3")
+	)
+	resolver, err := New(testFreq, testDefaultLookupTimeout,
+		WithRotation(RotateRoundRobin),
+		WithStaticEntries(map[string][]net.IP{"rr.mercari.io": {ip1, ip2, ip3}}),
+	)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resolver.Stop()
+
+	wants := [][]net.IP{
+		{ip1, ip2, ip3},
+		{ip2, ip3, ip1},
+		{ip3, ip1, ip2},
+		{ip1, ip2, ip3},
+	}
+	for _, want := range wants {
+		got, err := resolver.Fetch(context.Background(), "rr.mercari.io")
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Fatalf("want %v, got %v", want, got)
+		}
+	}
+}
+
+func TestRotateShuffle(t *testing.T) {
+	origFunc := randPerm
+	defer func() {
+		randPerm = origFunc
+	}()
+	randPerm = func(n int) []int {
+		return []int{2, 0, 1}
+	}
+
+	var (
+		ip1 = net.ParseIP("10.0.0.1")
+		ip2 = net.ParseIP("10.0.0.2")
+		ip3 = net.ParseIP("10.0.0.3")
+	)
+	resolver := &Resolver{rotation: RotateShuffle}
+	e := &entry{ips: []net.IP{ip1, ip2, ip3}}
+
+	if got, want := resolver.rotate(e), []net.IP{ip3, ip1, ip2}; !reflect.DeepEqual(want, got) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+	if got, want := e.ips, []net.IP{ip1, ip2, ip3}; !reflect.DeepEqual(want, got) {
+		t.Fatalf("expect cached IPs not to be modified, got %v", got)
+	}
+}
@@ -0,0 +1,92 @@
+package dnscache
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+)
+
+// Resolution is the metadata of how the host of a request was resolved by
+// the RoundTripper.
+type Resolution struct {
+	// Host is the host of the request.
+	Host string
+
+	// IPs is the IP list of the host fetched from the cache.
+	IPs []net.IP
+
+	// CacheHit reports whether IPs were already cached before the request.
+	CacheHit bool
+
+	// IP is the remote IP of the connection the request was sent on. It is nil
+	// if no connection was obtained.
+	IP net.IP
+}
+
+type resolutionKey struct{}
+
+// ResolutionFromContext returns the Resolution recorded by the RoundTripper. Use
+// the context of `http.Response.Request` to get it after the round trip.
+func ResolutionFromContext(ctx context.Context) (*Resolution, bool) {
+	res, ok := ctx.Value(resolutionKey{}).(*Resolution)
+	return res, ok
+}
+
+type roundTripper struct {
+	resolver *Resolver
+	base     http.RoundTripper
+}
+
+// RoundTripper returns a `http.RoundTripper` which resolves the host of each request
+// via the cache of the resolver before passing it to the base round tripper. The
+// Resolution of the request, the fetched IPs, whether they were cached and the IP
+// of the used connection, is recorded into the request context and can be read
+// by ResolutionFromContext for logging. If base is nil, the transport returned
+// by NewTransport is used.
+//
+// The base round tripper is expected to dial with the resolver, e.g. the one
+// returned by NewTransport, so that it uses the same cached IPs.
+func RoundTripper(resolver *Resolver, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = NewTransport(resolver)
+	}
+	return &roundTripper{resolver: resolver, base: base}
+}
+
+func (rt *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Hostname()
+	res := &Resolution{Host: host}
+	if ip := net.ParseIP(host); ip != nil {
+		res.IPs = []net.IP{ip}
+	} else {
+		res.CacheHit = rt.resolver.isCached(host)
+		ips, err := rt.resolver.Fetch(req.Context(), host)
+		if err != nil {
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			return nil, err
+		}
+		res.IPs = ips
+	}
+
+	ctx := context.WithValue(req.Context(), resolutionKey{}, res)
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if addr, ok := info.Conn.RemoteAddr().(*net.TCPAddr); ok {
+				res.IP = addr.IP
+			}
+		},
+	})
+	return rt.base.RoundTrip(req.WithContext(ctx))
+}
+
+// isCached reports whether the IP list of addr is in the cache or static entries.
+func (r *Resolver) isCached(addr string) bool {
+	if _, ok := r.static[addr]; ok {
+		return true
+	}
+	e, ok := r.cached(addr)
+	return ok && r.fresh(e)
+}
@@ -0,0 +1,108 @@
+package dnscache
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRoundTripper(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	resolver := &Resolver{
+		cache: map[string]*entry{
+			"deeeet.com": {ips: []net.IP{net.ParseIP("127.0.0.1")}},
+		},
+		lookupTimeout: time.Second,
+	}
+
+	client := &http.Client{Transport: RoundTripper(resolver, NewTransport(resolver))}
+	resp, err := client.Get("http://" + net.JoinHostPort("deeeet.com", port))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+
+	res, ok := ResolutionFromContext(resp.Request.Context())
+	if !ok {
+		t.Fatalf("expect resolution to be recorded")
+	}
+	if res.Host != "deeeet.com" || !res.CacheHit || len(res.IPs) != 1 {
+		t.Fatalf("unexpected resolution: %+v", res)
+	}
+	if !res.IP.Equal(net.ParseIP("127.0.0.1")) {
+		t.Fatalf("want 127.0.0.1, got %v", res.IP)
+	}
+}
+
+func TestRoundTripperNilBase(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	resolver := &Resolver{
+		cache: map[string]*entry{
+			"deeeet.com": {ips: []net.IP{net.ParseIP("127.0.0.1")}},
+		},
+		lookupTimeout: time.Second,
+	}
+
+	// The request reaches the server only if the default base dials the cached IPs.
+	client := &http.Client{Transport: RoundTripper(resolver, nil)}
+	resp, err := client.Get("http://" + net.JoinHostPort("deeeet.com", port))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+
+	res, _ := ResolutionFromContext(resp.Request.Context())
+	if res == nil || res.IP == nil {
+		t.Fatalf("expect the IP of the connection to be recorded: %+v", res)
+	}
+	found := false
+	for _, ip := range res.IPs {
+		found = found || ip.Equal(res.IP)
+	}
+	if !found {
+		t.Fatalf("got %v; want one of %v", res.IP, res.IPs)
+	}
+}
+
+func TestRoundTripperCacheMiss(t *testing.T) {
+	originalFunc := lookupIP
+	defer func() {
+		lookupIP = originalFunc
+	}()
+
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("127.0.0.1")}, nil
+	}
+
+	resolver := testResolver(t)
+	defer resolver.Stop()
+
+	var res *Resolution
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		res, _ = ResolutionFromContext(req.Context())
+		return &http.Response{StatusCode: http.StatusOK, Request: req}, nil
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, "http://deeeet.com/", nil)
+	if _, err := RoundTripper(resolver, base).RoundTrip(req); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if res == nil || res.CacheHit || len(res.IPs) != 1 {
+		t.Fatalf("unexpected resolution: %+v", res)
+	}
+}
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,163 @@
+package dnscache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// defaultResolvConf is the path of the system resolver configuration.
+	defaultResolvConf = "/etc/resolv.conf"
+
+	// defaultNdots is the default ndots of resolv.conf.
+	defaultNdots = 1
+)
+
+// resolvConf is the part of a resolv.conf(5) file used by the resolver.
+type resolvConf struct {
+	nameservers []string
+	search      []string
+	ndots       int
+}
+
+// readResolvConf reads and parses the resolv.conf file at the given path.
+func readResolvConf(path string) (*resolvConf, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseResolvConf(data), nil
+}
+
+// reloadResolvConf reads the resolv.conf file of WithResolvConf if it has been
+// modified since the last read. When it changes, the names which unqualified
+// names resolved to are forgotten, so that they are looked up again with the new
+// search domains. Cached entries of the names are kept. The nameserver client is
+// swapped when the first nameserver changes, so that in-flight lookups finish
+// with the old one.
+func (r *Resolver) reloadResolvConf() error {
+	fi, err := os.Stat(r.resolvConfPath)
+	if err != nil {
+		return err
+	}
+
+	r.lock.RLock()
+	unchanged := !r.resolvConfModTime.IsZero() && fi.ModTime().Equal(r.resolvConfModTime)
+	r.lock.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	conf, err := readResolvConf(r.resolvConfPath)
+	if err != nil {
+		return err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if !r.resolvConfModTime.IsZero() && (r.ndots != conf.ndots || !slices.Equal(r.search, conf.search)) {
+		r.aliases = make(map[string]string)
+	}
+	r.search, r.ndots = conf.search, conf.ndots
+	r.resolvConfModTime = fi.ModTime()
+
+	switch old := r.resolvConfNameserver.Load(); {
+	case len(conf.nameservers) == 0:
+		r.resolvConfNameserver.Store(nil)
+	case old == nil || old.addr != newNameserver(conf.nameservers[0]).addr:
+		r.resolvConfNameserver.Store(newNameserver(conf.nameservers[0]))
+	}
+	return nil
+}
+
+// parseResolvConf parses resolv.conf data. Like the system resolver, the last
+// of `search` and `domain` lines wins.
+func parseResolvConf(data []byte) *resolvConf {
+	conf := &resolvConf{ndots: defaultNdots}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexAny(line, "#;"); i >= 0 {
+			line = line[:i]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		switch fields[0] {
+		case "nameserver":
+			conf.nameservers = append(conf.nameservers, fields[1])
+		case "search":
+			conf.search = fields[1:]
+		case "domain":
+			conf.search = fields[1:2]
+		case "options":
+			for _, opt := range fields[1:] {
+				if v, ok := strings.CutPrefix(opt, "ndots:"); ok {
+					if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+						conf.ndots = min(n, 15)
+					}
+				}
+			}
+		}
+	}
+	return conf
+}
+
+// searchNames returns the names to try in order for addr. Names with fewer dots
+// than ndots are tried with the search domains first, then as they are.
+// Other names are tried as they are first. Absolute names ending with a dot
+// are never expanded.
+func (r *Resolver) searchNames(addr string) []string {
+	r.lock.RLock()
+	search, ndots := r.search, r.ndots
+	r.lock.RUnlock()
+	if len(search) == 0 || strings.HasSuffix(addr, ".") {
+		return []string{addr}
+	}
+
+	names := make([]string, 0, len(search)+1)
+	if strings.Count(addr, ".") >= ndots {
+		names = append(names, addr)
+	}
+	for _, domain := range search {
+		names = append(names, addr+"."+strings.Trim(domain, "."))
+	}
+	if strings.Count(addr, ".") < ndots {
+		names = append(names, addr)
+	}
+	return names
+}
+
+// lookupSearch looks up the search names of addr in order until one exists and
+// remembers it so that Fetch of addr is served from the cache entry of that name.
+func (r *Resolver) lookupSearch(ctx context.Context, addr string) ([]net.IP, error) {
+	var lastErr error
+	for _, name := range r.searchNames(addr) {
+		ips, err := r.lookupIP(ctx, name)
+		if err == nil {
+			if name != addr {
+				r.lock.Lock()
+				r.aliases[addr] = name
+				r.lock.Unlock()
+			}
+			return ips, nil
+		}
+
+		lastErr = err
+		var dnsErr *net.DNSError
+		if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+			break
+		}
+	}
+	return nil, lastErr
+}
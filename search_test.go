@@ -0,0 +1,221 @@
+package dnscache
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseResolvConf(t *testing.T) {
+	data := []byte(`# generated by kubelet
+nameserver 10.96.0.10
+domain example.com
+search prod.svc.cluster.local svc.cluster.local cluster.local
+options ndots:5 timeout:2
+`)
+
+	got := parseResolvConf(data)
+	want := &resolvConf{
+		nameservers: []string{"10.96.0.10"},
+		search:      []string{"prod.svc.cluster.local", "svc.cluster.local", "cluster.local"},
+		ndots:       5,
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("want %#v, got %#v", want, got)
+	}
+}
+
+func TestSearchNames(t *testing.T) {
+	resolver := &Resolver{
+		search: []string{"svc.cluster.local", "cluster.local."},
+		ndots:  2,
+	}
+
+	cases := []struct {
+		addr string
+		want []string
+	}{
+		{"payments", []string{"payments.svc.cluster.local", "payments.cluster.local", "payments"}},
+		{"payments.prod", []string{"payments.prod.svc.cluster.local", "payments.prod.cluster.local", "payments.prod"}},
+		{"api.mercari.io", []string{"api.mercari.io", "api.mercari.io.svc.cluster.local", "api.mercari.io.cluster.local"}},
+		{"payments.", []string{"payments."}},
+	}
+
+	for _, tc := range cases {
+		if got := resolver.searchNames(tc.addr); !reflect.DeepEqual(tc.want, got) {
+			t.Fatalf("%s: want %v, got %v", tc.addr, tc.want, got)
+		}
+	}
+}
+
+func TestSearchDomains(t *testing.T) {
+	originalFunc := lookupIP
+	defer func() {
+		lookupIP = originalFunc
+	}()
+
+	want := []net.IP{
+		net.ParseIP("10.0.0.1"),
+	}
+	var calls int32
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		atomic.AddInt32(&calls, 1)
+		if host == "payments.prod.svc.cluster.local" {
+			return want, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+	conf := "search svc.cluster.local prod.svc.cluster.local\noptions ndots:5\n"
+	if err := os.WriteFile(path, []byte(conf), 0o644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	resolver, err := New(testFreq, testDefaultLookupTimeout, WithResolvConf(path))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resolver.Stop()
+
+	ctx := context.Background()
+	got, err := resolver.Fetch(ctx, "payments")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("want %#v, got %#v", want, got)
+	}
+
+	// Only the final FQDN is cached.
+	if got, want := len(resolver.cache), 1; got != want {
+		t.Fatalf("got %d cache entries, want %d", got, want)
+	}
+	if _, ok := resolver.cache["payments.prod.svc.cluster.local"]; !ok {
+		t.Fatalf("expect FQDN to be cached")
+	}
+
+	// Fetch of the short name is served from the cache.
+	before := atomic.LoadInt32(&calls)
+	if _, err := resolver.Fetch(ctx, "payments"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if got := atomic.LoadInt32(&calls); got != before {
+		t.Fatalf("expect cache to be used, got %d more lookups", got-before)
+	}
+
+	if _, err := resolver.Fetch(ctx, "unknown"); err == nil {
+		t.Fatalf("expect to be failed")
+	}
+}
+
+func TestWithResolvConf_reload(t *testing.T) {
+	originalFunc := lookupIP
+	defer func() {
+		lookupIP = originalFunc
+	}()
+
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		switch host {
+		case "payments.old.svc.cluster.local":
+			return []net.IP{net.ParseIP("10.0.0.1")}, nil
+		case "payments.new.svc.cluster.local":
+			return []net.IP{net.ParseIP("10.0.0.2")}, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+	if err := os.WriteFile(path, []byte("search old.svc.cluster.local\n"), 0o644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	resolver, err := New(testFreq, testDefaultLookupTimeout, WithResolvConf(path))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resolver.Stop()
+
+	ctx := context.Background()
+	got, err := resolver.Fetch(ctx, "payments")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if want := []net.IP{net.ParseIP("10.0.0.1")}; !reflect.DeepEqual(want, got) {
+		t.Fatalf("want %#v, got %#v", want, got)
+	}
+
+	if err := os.WriteFile(path, []byte("search new.svc.cluster.local\noptions ndots:2\n"), 0o644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	modTime := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resolver.Refresh()
+
+	got, err = resolver.Fetch(ctx, "payments")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if want := []net.IP{net.ParseIP("10.0.0.2")}; !reflect.DeepEqual(want, got) {
+		t.Fatalf("want %#v, got %#v", want, got)
+	}
+	if got := resolver.searchNames("a.b"); !reflect.DeepEqual(got, []string{"a.b.new.svc.cluster.local", "a.b"}) {
+		t.Fatalf("got %v; want the ndots to be reloaded", got)
+	}
+}
+
+func TestWithResolvConf_nameserver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+	if err := os.WriteFile(path, []byte("nameserver 10.96.0.10\nnameserver 10.96.0.11\n"), 0o644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	resolver, err := New(testFreq, testDefaultLookupTimeout, WithResolvConf(path))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resolver.Stop()
+
+	old := resolver.resolvConfNameserver.Load()
+	if old == nil || old.addr != "10.96.0.10:53" {
+		t.Fatalf("got %+v; want the first nameserver", old)
+	}
+
+	reload := func(conf string, d time.Duration) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(conf), 0o644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		modTime := time.Now().Add(d)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := resolver.reloadResolvConf(); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	// The client is kept while the first nameserver stays the same.
+	reload("nameserver 10.96.0.10\nsearch svc.cluster.local\n", time.Minute)
+	if got := resolver.resolvConfNameserver.Load(); got != old {
+		t.Fatalf("expect the client to be kept")
+	}
+
+	reload("nameserver fd00::10\n", 2*time.Minute)
+	if got := resolver.resolvConfNameserver.Load(); got == nil || got.addr != "[fd00::10]:53" {
+		t.Fatalf("got %+v; want the new nameserver", got)
+	}
+
+	// Without nameservers, the lookup function is used again.
+	reload("search svc.cluster.local\n", 3*time.Minute)
+	if got := resolver.resolvConfNameserver.Load(); got != nil {
+		t.Fatalf("got %+v; want no nameserver", got)
+	}
+}
@@ -0,0 +1,61 @@
+package dnscache
+
+import (
+	"runtime/debug"
+	"sync"
+)
+
+// call is an in-flight or completed lookup of a singleflight group.
+type call struct {
+	done  chan struct{}
+	entry *entry
+	err   error
+
+	// panicked is true when fn panicked with recovered, and stack is the stack of
+	// the panic. Every waiter handles the panic itself.
+	panicked  bool
+	recovered any
+	stack     []byte
+}
+
+// group deduplicates concurrent lookups for the same host so that only one of
+// them hits the upstream resolver. The zero value is ready to use.
+type group struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+// do executes fn for the given key unless a call for the same key is already in
+// flight; in that case it returns the channel of the in-flight call instead.
+// The returned call is complete when its done channel is closed. fn runs in its
+// own goroutine, so a panic of fn is recovered and recorded in the call.
+func (g *group) do(key string, fn func() (*entry, error)) *call {
+	g.mu.Lock()
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		return c
+	}
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+	c := &call{done: make(chan struct{})}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	go func() {
+		defer func() {
+			if v := recover(); v != nil {
+				c.entry, c.err = nil, nil
+				c.panicked, c.recovered, c.stack = true, v, debug.Stack()
+			}
+
+			g.mu.Lock()
+			delete(g.calls, key)
+			g.mu.Unlock()
+			close(c.done)
+		}()
+		c.entry, c.err = fn()
+	}()
+
+	return c
+}
@@ -0,0 +1,173 @@
+package dnscache
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// SOCKS5 protocol constants (RFC 1928 and RFC 1929).
+const (
+	socks5Version = 0x05
+
+	socks5AuthNone     = 0x00
+	socks5AuthPassword = 0x02
+	socks5AuthNoAccept = 0xff
+
+	socks5CmdConnect = 0x01
+
+	socks5AddrIPv4   = 0x01
+	socks5AddrDomain = 0x03
+	socks5AddrIPv6   = 0x04
+)
+
+var errSOCKS5Auth = errors.New("dnscache: SOCKS5 authentication failed")
+
+// socks5 is the configuration of the SOCKS5 proxy to dial through.
+type socks5 struct {
+	addr               string
+	username, password string
+
+	// remote sends the host to the proxy to resolve instead of the cached IPs.
+	remote bool
+}
+
+// dial wraps the dial function to dial the proxy and connect through it to the
+// dialed address.
+func (s *socks5) dial(baseDialFunc dialFunc) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := baseDialFunc(ctx, "tcp", s.addr)
+		if err != nil {
+			return nil, err
+		}
+
+		stop := setDeadline(ctx, conn)
+		err = s.connect(conn, addr)
+		if !stop() {
+			// ctx is done while connecting.
+			conn.Close()
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if err := conn.SetDeadline(time.Time{}); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return conn, nil
+	}
+}
+
+// connect negotiates the authentication and sends the CONNECT command to addr.
+func (s *socks5) connect(conn net.Conn, addr string) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return fmt.Errorf("dnscache: invalid port %q", portStr)
+	}
+
+	methods := []byte{socks5AuthNone}
+	if s.username != "" {
+		methods = append(methods, socks5AuthPassword)
+	}
+	greeting := append([]byte{socks5Version, byte(len(methods))}, methods...)
+	if _, err := conn.Write(greeting); err != nil {
+		return err
+	}
+
+	var reply [2]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return err
+	}
+	if reply[0] != socks5Version {
+		return fmt.Errorf("dnscache: unexpected SOCKS version %d", reply[0])
+	}
+	switch reply[1] {
+	case socks5AuthNone:
+	case socks5AuthPassword:
+		if err := s.authenticate(conn); err != nil {
+			return err
+		}
+	default:
+		return errSOCKS5Auth
+	}
+
+	req := []byte{socks5Version, socks5CmdConnect, 0}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			req = append(append(req, socks5AddrIPv4), ip4...)
+		} else {
+			req = append(append(req, socks5AddrIPv6), ip.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return fmt.Errorf("dnscache: too long host %q for SOCKS5", host)
+		}
+		req = append(append(req, socks5AddrDomain, byte(len(host))), host...)
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	var head [4]byte
+	if _, err := io.ReadFull(conn, head[:]); err != nil {
+		return err
+	}
+	if head[1] != 0 {
+		return fmt.Errorf("dnscache: SOCKS5 proxy failed to connect to %s: reply %d", addr, head[1])
+	}
+
+	// Discard the bound address.
+	var n int
+	switch head[3] {
+	case socks5AddrIPv4:
+		n = net.IPv4len
+	case socks5AddrIPv6:
+		n = net.IPv6len
+	case socks5AddrDomain:
+		var l [1]byte
+		if _, err := io.ReadFull(conn, l[:]); err != nil {
+			return err
+		}
+		n = int(l[0])
+	default:
+		return fmt.Errorf("dnscache: unexpected SOCKS5 address type %d", head[3])
+	}
+	_, err = io.ReadFull(conn, make([]byte, n+2))
+	return err
+}
+
+// authenticate performs the username/password authentication (RFC 1929).
+func (s *socks5) authenticate(conn net.Conn) error {
+	if len(s.username) > 255 || len(s.password) > 255 {
+		return errSOCKS5Auth
+	}
+
+	req := []byte{0x01, byte(len(s.username))}
+	req = append(req, s.username...)
+	req = append(req, byte(len(s.password)))
+	req = append(req, s.password...)
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	var reply [2]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return err
+	}
+	if reply[1] != 0 {
+		return errSOCKS5Auth
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package dnscache
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// testSOCKS5Server starts a SOCKS5 proxy which requires the given credentials
+// when user is not empty, and returns its address. The CONNECT destinations are
+// sent to dests and connections to refused are rejected.
+func testSOCKS5Server(t *testing.T, user, pass, refused string, dests chan<- string) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	handle := func(conn net.Conn) {
+		defer conn.Close()
+
+		var head [2]byte
+		if _, err := io.ReadFull(conn, head[:]); err != nil {
+			return
+		}
+		methods := make([]byte, head[1])
+		io.ReadFull(conn, methods)
+
+		if user == "" {
+			conn.Write([]byte{5, socks5AuthNone})
+		} else {
+			conn.Write([]byte{5, socks5AuthPassword})
+			var l [2]byte
+			io.ReadFull(conn, l[:])
+			u := make([]byte, l[1])
+			io.ReadFull(conn, u)
+			io.ReadFull(conn, l[:1])
+			p := make([]byte, l[0])
+			io.ReadFull(conn, p)
+			if string(u) != user || string(p) != pass {
+				conn.Write([]byte{1, 1})
+				return
+			}
+			conn.Write([]byte{1, 0})
+		}
+
+		var req [4]byte
+		if _, err := io.ReadFull(conn, req[:]); err != nil {
+			return
+		}
+		var host string
+		switch req[3] {
+		case socks5AddrIPv4:
+			b := make([]byte, 4)
+			io.ReadFull(conn, b)
+			host = net.IP(b).String()
+		case socks5AddrIPv6:
+			b := make([]byte, 16)
+			io.ReadFull(conn, b)
+			host = net.IP(b).String()
+		case socks5AddrDomain:
+			var l [1]byte
+			io.ReadFull(conn, l[:])
+			b := make([]byte, l[0])
+			io.ReadFull(conn, b)
+			host = string(b)
+		}
+		var port [2]byte
+		io.ReadFull(conn, port[:])
+		dest := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port[:]))))
+		dests <- dest
+
+		rep := byte(0)
+		if dest == refused {
+			rep = 5
+		}
+		conn.Write([]byte{5, rep, 0, socks5AddrIPv4, 127, 0, 0, 1, 0, 0})
+		io.Copy(io.Discard, conn)
+	}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go handle(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestDialerSOCKS5(t *testing.T) {
+	dests := make(chan string, 10)
+	proxy := testSOCKS5Server(t, "user", "pass", "10.0.0.1:443", dests)
+
+	resolver := &Resolver{
+		cache: map[string]*entry{
+			"deeeet.com": {ips: []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2")}},
+		},
+		lookupTimeout: time.Second,
+	}
+
+	// The proxy refuses the first IP and the second one is connected.
+	d := NewDialer(resolver, nil, WithSelector(firstSelector{}), WithSOCKS5(proxy, "user", "pass", false))
+	conn, err := d.DialContext(context.Background(), "tcp", "deeeet.com:443")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	conn.Close()
+
+	if got := []string{<-dests, <-dests}; got[0] != "10.0.0.1:443" || got[1] != "10.0.0.2:443" {
+		t.Fatalf("unexpected destinations: %v", got)
+	}
+}
+
+func TestDialerSOCKS5RemoteResolve(t *testing.T) {
+	dests := make(chan string, 10)
+	proxy := testSOCKS5Server(t, "", "", "", dests)
+
+	d := NewDialer(&Resolver{}, nil, WithSOCKS5(proxy, "", "", true))
+	conn, err := d.DialContext(context.Background(), "tcp", "deeeet.com:443")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	conn.Close()
+
+	if got := <-dests; got != "deeeet.com:443" {
+		t.Fatalf("expect the host to be sent to the proxy, got %s", got)
+	}
+
+	if _, err := d.DialContext(context.Background(), "udp", "deeeet.com:53"); err == nil {
+		t.Fatalf("expect UDP through the proxy to fail")
+	}
+}
+
+func TestDialerSOCKS5AuthError(t *testing.T) {
+	dests := make(chan string, 10)
+	proxy := testSOCKS5Server(t, "user", "pass", "", dests)
+
+	d := NewDialer(&Resolver{}, nil, WithSOCKS5(proxy, "user", "wrong", true))
+	if _, err := d.DialContext(context.Background(), "tcp", "deeeet.com:443"); err != errSOCKS5Auth {
+		t.Fatalf("want %v, got %v", errSOCKS5Auth, err)
+	}
+}
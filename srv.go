@@ -0,0 +1,60 @@
+package dnscache
+
+import (
+	"math/rand"
+	"net"
+	"sort"
+)
+
+// randIntn is used to replace rand.Intn when test.
+var randIntn = func(n int) int {
+	return rand.Intn(n)
+}
+
+// orderSRV returns a copy of the SRV records in the order to try them (RFC 2782):
+// ascending priority, and randomized by weight within the same priority, so that
+// a record with a larger weight is more likely to be tried first. It is applied
+// for every dial so that a fixed order of the records, e.g. of cached ones, does not
+// skew the distribution.
+func orderSRV(srvs []*net.SRV) []*net.SRV {
+	ordered := make([]*net.SRV, len(srvs))
+	copy(ordered, srvs)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Priority < ordered[j].Priority
+	})
+
+	for start := 0; start < len(ordered); {
+		end := start + 1
+		for end < len(ordered) && ordered[end].Priority == ordered[start].Priority {
+			end++
+		}
+		shuffleByWeight(ordered[start:end])
+		start = end
+	}
+	return ordered
+}
+
+// shuffleByWeight orders the records of the same priority by weighted random
+// selection.
+func shuffleByWeight(srvs []*net.SRV) {
+	sum := 0
+	for _, srv := range srvs {
+		sum += int(srv.Weight)
+	}
+
+	for sum > 0 && len(srvs) > 1 {
+		s := 0
+		n := randIntn(sum + 1)
+		for i := range srvs {
+			s += int(srvs[i].Weight)
+			if s >= n {
+				if i > 0 {
+					srvs[0], srvs[i] = srvs[i], srvs[0]
+				}
+				break
+			}
+		}
+		sum -= int(srvs[0].Weight)
+		srvs = srvs[1:]
+	}
+}
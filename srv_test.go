@@ -0,0 +1,58 @@
+package dnscache
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestOrderSRV(t *testing.T) {
+	origFunc := randIntn
+	defer func() {
+		randIntn = origFunc
+	}()
+
+	srvs := []*net.SRV{
+		{Target: "c.mercari.io.", Priority: 20, Weight: 1},
+		{Target: "a.mercari.io.", Priority: 10, Weight: 1},
+		{Target: "b.mercari.io.", Priority: 10, Weight: 9},
+	}
+
+	cases := []struct {
+		n    int
+		want []string
+	}{
+		// The random number falls in the weight of a.
+		{n: 1, want: []string{"a.mercari.io.", "b.mercari.io.", "c.mercari.io."}},
+		// The random number falls in the weight of b.
+		{n: 5, want: []string{"b.mercari.io.", "a.mercari.io.", "c.mercari.io."}},
+	}
+
+	for _, tc := range cases {
+		randIntn = func(int) int { return tc.n }
+
+		var got []string
+		for _, srv := range orderSRV(srvs) {
+			got = append(got, srv.Target)
+		}
+		if !reflect.DeepEqual(tc.want, got) {
+			t.Fatalf("want %v, got %v", tc.want, got)
+		}
+	}
+
+	if srvs[0].Target != "c.mercari.io." {
+		t.Fatalf("expect the records not to be modified")
+	}
+}
+
+func TestOrderSRVZeroWeight(t *testing.T) {
+	srvs := []*net.SRV{
+		{Target: "a.mercari.io.", Priority: 10},
+		{Target: "b.mercari.io.", Priority: 10},
+	}
+
+	got := orderSRV(srvs)
+	if got[0].Target != "a.mercari.io." || got[1].Target != "b.mercari.io." {
+		t.Fatalf("expect the order of zero weight records to be kept, got %v", got)
+	}
+}
@@ -0,0 +1,49 @@
+package dnscache
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// WithStaleFallback makes foreground lookups by LookupIP and Fetch return the
+// previously cached IPs of the host instead of the error when the upstream
+// lookup fails, so that a brief outage of the upstream resolver does not fail
+// requests. Only entries stored within maxStale are served, or any entry if
+// maxStale is 0. Such results are flagged by Stale of LookupDoneInfo with the
+// error of the lookup and logged. Background refreshes keep failing as before.
+func WithStaleFallback(maxStale time.Duration) Option {
+	return Option{apply: func(r *Resolver) {
+		r.invalidDuration("WithStaleFallback", "max staleness", maxStale)
+		r.staleFallback = true
+		r.maxStale = maxStale
+	}}
+}
+
+// noStaleKey marks the context of a lookup which must not be served stale IPs,
+// e.g. the one of RefreshHost.
+type noStaleKey struct{}
+
+// staleIPs returns the cached IPs of addr to serve in place of the failed
+// foreground lookup with err, if the fallback is enabled.
+func (r *Resolver) staleIPs(ctx context.Context, addr string, err error) ([]net.IP, bool) {
+	if noStale, _ := ctx.Value(noStaleKey{}).(bool); !r.staleFallback || noStale || isRefresh(ctx) {
+		return nil, false
+	}
+
+	e, ok := r.cached(addr)
+	if !ok {
+		return nil, false
+	}
+	age := r.now().Sub(e.updated)
+	if r.maxStale > 0 && age >= r.maxStale {
+		return nil, false
+	}
+
+	r.logger.Warn("serving stale DNS cache",
+		"error", err,
+		"addr", addr,
+		"age", age,
+	)
+	return e.ips, true
+}
@@ -0,0 +1,67 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestStaleFallback(t *testing.T) {
+	clock := newFakeClock()
+	failing := false
+	var done []LookupDoneInfo
+	r := &Resolver{
+		cache:         map[string]*entry{},
+		lookupTimeout: time.Second,
+		lookupIPFn: func(ctx context.Context, network, host string) ([]net.IP, error) {
+			if failing {
+				return nil, errors.New("lookup failed")
+			}
+			return []net.IP{net.ParseIP("127.0.0.1")}, nil
+		},
+		defaultLookupTimeout: time.Second,
+		clock:                clock,
+		logger:               slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	WithStaleFallback(time.Minute).apply(r)
+	WithHooks(Hooks{OnLookupDone: func(info LookupDoneInfo) {
+		done = append(done, info)
+	}}).apply(r)
+
+	if _, err := r.LookupIP(context.Background(), "deeeet.com"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	failing = true
+	ips, err := r.LookupIP(context.Background(), "deeeet.com")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(ips) != 1 || !ips[0].Equal(net.ParseIP("127.0.0.1")) {
+		t.Fatalf("got %v; want the stale IPs", ips)
+	}
+	if info := done[len(done)-1]; !info.Stale || info.Err == nil || info.IPs != 1 {
+		t.Fatalf("got %+v; want the result to be flagged as stale", info)
+	}
+
+	// Unknown hosts, refreshes and RefreshHost keep failing.
+	if _, err := r.LookupIP(context.Background(), "unknown.deeeet.com"); err == nil {
+		t.Fatalf("expect to be failed")
+	}
+	if summary := r.refresh(context.Background(), false); summary.Failures != 1 {
+		t.Fatalf("got %d failures; want the refresh to fail", summary.Failures)
+	}
+	if ips, err := r.RefreshHost(context.Background(), "deeeet.com"); err == nil || ips != nil {
+		t.Fatalf("got %v and %v; want the upstream error", ips, err)
+	}
+
+	// Entries older than the max staleness are not served.
+	clock.Advance(time.Minute)
+	if _, err := r.LookupIP(context.Background(), "deeeet.com"); err == nil {
+		t.Fatalf("expect to be failed")
+	}
+}
@@ -0,0 +1,18 @@
+module go.mercari.io/go-dnscache/statsdmetrics
+
+go 1.21
+
+require (
+	github.com/DataDog/datadog-go/v5 v5.5.0
+	go.mercari.io/go-dnscache v0.2.0
+)
+
+require (
+	github.com/Microsoft/go-winio v0.5.0 // indirect
+	golang.org/x/sys v0.0.0-20210510120138-977fb7262007 // indirect
+)
+
+// The parent module is used from this repository until go-dnscache v0.2.0,
+// which introduces the APIs used by this module, is released. Drop this replace
+// directive when releasing this module.
+replace go.mercari.io/go-dnscache => ../
@@ -0,0 +1,107 @@
+// Package statsdmetrics pushes the counters and timings of a go-dnscache
+// resolver to a StatsD or DogStatsD agent by a datadog-go client, for services
+// which are monitored through the agent rather than scraped.
+package statsdmetrics // import "go.mercari.io/go-dnscache/statsdmetrics"
+
+import (
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+	dnscache "go.mercari.io/go-dnscache"
+)
+
+// Prefix is the prefix of the metric names when none is given to New.
+const Prefix = "dnscache."
+
+// Tags added to lookup and dial timings.
+const (
+	tagSuccess = "result:success"
+	tagError   = "result:error"
+)
+
+// Metrics sends the measurements of a resolver by a statsd client. It implements
+// dnscache.Metrics, so give it to the resolver by dnscache.WithMetrics:
+//
+//	client, _ := statsd.New("127.0.0.1:8125")
+//	m := statsdmetrics.New(client, "", []string{"service:api"})
+//	resolver, _ := dnscache.New(freq, timeout, dnscache.WithMetrics(m))
+//	m.Track(resolver)
+//
+// The cache size is sent as a gauge at the end of every refresh cycle.
+type Metrics struct {
+	client   statsd.ClientInterface
+	prefix   string
+	tags     []string
+	resolver atomic.Pointer[dnscache.Resolver]
+
+	successTags []string
+	errorTags   []string
+}
+
+var (
+	_ dnscache.Metrics     = (*Metrics)(nil)
+	_ dnscache.DialMetrics = (*Metrics)(nil)
+)
+
+// New returns metrics sent by client with the given name prefix and tags. If
+// prefix is empty, Prefix is used.
+func New(client statsd.ClientInterface, prefix string, tags []string) *Metrics {
+	if prefix == "" {
+		prefix = Prefix
+	}
+
+	return &Metrics{
+		client:      client,
+		prefix:      prefix,
+		tags:        tags,
+		successTags: append(tags[:len(tags):len(tags)], tagSuccess),
+		errorTags:   append(tags[:len(tags):len(tags)], tagError),
+	}
+}
+
+// Track makes the metrics send the cache size of the given resolver.
+func (m *Metrics) Track(resolver *dnscache.Resolver) {
+	m.resolver.Store(resolver)
+}
+
+// CacheHit implements dnscache.Metrics.
+func (m *Metrics) CacheHit(host string) {
+	m.client.Incr(m.prefix+"cache.hits", m.tags, 1)
+}
+
+// CacheMiss implements dnscache.Metrics.
+func (m *Metrics) CacheMiss(host string) {
+	m.client.Incr(m.prefix+"cache.misses", m.tags, 1)
+}
+
+// LookupDone implements dnscache.Metrics. The timing is tagged with the result
+// and the tags given to New, but not the host, since every tag value creates a
+// custom metric on the agent side.
+func (m *Metrics) LookupDone(host string, d time.Duration, err error) {
+	tags := m.successTags
+	if err != nil {
+		tags = m.errorTags
+	}
+	m.client.Timing(m.prefix+"lookup.duration", d, tags, 1)
+}
+
+// RefreshDone implements dnscache.Metrics.
+func (m *Metrics) RefreshDone(d time.Duration, failures int) {
+	m.client.Timing(m.prefix+"refresh.duration", d, m.tags, 1)
+	m.client.Count(m.prefix+"refresh.errors", int64(failures), m.tags, 1)
+	if r := m.resolver.Load(); r != nil {
+		m.client.Gauge(m.prefix+"cache.size", float64(r.Len()), m.tags, 1)
+	}
+}
+
+// DialDone implements dnscache.DialMetrics. It sends the connect latency tagged
+// with the result, like LookupDone.
+func (m *Metrics) DialDone(host string, ip net.IP, d time.Duration, err error) {
+	tags := m.successTags
+	if err != nil {
+		tags = m.errorTags
+	}
+	m.client.Timing(m.prefix+"dial.duration", d, tags, 1)
+}
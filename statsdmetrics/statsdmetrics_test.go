@@ -0,0 +1,85 @@
+package statsdmetrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+	dnscache "go.mercari.io/go-dnscache"
+)
+
+func TestMetrics(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer conn.Close()
+
+	client, err := statsd.New(conn.LocalAddr().String(), statsd.WithoutTelemetry(), statsd.WithoutClientSideAggregation())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	hosts := filepath.Join(t.TempDir(), "hosts")
+	if err := os.WriteFile(hosts, []byte("127.0.0.1 deeeet.com\n"), 0o644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	m := New(client, "", []string{"service:test"})
+	resolver, err := dnscache.New(time.Minute, time.Second, dnscache.WithHostsFile(hosts), dnscache.WithMetrics(m))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resolver.Stop()
+	m.Track(resolver)
+
+	resolver.Fetch(context.Background(), "deeeet.com")
+	resolver.Fetch(context.Background(), "deeeet.com")
+	resolver.Refresh()
+
+	dialer := dnscache.NewDialer(resolver, func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, errors.New("connection refused")
+	}, dnscache.WithDialStats())
+	dialer.DialContext(context.Background(), "tcp", "deeeet.com:443")
+	if err := client.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var got []string
+	buf := make([]byte, 65535)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			break
+		}
+		for _, line := range strings.Split(strings.TrimSpace(string(buf[:n])), "\n") {
+			name, rest, _ := strings.Cut(line, ":")
+			_, tags, _ := strings.Cut(rest, "|#")
+			got = append(got, name+" "+tags)
+		}
+	}
+	sort.Strings(got)
+
+	want := []string{
+		"dnscache.cache.hits service:test",
+		"dnscache.cache.hits service:test",
+		"dnscache.cache.misses service:test",
+		"dnscache.cache.size service:test",
+		"dnscache.dial.duration service:test,result:error",
+		"dnscache.lookup.duration service:test,result:success",
+		"dnscache.lookup.duration service:test,result:success",
+		"dnscache.refresh.duration service:test",
+		"dnscache.refresh.errors service:test",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("got %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,27 @@
+package dnscache
+
+import "time"
+
+// RefreshSummary summarizes a refresh cycle of the cache.
+type RefreshSummary struct {
+	// Hosts is the number of cached hosts which were refreshed.
+	Hosts int
+
+	// Failures is the number of hosts which failed to be refreshed.
+	Failures int
+
+	// Changed is the number of hosts whose IP set changed.
+	Changed int
+
+	// Duration is the time the whole cycle took.
+	Duration time.Duration
+}
+
+// WithRefreshListener sets a function called with the summary at the end of
+// every refresh cycle, including ones started by Refresh. It is called
+// synchronously from the refreshing goroutine.
+func WithRefreshListener(listener func(RefreshSummary)) Option {
+	return Option{apply: func(r *Resolver) {
+		r.onRefresh = listener
+	}}
+}
@@ -0,0 +1,44 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRefreshListener(t *testing.T) {
+	var got []RefreshSummary
+	r := &Resolver{
+		cache: map[string]*entry{
+			"deeeet.com":         {ips: []net.IP{net.ParseIP("127.0.0.1")}},
+			"changed.deeeet.com": {ips: []net.IP{net.ParseIP("127.0.0.1")}},
+			"fail.deeeet.com":    {ips: []net.IP{net.ParseIP("127.0.0.1")}},
+		},
+		lookupIPFn: func(ctx context.Context, network, host string) ([]net.IP, error) {
+			switch host {
+			case "changed.deeeet.com":
+				return []net.IP{net.ParseIP("127.0.0.2")}, nil
+			case "fail.deeeet.com":
+				return nil, errors.New("lookup failed")
+			}
+			return []net.IP{net.ParseIP("127.0.0.1")}, nil
+		},
+		defaultLookupTimeout: time.Second,
+		logger:               slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	WithRefreshListener(func(s RefreshSummary) {
+		got = append(got, s)
+	}).apply(r)
+
+	r.Refresh()
+	if len(got) != 1 {
+		t.Fatalf("got %d summaries; want 1", len(got))
+	}
+	if s := got[0]; s.Hosts != 3 || s.Failures != 1 || s.Changed != 1 || s.Duration <= 0 {
+		t.Fatalf("got %+v; want 3 hosts, 1 failure and 1 change", s)
+	}
+}
@@ -0,0 +1,45 @@
+package dnscache
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+)
+
+// DialTLSFunc is a helper function which returns a dial function establishing TLS
+// connections over the connections dialed by DialFunc. Although the cached IP is
+// dialed, the server name and the certificate are verified against the hostname
+// of the dialed address, so SNI and verification work as if the hostname were
+// dialed. The ServerName of config is used instead when it is set. config may be nil.
+//
+// You can use returned dial function for `http.Transport.DialTLSContext`.
+func DialTLSFunc(resolver *Resolver, config *tls.Config, baseDialFunc dialFunc, options ...DialOption) dialFunc {
+	dial := DialFunc(resolver, baseDialFunc, options...)
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		h, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg := &tls.Config{}
+		if config != nil {
+			cfg = config.Clone()
+		}
+		if cfg.ServerName == "" {
+			cfg.ServerName = h
+		}
+
+		tlsConn := tls.Client(conn, cfg)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return tlsConn, nil
+	}
+}
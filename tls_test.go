@@ -0,0 +1,71 @@
+package dnscache
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDialTLSFunc(t *testing.T) {
+	var serverName string
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serverName = r.TLS.ServerName
+		io.WriteString(w, "ok")
+	}))
+	srv.StartTLS()
+	defer srv.Close()
+
+	// The certificate of httptest is valid for example.com.
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	resolver := &Resolver{
+		cache: map[string]*entry{
+			"example.com": {ips: []net.IP{net.ParseIP("127.0.0.1")}},
+		},
+		lookupTimeout: time.Second,
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	client := &http.Client{Transport: &http.Transport{
+		DialTLSContext: DialTLSFunc(resolver, &tls.Config{RootCAs: pool}, nil),
+	}}
+
+	resp, err := client.Get("https://" + net.JoinHostPort("example.com", port))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+
+	if serverName != "example.com" {
+		t.Fatalf("expect SNI to be example.com, got %q", serverName)
+	}
+}
+
+func TestDialTLSFuncVerifyError(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	resolver := &Resolver{
+		cache: map[string]*entry{
+			"deeeet.com": {ips: []net.IP{net.ParseIP("127.0.0.1")}},
+		},
+		lookupTimeout: time.Second,
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	client := &http.Client{Transport: &http.Transport{
+		DialTLSContext: DialTLSFunc(resolver, &tls.Config{RootCAs: pool}, nil),
+	}}
+
+	// The certificate is not valid for deeeet.com even though the IP is same.
+	if _, err := client.Get("https://" + net.JoinHostPort("deeeet.com", port)); err == nil {
+		t.Fatalf("expect certificate verification to fail")
+	}
+}
@@ -0,0 +1,46 @@
+package dnscache
+
+import (
+	"context"
+	"strconv"
+)
+
+// Span names of the operations traced by a Tracer.
+const (
+	SpanFetch    = "dnscache.Fetch"
+	SpanLookupIP = "dnscache.LookupIP"
+	SpanRefresh  = "dnscache.Refresh"
+)
+
+// Tracer starts trace spans around resolver operations, e.g. to make DNS time
+// visible in distributed traces. It must be safe for concurrent use.
+type Tracer interface {
+	// Start starts a span of the given name for host, which is empty for refresh
+	// cycles, as a child of the span in ctx. cacheHit is set for Fetch spans served
+	// from the cache. It returns the context holding the span and a function which
+	// ends the span with the error of the operation.
+	Start(ctx context.Context, name, host string, cacheHit bool) (context.Context, func(err error))
+}
+
+// WithTracer traces Fetch, LookupIP and refresh cycles of the resolver with t.
+func WithTracer(t Tracer) Option {
+	return Option{apply: func(r *Resolver) {
+		r.tracer = t
+	}}
+}
+
+// startSpan starts a span by the tracer if configured.
+func (r *Resolver) startSpan(ctx context.Context, name, host string, cacheHit bool) (context.Context, func(err error)) {
+	if r.tracer == nil {
+		return ctx, func(error) {}
+	}
+	return r.tracer.Start(ctx, name, host, cacheHit)
+}
+
+// refreshError is the error of a refresh cycle in which some hosts failed to be
+// refreshed.
+type refreshError int
+
+func (e refreshError) Error() string {
+	return "dnscache: failed to refresh " + strconv.Itoa(int(e)) + " hosts"
+}
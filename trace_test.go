@@ -0,0 +1,72 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type spanKey struct{}
+
+type testTracer struct {
+	mu    sync.Mutex
+	spans []string
+}
+
+func (t *testTracer) Start(ctx context.Context, name, host string, cacheHit bool) (context.Context, func(err error)) {
+	span := name + " " + host
+	if cacheHit {
+		span += " hit"
+	}
+	if parent, ok := ctx.Value(spanKey{}).(string); ok {
+		span = parent + " > " + span
+	}
+	return context.WithValue(ctx, spanKey{}, span), func(err error) {
+		if err != nil {
+			span += " error"
+		}
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.spans = append(t.spans, span)
+	}
+}
+
+func TestTracer(t *testing.T) {
+	tracer := &testTracer{}
+	r := &Resolver{
+		cache:         map[string]*entry{},
+		lookupTimeout: time.Second,
+		tracer:        tracer,
+		lookupIPFn: func(ctx context.Context, network, host string) ([]net.IP, error) {
+			if host == "fail.deeeet.com" {
+				return nil, errors.New("lookup failed")
+			}
+			return []net.IP{net.ParseIP("127.0.0.1")}, nil
+		},
+		defaultLookupTimeout: time.Second,
+		logger:               slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	r.Fetch(context.Background(), "deeeet.com")
+	r.Fetch(context.Background(), "deeeet.com")
+	r.cache["fail.deeeet.com"] = &entry{ips: []net.IP{net.ParseIP("127.0.0.2")}}
+	delete(r.cache, "deeeet.com")
+	r.Refresh()
+
+	want := []string{
+		"dnscache.Fetch deeeet.com > dnscache.LookupIP deeeet.com",
+		"dnscache.Fetch deeeet.com",
+		"dnscache.Fetch deeeet.com hit",
+		"dnscache.Refresh  > dnscache.LookupIP fail.deeeet.com error",
+		"dnscache.Refresh  error",
+	}
+	if !reflect.DeepEqual(tracer.spans, want) {
+		t.Fatalf("got %q; want %q", tracer.spans, want)
+	}
+}
@@ -0,0 +1,73 @@
+package dnscache
+
+import (
+	"errors"
+	"time"
+)
+
+// invalid records that an option was given an invalid value, so that New fails
+// with the reason instead of the option being silently ignored.
+func (r *Resolver) invalid(option, reason string) {
+	r.optionErrs = append(r.optionErrs, errors.New("dnscache: "+option+": "+reason))
+}
+
+// invalidDuration records the option as invalid if d is negative.
+func (r *Resolver) invalidDuration(option, name string, d time.Duration) {
+	if d < 0 {
+		r.invalid(option, "negative "+name)
+	}
+}
+
+// validate checks the options given to New and their combination before the
+// resolver is started, and returns an error describing every problem found.
+func (r *Resolver) validate() error {
+	errs := r.optionErrs
+
+	if r.lookupIPFn == nil {
+		errs = append(errs, errors.New("dnscache: nil lookup function"))
+	}
+	if r.logger == nil {
+		errs = append(errs, errors.New("dnscache: WithLogger: nil logger"))
+	}
+
+	switch r.network {
+	case "ip", "ip4", "ip6":
+	default:
+		errs = append(errs, errors.New("dnscache: unknown network "+r.network))
+	}
+
+	if r.nameserver == nil {
+		if r.clientSubnet.IsValid() {
+			errs = append(errs, errors.New("dnscache: WithClientSubnet requires WithNameserver"))
+		}
+		if r.dnssec != dnssecOff {
+			errs = append(errs, errors.New("dnscache: WithDNSSEC requires WithNameserver"))
+		}
+		if r.wireFormat {
+			errs = append(errs, errors.New("dnscache: WithWireFormat requires WithNameserver"))
+		}
+	}
+	if len(r.search) > 0 && r.resolvConfPath != "" {
+		errs = append(errs, errors.New("dnscache: WithSearchDomains conflicts with WithResolvConf"))
+	}
+	if r.onDemand && len(r.intervals) > 0 {
+		errs = append(errs, errors.New("dnscache: WithHostRefreshInterval conflicts with WithOnDemand"))
+	}
+	if r.onDemand && r.adaptive != nil {
+		errs = append(errs, errors.New("dnscache: WithAdaptiveRefresh conflicts with WithOnDemand"))
+	}
+
+	if r.retry.attempts < 0 {
+		errs = append(errs, errors.New("dnscache: WithRetry: negative attempts"))
+	}
+	if r.retry.baseDelay < 0 {
+		errs = append(errs, errors.New("dnscache: WithRetry: negative base delay"))
+	}
+	if r.retry.jitter < 0 || r.retry.jitter > 1 {
+		errs = append(errs, errors.New("dnscache: WithRetry: jitter must be between 0 and 1"))
+	}
+	if r.ndots < 0 {
+		errs = append(errs, errors.New("dnscache: WithSearchDomains: negative ndots"))
+	}
+	return errors.Join(errs...)
+}
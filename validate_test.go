@@ -0,0 +1,118 @@
+package dnscache
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNew_validate(t *testing.T) {
+	cases := map[string]struct {
+		options []Option
+		want    string
+	}{
+		"nil logger": {
+			options: []Option{WithLogger(nil)},
+			want:    "dnscache: WithLogger: nil logger",
+		},
+		"search domains with resolv.conf": {
+			options: []Option{WithSearchDomains([]string{"deeeet.com"}, 1), WithResolvConf("")},
+			want:    "dnscache: WithSearchDomains conflicts with WithResolvConf",
+		},
+		"host interval with on-demand": {
+			options: []Option{WithOnDemand(time.Minute), WithHostRefreshInterval(".deeeet.com", time.Minute)},
+			want:    "dnscache: WithHostRefreshInterval conflicts with WithOnDemand",
+		},
+		"negative capacity": {
+			options: []Option{WithCacheCapacity(-1)},
+			want:    "dnscache: WithCacheCapacity: negative capacity",
+		},
+		"negative timeout": {
+			options: []Option{WithLookupTimeout(-time.Second)},
+			want:    "dnscache: WithLookupTimeout: negative timeout",
+		},
+		"negative rate": {
+			options: []Option{WithRefreshRateLimit(-1, 1)},
+			want:    "dnscache: WithRefreshRateLimit: negative rate or burst",
+		},
+		"unknown IP version preference": {
+			options: []Option{WithIPVersionPreference(IPv6Only + 1)},
+			want:    "dnscache: WithIPVersionPreference: unknown preference 5",
+		},
+		"unknown rotation": {
+			options: []Option{WithRotation(-1)},
+			want:    "dnscache: WithRotation: unknown rotation -1",
+		},
+		"unknown empty result policy": {
+			options: []Option{WithEmptyResults(EmptyResultSkip + 1)},
+			want:    "dnscache: WithEmptyResults: unknown policy 3",
+		},
+		"empty nameserver": {
+			options: []Option{WithNameserver("")},
+			want:    "dnscache: WithNameserver: empty address",
+		},
+		"nameserver without host": {
+			options: []Option{WithNameserver(":53")},
+			want:    "dnscache: WithNameserver: empty address",
+		},
+		"zero on-demand max age": {
+			options: []Option{WithOnDemand(0)},
+			want:    "dnscache: WithOnDemand: non-positive max age",
+		},
+		"invalid jitter": {
+			options: []Option{WithRetry(3, time.Millisecond, 2)},
+			want:    "dnscache: WithRetry: jitter must be between 0 and 1",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r, err := New(testFreq, testDefaultLookupTimeout, tc.options...)
+			if err == nil {
+				r.Stop()
+				t.Fatalf("expect to be failed")
+			}
+			if err.Error() != tc.want {
+				t.Fatalf("got %q; want %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestNew_validateAll(t *testing.T) {
+	_, err := New(testFreq, testDefaultLookupTimeout,
+		WithHistory(-1),
+		WithUnhealthyAfter(-1),
+		WithNetwork("tcp"),
+	)
+	if err == nil {
+		t.Fatalf("expect to be failed")
+	}
+	for _, want := range []string{"WithHistory", "WithUnhealthyAfter", "unknown network tcp"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("got %q; want it to contain %q", err, want)
+		}
+	}
+}
+
+func TestNew_nilLookupFunc(t *testing.T) {
+	originalFunc := lookupIP
+	defer func() {
+		lookupIP = originalFunc
+	}()
+
+	lookupIP = nil
+	if _, err := New(testFreq, testDefaultLookupTimeout); err == nil || err.Error() != "dnscache: nil lookup function" {
+		t.Fatalf("got %v; want the nil lookup function to be rejected", err)
+	}
+
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		return nil, nil
+	}
+	r, err := New(testFreq, testDefaultLookupTimeout)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	r.Stop()
+}
@@ -0,0 +1,16 @@
+module go.mercari.io/go-dnscache/websocketdialer
+
+go 1.21
+
+require (
+	github.com/gorilla/websocket v1.5.1
+	go.mercari.io/go-dnscache v0.2.0
+	nhooyr.io/websocket v1.8.10
+)
+
+require golang.org/x/net v0.17.0 // indirect
+
+// The parent module is used from this repository until go-dnscache v0.2.0,
+// which introduces the APIs used by this module, is released. Drop this replace
+// directive when releasing this module.
+replace go.mercari.io/go-dnscache => ../
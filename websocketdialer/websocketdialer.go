@@ -0,0 +1,28 @@
+// Package websocketdialer provides WebSocket dialers backed by go-dnscache for
+// gorilla/websocket and nhooyr.io/websocket. Connections resolve hosts through
+// the cache and fail over to the other cached IPs, which keeps mass reconnects
+// after a DNS change from hitting DNS and dead backends.
+package websocketdialer // import "go.mercari.io/go-dnscache/websocketdialer"
+
+import (
+	"github.com/gorilla/websocket"
+	dnscache "go.mercari.io/go-dnscache"
+	nhooyr "nhooyr.io/websocket"
+)
+
+// NewGorillaDialer returns a gorilla/websocket Dialer whose NetDialContext dials
+// the IPs cached by the resolver with `dnscache.DialFunc`. The other settings are
+// same as `websocket.DefaultDialer`. TLS of wss URLs is established by gorilla
+// against the hostname of the URL as usual. The given options are passed to DialFunc.
+func NewGorillaDialer(resolver *dnscache.Resolver, options ...dnscache.DialOption) *websocket.Dialer {
+	d := *websocket.DefaultDialer
+	d.NetDialContext = dnscache.DialFunc(resolver, nil, options...)
+	return &d
+}
+
+// NhooyrDialOptions returns nhooyr.io/websocket DialOptions whose HTTP client is
+// the one returned by `dnscache.NewHTTPClient`. The given options are passed to
+// DialFunc.
+func NhooyrDialOptions(resolver *dnscache.Resolver, options ...dnscache.DialOption) *nhooyr.DialOptions {
+	return &nhooyr.DialOptions{HTTPClient: dnscache.NewHTTPClient(resolver, options...)}
+}
@@ -0,0 +1,82 @@
+package websocketdialer
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	dnscache "go.mercari.io/go-dnscache"
+	nhooyr "nhooyr.io/websocket"
+)
+
+// testServer starts a WebSocket echo server and returns the URL of it with the
+// host of which IP is cached by the returned resolver.
+func testServer(t *testing.T) (string, *dnscache.Resolver) {
+	t.Helper()
+
+	var upgrader websocket.Upgrader
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		typ, msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		conn.WriteMessage(typ, msg)
+	}))
+	t.Cleanup(srv.Close)
+
+	resolver, err := dnscache.New(time.Minute, time.Second, dnscache.WithStaticEntries(map[string][]net.IP{
+		"ws.mercari.io": {net.ParseIP("127.0.0.1")},
+	}))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	t.Cleanup(resolver.Stop)
+
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	return "ws://" + net.JoinHostPort("ws.mercari.io", port), resolver
+}
+
+func TestNewGorillaDialer(t *testing.T) {
+	url, resolver := testServer(t)
+
+	conn, _, err := NewGorillaDialer(resolver).Dial(url, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer conn.Close()
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("ping")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, msg, err := conn.ReadMessage(); err != nil || string(msg) != "ping" {
+		t.Fatalf("expect ping to be echoed, got %q, err: %v", msg, err)
+	}
+}
+
+func TestNhooyrDialOptions(t *testing.T) {
+	url, resolver := testServer(t)
+
+	ctx, cancelF := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelF()
+	conn, _, err := nhooyr.Dial(ctx, url, NhooyrDialOptions(resolver))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer conn.Close(nhooyr.StatusNormalClosure, "")
+
+	if err := conn.Write(ctx, nhooyr.MessageText, []byte("ping")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, msg, err := conn.Read(ctx); err != nil || string(msg) != "ping" {
+		t.Fatalf("expect ping to be echoed, got %q, err: %v", msg, err)
+	}
+}